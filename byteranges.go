@@ -0,0 +1,116 @@
+package itermultipart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeNotSatisfiable is returned when none of the requested byte ranges overlaps the content.
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// ByteRange is a range of Length bytes of the content starting at Start.
+type ByteRange struct {
+	Start, Length int64
+}
+
+// ContentRange returns the Content-Range header value of the range for the content of the given size.
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// ParseByteRanges parses the Range header value, i.e. "bytes=0-99,-100", for the content of the given size.
+// Ranges not overlapping the content are dropped, [ErrRangeNotSatisfiable] is returned if no range is left.
+func ParseByteRanges(header string, size int64) ([]ByteRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, fmt.Errorf("invalid range %q", header)
+	}
+
+	var ranges []ByteRange
+	for _, r := range strings.Split(spec, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		start, end, ok := strings.Cut(r, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q", r)
+		}
+		start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+
+		if start == "" {
+			// suffix range "-N" selects the last N bytes
+			n, err := strconv.ParseInt(end, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid range %q", r)
+			}
+			n = min(n, size)
+			if n == 0 {
+				continue
+			}
+			ranges = append(ranges, ByteRange{Start: size - n, Length: n})
+			continue
+		}
+
+		first, err := strconv.ParseInt(start, 10, 64)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid range %q", r)
+		}
+		last := size - 1
+		if end != "" {
+			if last, err = strconv.ParseInt(end, 10, 64); err != nil || last < first {
+				return nil, fmt.Errorf("invalid range %q", r)
+			}
+			last = min(last, size-1)
+		}
+		if first >= size {
+			continue
+		}
+		ranges = append(ranges, ByteRange{Start: first, Length: last - first + 1})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("%w: %q of %d bytes", ErrRangeNotSatisfiable, header, size)
+	}
+	return ranges, nil
+}
+
+// ByteRangeParts returns parts of a multipart/byteranges message (RFC 9110, section 14.6) for the content
+// of the given size. Every part has the Content-Type header, if it's not empty, the Content-Range header
+// and reads its range from the content. An error is yielded for a range exceeding the content.
+// The sequence can be iterated multiple times.
+func ByteRangeParts(content io.ReaderAt, size int64, contentType string, ranges []ByteRange) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for _, r := range ranges {
+			if r.Start < 0 || r.Length <= 0 || r.Start+r.Length > size {
+				yield(nil, fmt.Errorf("%w: %d bytes at %d of %d", ErrRangeNotSatisfiable, r.Length, r.Start, size))
+				return
+			}
+
+			part := NewPart().
+				SetHeaderValue(contentRangeHeader, r.ContentRange(size)).
+				SetContent(io.NewSectionReader(content, r.Start, r.Length))
+			if contentType != "" {
+				part.SetContentType(contentType)
+			}
+			if !yield(part, nil) {
+				return
+			}
+		}
+	}
+}
+
+// NewByteRangesSource returns a [Source] generating a multipart/byteranges message from [ByteRangeParts],
+// i.e. for an HTTP 206 response to a multi-range request. Use [Source.ByteRangesContentType] for the Content-Type header.
+// Parts have known sizes and the sequence is replayable, so [Source.ContentLength] reports the response size.
+func NewByteRangesSource(content io.ReaderAt, size int64, contentType string, ranges []ByteRange, opts ...SourceOption) *Source {
+	return NewSource(ByteRangeParts(content, size, contentType, ranges), append([]SourceOption{WithReplayableParts()}, opts...)...)
+}
+
+// ByteRangesContentType returns the Content-Type for a multipart/byteranges message with this [Source]'s Boundary.
+func (s *Source) ByteRangesContentType() string {
+	return s.ContentType("byteranges", nil)
+}
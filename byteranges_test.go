@@ -0,0 +1,131 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestParseByteRanges(t *testing.T) {
+	type BR = itermultipart.ByteRange
+	cases := []struct {
+		header string
+		want   []BR
+		err    error
+	}{
+		{"bytes=0-4", []BR{{0, 5}}, nil},
+		{"bytes=0-4, 10-", []BR{{0, 5}, {10, 10}}, nil},
+		{"bytes=-5", []BR{{15, 5}}, nil},
+		{"bytes=-50", []BR{{0, 20}}, nil},
+		{"bytes=15-100", []BR{{15, 5}}, nil},
+		{"bytes=0-0,30-40", []BR{{0, 1}}, nil},
+		{"bytes=30-40", nil, itermultipart.ErrRangeNotSatisfiable},
+		{"bytes=-0", nil, itermultipart.ErrRangeNotSatisfiable},
+		{"bytes=5-1", nil, nil},
+		{"bytes=a-b", nil, nil},
+		{"items=0-1", nil, nil},
+	}
+	for _, c := range cases {
+		got, err := itermultipart.ParseByteRanges(c.header, 20)
+		if c.want == nil {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", c.header, got)
+			} else if c.err != nil && !errors.Is(err, c.err) {
+				t.Errorf("%q: got error %s, want %s", c.header, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", c.header, err)
+			continue
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.header, got, c.want)
+		}
+	}
+}
+
+func TestNewByteRangesSource(t *testing.T) {
+	const content = "0123456789abcdefghij"
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges, err := itermultipart.ParseByteRanges(r.Header.Get("Range"), int64(len(content)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		src := itermultipart.NewByteRangesSource(strings.NewReader(content), int64(len(content)), "text/plain", ranges)
+		length, ok := src.ContentLength()
+		if !ok {
+			t.Error("ContentLength: expected known length")
+		}
+		w.Header().Set("Content-Type", src.ByteRangesContentType())
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if _, err := src.WriteTo(w); err != nil {
+			t.Errorf("WriteTo: unexpected error %s", err)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=0-3,-5")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusPartialContent)
+	}
+	if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(rec.Body.Len()); got != want {
+		t.Errorf("got Content-Length %s, want %s", got, want)
+	}
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("unexpected Content-Type %q (%v)", rec.Header().Get("Content-Type"), err)
+	}
+
+	want := []struct{ contentRange, content string }{
+		{"bytes 0-3/20", "0123"},
+		{"bytes 15-19/20", "fghij"},
+	}
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+	for i, w := range want {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: unexpected error %s", i, err)
+		}
+		if got := p.Header.Get("Content-Range"); got != w.contentRange {
+			t.Errorf("part %d: got Content-Range %q, want %q", i, got, w.contentRange)
+		}
+		if got := p.Header.Get("Content-Type"); got != "text/plain" {
+			t.Errorf("part %d: got Content-Type %q, want text/plain", i, got)
+		}
+		got, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatalf("part %d: unexpected error %s", i, err)
+		}
+		if string(got) != w.content {
+			t.Errorf("part %d: got content %q, want %q", i, got, w.content)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestByteRangePartsInvalidRange(t *testing.T) {
+	parts := itermultipart.ByteRangeParts(strings.NewReader("abc"), 3, "", []itermultipart.ByteRange{{Start: 2, Length: 2}})
+	for _, err := range parts {
+		if !errors.Is(err, itermultipart.ErrRangeNotSatisfiable) {
+			t.Errorf("got error %v, want %s", err, itermultipart.ErrRangeNotSatisfiable)
+		}
+	}
+}
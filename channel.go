@@ -0,0 +1,80 @@
+package itermultipart
+
+import (
+	"context"
+	"iter"
+)
+
+// PartsFromChannel returns the sequence of parts received from ch until it's closed.
+// An error received from errCh, which may be nil, is yielded and ends the sequence;
+// an error sent to a buffered errCh right before closing ch is yielded too.
+// When the consumer stops early, the sequence returns without draining the channels,
+// so the producer must be stopped on its own, i.e. with a context. [PartsFromProducer] does that.
+// Parts are owned by the consumer once sent, the producer must not modify them.
+func PartsFromChannel(ch <-chan *Part, errCh <-chan error) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for {
+			select {
+			case part, ok := <-ch:
+				if !ok {
+					select {
+					case err := <-errCh:
+						if err != nil {
+							yield(nil, err)
+						}
+					default:
+					}
+					return
+				}
+				if !yield(part, nil) {
+					return
+				}
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil // closed without an error, keep receiving parts
+					continue
+				}
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// PartsFromProducer returns the sequence of parts produced by the function running in its own goroutine,
+// i.e. workers generating reports. The function sends parts with send and returns when it's done,
+// a returned error is yielded. Every iteration runs the function again.
+// When the consumer stops early, the context passed to the function is canceled and send returns its error,
+// the sequence waits for the function to return, so it must respect the context.
+func PartsFromProducer(ctx context.Context, produce func(ctx context.Context, send func(*Part) error) error) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		ch := make(chan *Part)
+		errCh := make(chan error, 1)
+		go func() {
+			defer close(ch)
+			errCh <- produce(ctx, func(part *Part) error {
+				select {
+				case ch <- part:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}()
+		defer func() {
+			cancel()
+			for range ch {
+				// wait for the producer to return
+			}
+		}()
+
+		for part, err := range PartsFromChannel(ch, errCh) {
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package itermultipart_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestPartsFromChannel(t *testing.T) {
+	errProduce := errors.New("produce failed")
+	ch := make(chan *itermultipart.Part)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		for i := range 3 {
+			ch <- itermultipart.NewFieldPart("report"+strconv.Itoa(i), "data")
+		}
+		errCh <- errProduce
+	}()
+
+	var count int
+	var gotErr error
+	for part, err := range itermultipart.PartsFromChannel(ch, errCh) {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		if want := "report" + strconv.Itoa(count); part.FormName() != want {
+			t.Errorf("got part %q, want %q", part.FormName(), want)
+		}
+		count++
+	}
+	if count != 3 || !errors.Is(gotErr, errProduce) {
+		t.Errorf("got %d parts and error %v, want 3 and %s", count, gotErr, errProduce)
+	}
+}
+
+func TestPartsFromProducer(t *testing.T) {
+	var canceled bool
+	parts := itermultipart.PartsFromProducer(context.Background(), func(ctx context.Context, send func(*itermultipart.Part) error) error {
+		for i := 0; ; i++ {
+			if err := send(itermultipart.NewFieldPart("n", strconv.Itoa(i))); err != nil {
+				canceled = errors.Is(err, context.Canceled)
+				return err
+			}
+		}
+	})
+
+	src := itermultipart.NewSource(itermultipart.LimitParts(parts, 5))
+	if _, err := src.WriteTo(io.Discard); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if stats := src.Stats(); stats.Parts != 5 {
+		t.Errorf("got %d parts, want 5", stats.Parts)
+	}
+	if !canceled {
+		t.Error("producer is not canceled")
+	}
+
+	errProduce := errors.New("produce failed")
+	failing := itermultipart.PartsFromProducer(context.Background(), func(ctx context.Context, send func(*itermultipart.Part) error) error {
+		if err := send(itermultipart.NewFieldPart("a", "1")); err != nil {
+			return err
+		}
+		return errProduce
+	})
+	for range 2 { // every iteration runs the producer
+		var count int
+		var gotErr error
+		for _, err := range failing {
+			if err != nil {
+				gotErr = err
+				continue
+			}
+			count++
+		}
+		if count != 1 || !errors.Is(gotErr, errProduce) {
+			t.Errorf("got %d parts and error %v, want 1 and %s", count, gotErr, errProduce)
+		}
+	}
+}
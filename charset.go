@@ -0,0 +1,247 @@
+package itermultipart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsetFieldName is the name of the form field declaring the default charset of text fields, see RFC 7578, Section 4.6.
+const charsetFieldName = "_charset_"
+
+// maxCharsetFieldSize limits the content of the "_charset_" field read by [DecodeCharset].
+const maxCharsetFieldSize = 64
+
+// CharsetReader returns a reader converting input in the charset to UTF-8 like [mime.WordDecoder.CharsetReader].
+// Charset name is lowercase.
+type CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// DecodeCharset converts contents of text form fields to UTF-8. Text fields are parts without a file name
+// and with "text/plain" or no Content-Type. Charset of the field is taken from its Content-Type charset parameter,
+// otherwise from the value of the preceding "_charset_" field (RFC 7578, Section 4.6).
+// UTF-8, US-ASCII and ISO-8859-1 are supported out of the box, other charsets are converted with charsetReader.
+// If charsetReader is nil or fails, an error is yielded instead of the field.
+// Content-Type charset parameter of converted fields is changed to "utf-8".
+// Parts are not modified permanently: the content and the header are restored after the part is yielded.
+func DecodeCharset(parts iter.Seq2[*Part, error], charsetReader CharsetReader) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		var defaultCharset string
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if part.FileName() != "" {
+				if !yield(part, nil) {
+					return
+				}
+				continue
+			}
+
+			content := part.Content
+			if part.FormName() == charsetFieldName && content != nil {
+				value, err := io.ReadAll(io.LimitReader(content, maxCharsetFieldSize))
+				if err != nil {
+					if !yield(nil, err) {
+						return
+					}
+					continue
+				}
+				defaultCharset = strings.ToLower(strings.TrimSpace(string(value)))
+				part.Content = bytes.NewReader(value)
+				next := yield(part, nil)
+				part.Content = content
+				if !next {
+					return
+				}
+				continue
+			}
+
+			contentType := part.Header.Get(contentTypeHeader)
+			mediaType, params, _ := mime.ParseMediaType(contentType)
+			charset := defaultCharset
+			if cs := params["charset"]; cs != "" {
+				charset = strings.ToLower(cs)
+			}
+			if contentType != "" && mediaType != "text/plain" || isUTF8Compatible(charset) || content == nil {
+				if !yield(part, nil) {
+					return
+				}
+				continue
+			}
+
+			var decoded io.Reader
+			switch {
+			case isLatin1(charset):
+				decoded = &latin1Reader{r: content}
+			case charsetReader != nil:
+				decoded, err = charsetReader(charset, content)
+			default:
+				err = fmt.Errorf("unsupported charset %q", charset)
+			}
+			if err != nil {
+				if !yield(nil, fmt.Errorf("multipart: field %q: %w", part.FormName(), err)) {
+					return
+				}
+				continue
+			}
+
+			part.Content = decoded
+			if contentType != "" {
+				params["charset"] = "utf-8"
+				part.Header.Set(contentTypeHeader, mime.FormatMediaType(mediaType, params))
+			}
+			next := yield(part, nil)
+			part.Content = content
+			if contentType != "" {
+				part.Header.Set(contentTypeHeader, contentType)
+			}
+			if !next {
+				return
+			}
+		}
+	}
+}
+
+// CharsetEncoder returns a reader converting UTF-8 input to the charset. Charset name is lowercase.
+type CharsetEncoder func(charset string, input io.Reader) (io.Reader, error)
+
+// DeclareCharset is the write-side counterpart of [DecodeCharset]: it yields the "_charset_" field with the charset
+// before the parts and converts contents of text fields from UTF-8 to the charset. "_charset_" fields of the parts
+// and text fields with their own Content-Type charset parameter are left out of the conversion, the former are dropped.
+// UTF-8, US-ASCII and ISO-8859-1 are supported out of the box, other charsets are converted with charsetEncoder.
+// If charsetEncoder is nil or fails, an error is yielded instead of the field.
+// Parts are not modified permanently: the content is restored after the part is yielded.
+func DeclareCharset(parts iter.Seq2[*Part, error], charset string, charsetEncoder CharsetEncoder) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		if !yield(NewFieldPart(charsetFieldName, charset), nil) {
+			return
+		}
+
+		target := strings.ToLower(charset)
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if part.FileName() == "" && part.FormName() == charsetFieldName {
+				continue
+			}
+
+			content := part.Content
+			mediaType, params, _ := mime.ParseMediaType(part.Header.Get(contentTypeHeader))
+			if part.FileName() != "" || mediaType != "" && mediaType != "text/plain" || params["charset"] != "" ||
+				isUTF8Compatible(target) || content == nil {
+				if !yield(part, nil) {
+					return
+				}
+				continue
+			}
+
+			var encoded io.Reader
+			switch {
+			case isLatin1(target):
+				encoded = &latin1Encoder{r: bufio.NewReader(content)}
+			case charsetEncoder != nil:
+				encoded, err = charsetEncoder(target, content)
+			default:
+				err = fmt.Errorf("unsupported charset %q", target)
+			}
+			if err != nil {
+				if !yield(nil, fmt.Errorf("multipart: field %q: %w", part.FormName(), err)) {
+					return
+				}
+				continue
+			}
+
+			part.Content = encoded
+			next := yield(part, nil)
+			part.Content = content
+			if !next {
+				return
+			}
+		}
+	}
+}
+
+func isUTF8Compatible(charset string) bool {
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return true
+	default:
+		return false
+	}
+}
+
+func isLatin1(charset string) bool {
+	switch charset {
+	case "iso-8859-1", "iso_8859-1", "latin1", "l1":
+		return true
+	default:
+		return false
+	}
+}
+
+// latin1Encoder converts UTF-8 to ISO-8859-1 failing on characters out of its range.
+type latin1Encoder struct {
+	r *bufio.Reader
+}
+
+func (le *latin1Encoder) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		r, size, err := le.r.ReadRune()
+		if err != nil {
+			if n > 0 && errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			return n, err
+		}
+		if r > 0xff || r == utf8.RuneError && size == 1 {
+			return n, fmt.Errorf("character %q can't be encoded in ISO-8859-1", r)
+		}
+		p[n] = byte(r)
+		n++
+	}
+	return n, nil
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8. Every byte of ISO-8859-1 is the code point of the same value.
+type latin1Reader struct {
+	r       io.Reader
+	err     error
+	buf     [512]byte
+	out     []byte
+	pending []byte // converted but not yet read part of out
+}
+
+func (lr *latin1Reader) Read(p []byte) (int, error) {
+	if len(lr.pending) == 0 {
+		if lr.err != nil {
+			return 0, lr.err
+		}
+		var n int
+		n, lr.err = lr.r.Read(lr.buf[:])
+		lr.out = lr.out[:0]
+		for _, b := range lr.buf[:n] {
+			lr.out = utf8.AppendRune(lr.out, rune(b))
+		}
+		lr.pending = lr.out
+		if n == 0 {
+			return 0, lr.err
+		}
+	}
+	n := copy(p, lr.pending)
+	lr.pending = lr.pending[n:]
+	return n, nil
+}
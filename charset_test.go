@@ -0,0 +1,118 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	latin1 := string([]byte{'c', 'a', 'f', 0xe9}) // "café" in ISO-8859-1
+	koi8 := string([]byte{0xd0, 0xd2, 0xc9, 0xd7, 0xc5, 0xd4})
+
+	var target struct {
+		Charset  string `multipart:"_charset_"`
+		Default  string `multipart:"default"`
+		Explicit string `multipart:"explicit"`
+		Custom   string `multipart:"custom"`
+		UTF8     string `multipart:"utf8"`
+		File     []byte `multipart:"file"`
+	}
+	parts := itermultipart.PartSeq(
+		itermultipart.NewFieldPart("utf8", "café"), // before _charset_
+		itermultipart.NewFieldPart("_charset_", "ISO-8859-1"),
+		itermultipart.NewFieldPart("default", latin1),
+		itermultipart.NewFieldPart("explicit", latin1).SetContentType("text/plain; charset=latin1"),
+		itermultipart.NewFieldPart("custom", koi8).SetContentType("text/plain; charset=KOI8-R"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("file.bin").SetContentString(latin1),
+	)
+	charsetReader := func(charset string, input io.Reader) (io.Reader, error) {
+		if charset != "koi8-r" {
+			return nil, errors.New("unexpected charset")
+		}
+		content, err := io.ReadAll(input)
+		if err != nil || !bytes.Equal(content, []byte(koi8)) {
+			return nil, errors.New("unexpected content")
+		}
+		return strings.NewReader("привет"), nil
+	}
+
+	var contentTypes []string
+	decoded := itermultipart.DecodeCharset(parts, charsetReader)
+	err := itermultipart.Unmarshal(func(yield func(*itermultipart.Part, error) bool) {
+		for part, err := range decoded {
+			if err == nil {
+				contentTypes = append(contentTypes, part.ContentType())
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}, &target)
+	if err != nil {
+		t.Fatalf("Unmarshal: unexpected error %s", err)
+	}
+
+	if target.Charset != "ISO-8859-1" || target.Default != "café" || target.Explicit != "café" ||
+		target.Custom != "привет" || target.UTF8 != "café" || string(target.File) != latin1 {
+		t.Errorf("unexpected result %+v", target)
+	}
+	if contentTypes[3] != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected content type %q of the converted field", contentTypes[3])
+	}
+
+	parts = itermultipart.PartSeq(
+		itermultipart.NewFieldPart("_charset_", "koi8-r"),
+		itermultipart.NewFieldPart("custom", koi8),
+	)
+	var errs []error
+	for _, err := range itermultipart.DecodeCharset(parts, nil) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `unsupported charset "koi8-r"`) {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func TestDeclareCharset(t *testing.T) {
+	parts := itermultipart.DeclareCharset(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("_charset_", "utf-8"), // replaced
+		itermultipart.NewFieldPart("text", "café"),
+		itermultipart.NewFieldPart("own", "café").SetContentType("text/plain; charset=utf-8"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContentString("café"),
+	), "ISO-8859-1", nil)
+
+	src := itermultipart.NewSource(parts)
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("\r\n\r\ncaf\xe9\r\n")) {
+		t.Errorf("text field is not encoded: %q", buf.String())
+	}
+
+	var target struct {
+		Charset []string `multipart:"_charset_"`
+		Text    string   `multipart:"text"`
+		Own     string   `multipart:"own"`
+		File    string   `multipart:"file"`
+	}
+	parsed := itermultipart.DecodeCharset(itermultipart.NewParser(&buf, src.Boundary()).Parts(), nil)
+	if err := itermultipart.Unmarshal(parsed, &target); err != nil {
+		t.Fatalf("Unmarshal: unexpected error %s", err)
+	}
+	if len(target.Charset) != 1 || target.Charset[0] != "ISO-8859-1" || target.Text != "café" || target.Own != "café" || target.File != "café" {
+		t.Errorf("unexpected result %+v", target)
+	}
+
+	parts = itermultipart.DeclareCharset(itermultipart.PartSeq(itermultipart.NewFieldPart("text", "привет")), "latin1", nil)
+	if _, err := itermultipart.NewSource(parts).WriteTo(io.Discard); err == nil || !strings.Contains(err.Error(), "can't be encoded") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package itermultipart
+
+import (
+	"crypto/subtle"
+	"hash"
+	"io"
+)
+
+// ChecksumReader computes the digest of the whole message while it's parsed.
+// It's the read-side counterpart of [Source.EnableStreamChecksum]: with the same hasher
+// both sides get the same digest for the same message.
+type ChecksumReader struct {
+	r      io.Reader
+	hasher hash.Hash
+}
+
+// NewChecksumReader returns a [ChecksumReader] feeding everything read from r to the hasher.
+func NewChecksumReader(r io.Reader, hasher hash.Hash) *ChecksumReader {
+	hasher.Reset()
+	return &ChecksumReader{r: r, hasher: hasher}
+}
+
+// Read implements [io.Reader].
+func (cr *ChecksumReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.hasher.Write(p[:n])
+	return n, err
+}
+
+// Checksum returns the digest of the message.
+// Parsers stop reading at the closing delimiter, so the rest of the message (epilogue) is read to the hasher first.
+func (cr *ChecksumReader) Checksum() ([]byte, error) {
+	if _, err := io.Copy(cr.hasher, cr.r); err != nil {
+		return nil, err
+	}
+	return cr.hasher.Sum(nil), nil
+}
+
+// CompareDigests reports whether digests are equal. Comparison takes constant time for digests of the same length.
+func CompareDigests(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
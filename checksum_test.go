@@ -0,0 +1,72 @@
+package itermultipart_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestChecksumReader(t *testing.T) {
+	src := itermultipart.NewSource(smallFields(100))
+	if err := src.EnableStreamChecksum(crc32.NewIEEE()); err != nil {
+		t.Fatalf("EnableStreamChecksum: unexpected error %s", err)
+	}
+
+	var message strings.Builder
+	if _, err := src.WriteTo(&message); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	sent, _ := src.StreamChecksum()
+
+	// the epilogue is not read by the parser but must be hashed
+	for _, epilogue := range []string{"", "epilogue"} {
+		cr := itermultipart.NewChecksumReader(strings.NewReader(message.String()+epilogue), crc32.NewIEEE())
+		if _, err := collectParts(t, itermultipart.NewParser(cr, src.Boundary()).Parts()); err != nil {
+			t.Fatalf("parse: unexpected error %s", err)
+		}
+		received, err := cr.Checksum()
+		if err != nil {
+			t.Fatalf("Checksum: unexpected error %s", err)
+		}
+		if itermultipart.CompareDigests(sent, received) != (epilogue == "") {
+			t.Errorf("epilogue %q: sent %x, received %x", epilogue, sent, received)
+		}
+	}
+}
+
+func ExampleChecksumReader() {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("key").SetContentString("value"),
+	))
+	src.EnableStreamChecksum(sha256.New())
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := src.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+
+	cr := itermultipart.NewChecksumReader(pr, sha256.New())
+	for part, err := range itermultipart.NewParser(cr, src.Boundary()).Parts() {
+		if err != nil {
+			panic(err)
+		}
+		content, _ := io.ReadAll(part.Content)
+		fmt.Printf("%s: %s\n", part.FormName(), content)
+	}
+
+	received, err := cr.Checksum()
+	if err != nil {
+		panic(err)
+	}
+	sent, _ := src.StreamChecksum()
+	fmt.Println("digests match:", itermultipart.CompareDigests(sent, received))
+	// Output:
+	// key: value
+	// digests match: true
+}
@@ -0,0 +1,47 @@
+package itermultipart
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"iter"
+)
+
+// Chunks splits the message generated by src into chunks of size bytes for chunked upload protocols,
+// i.e. parts of S3 multipart uploads or requests of GCS resumable and tus uploads.
+// Every chunk except the last one has exactly size bytes, no empty chunks are yielded.
+// Chunks read directly from src without copying, so a chunk is valid until the next iteration only.
+// Bytes of the chunk not read by then are skipped with [Source.SkipBytes].
+// Errors of generation are returned by chunk readers, errors occurred between chunks are yielded.
+func Chunks(src *Source, size int64) iter.Seq2[io.Reader, error] {
+	return func(yield func(io.Reader, error) bool) {
+		if size <= 0 {
+			yield(nil, errors.New("chunk size must be positive"))
+			return
+		}
+
+		var first [1]byte
+		for {
+			// the first byte tells whether there is one more chunk
+			if _, err := io.ReadFull(src, first[:]); err != nil {
+				if !errors.Is(err, io.EOF) {
+					yield(nil, err)
+				}
+				return
+			}
+
+			rest := &io.LimitedReader{R: src, N: size - 1}
+			if !yield(io.MultiReader(bytes.NewReader(first[:]), rest), nil) {
+				return
+			}
+			if rest.N > 0 {
+				if _, err := src.SkipBytes(rest.N); err != nil {
+					if !errors.Is(err, io.EOF) {
+						yield(nil, err)
+					}
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestChunks(t *testing.T) {
+	large := strings.Repeat("0123456789", 1000)
+	newSource := func() *itermultipart.Source {
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("a", "first"),
+			itermultipart.NewPart().SetFormName("b").SetContent(strings.NewReader(large)),
+		), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")))
+	}
+	var full bytes.Buffer
+	if _, err := newSource().WriteTo(&full); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for _, size := range []int64{1, 1000, int64(full.Len()), int64(full.Len()) + 1} {
+		var (
+			joined bytes.Buffer
+			chunks int
+		)
+		for chunk, err := range itermultipart.Chunks(newSource(), size) {
+			if err != nil {
+				t.Fatalf("size %d: unexpected error %s", size, err)
+			}
+			n, err := io.Copy(&joined, chunk)
+			if err != nil {
+				t.Fatalf("size %d: unexpected error %s", size, err)
+			}
+			if chunks++; n != size && joined.Len() != full.Len() {
+				t.Errorf("size %d: chunk %d has %d bytes", size, chunks, n)
+			}
+		}
+		if !bytes.Equal(joined.Bytes(), full.Bytes()) {
+			t.Errorf("size %d: joined chunks differ from the message", size)
+		}
+		if want := (full.Len() + int(size) - 1) / int(size); chunks != want {
+			t.Errorf("size %d: got %d chunks, want %d", size, chunks, want)
+		}
+	}
+
+	// unread bytes of chunks are skipped
+	var firstBytes []byte
+	for chunk, err := range itermultipart.Chunks(newSource(), 1000) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(chunk, b); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		firstBytes = append(firstBytes, b[0])
+	}
+	for i, b := range firstBytes {
+		if b != full.Bytes()[i*1000] {
+			t.Errorf("chunk %d starts with %q, want %q", i, b, full.Bytes()[i*1000])
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package itermultipart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net/url"
+	"os"
+)
+
+// defaultMaxMemory matches the default of [net/http.Request.ParseMultipartForm].
+const defaultMaxMemory = 32 << 20
+
+// valuesSizeReserve is added to the memory limit to get the default limit of values like [mime/multipart.Reader.ReadForm] does.
+const valuesSizeReserve = 10 << 20
+
+type collectOptions struct {
+	maxPartSize   int64
+	maxTotalSize  int64
+	maxValuesSize int64
+	maxMemory     int64
+	tempDir       string
+	strict        bool
+	duplicates    DuplicateNamePolicy
+	decodeCharset bool
+	charsetReader CharsetReader
+}
+
+// CollectOption configures [CollectValues] and [CollectFiles].
+type CollectOption func(*collectOptions)
+
+// WithMaxPartSize limits the content size of every collected part, [ErrPartTooLarge] is returned if it's exceeded.
+// Default is unlimited.
+func WithMaxPartSize(n int64) CollectOption {
+	return func(o *collectOptions) {
+		o.maxPartSize = n
+	}
+}
+
+// WithMaxTotalSize limits the total content size of collected parts, [ErrMessageTooLarge] is returned if it's exceeded.
+// Default is unlimited.
+func WithMaxTotalSize(n int64) CollectOption {
+	return func(o *collectOptions) {
+		o.maxTotalSize = n
+	}
+}
+
+// WithMaxValuesSize limits the total content size of collected values, [ErrMessageTooLarge] is returned if it's exceeded.
+// Default is the memory limit set by [WithMaxMemory] plus 10 MiB like for [mime/multipart.Reader.ReadForm], negative n means unlimited.
+func WithMaxValuesSize(n int64) CollectOption {
+	return func(o *collectOptions) {
+		o.maxValuesSize = n
+	}
+}
+
+// WithMaxMemory sets how many bytes of file contents are kept in memory, the rest of files is stored in temporary files.
+// Default is 32 MiB like for [net/http.Request.ParseMultipartForm].
+func WithMaxMemory(n int64) CollectOption {
+	return func(o *collectOptions) {
+		o.maxMemory = n
+	}
+}
+
+// WithTempDir sets the directory for temporary files, default is [os.TempDir].
+func WithTempDir(dir string) CollectOption {
+	return func(o *collectOptions) {
+		o.tempDir = dir
+	}
+}
+
+// WithStrictForm validates parts with [StrictFormParts] instead of skipping ones without a form name.
+// Unlike the sequence, collectors support [DuplicatesLastWins] replacing earlier values and files.
+func WithStrictForm(duplicates DuplicateNamePolicy) CollectOption {
+	return func(o *collectOptions) {
+		o.strict, o.duplicates = true, duplicates
+	}
+}
+
+// WithCharsetDecoding converts collected values to UTF-8 according to their charset parameters
+// and the "_charset_" field with [DecodeCharset], charsetReader may be nil if only UTF-8, US-ASCII and ISO-8859-1 are expected.
+func WithCharsetDecoding(charsetReader CharsetReader) CollectOption {
+	return func(o *collectOptions) {
+		o.decodeCharset, o.charsetReader = true, charsetReader
+	}
+}
+
+// CollectedFiles maps form names to file parts collected by [CollectFiles].
+type CollectedFiles map[string][]*FileHeader
+
+// RemoveAll removes temporary files of collected parts.
+func (f CollectedFiles) RemoveAll() error {
+	var errs []error
+	for _, files := range f {
+		for _, fh := range files {
+			errs = append(errs, fh.Remove())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CollectValues reads form fields, parts having a form name and no file name, into [url.Values].
+// Other parts are skipped without reading their content. Limits are set with [WithMaxPartSize], [WithMaxTotalSize]
+// and [WithMaxValuesSize]. It's a streaming replacement of [net/http.Request.ParseMultipartForm] for values.
+func CollectValues(parts iter.Seq2[*Part, error], opts ...CollectOption) (url.Values, error) {
+	c := newCollector(opts)
+	if err := c.collect(parts, true, false); err != nil {
+		return nil, err
+	}
+	return c.values, nil
+}
+
+// CollectFiles reads file parts, ones having a form name and a file name, into memory up to the limit
+// set by [WithMaxMemory] and into temporary files beyond it. Other parts are skipped without reading their content.
+// Limits are set with [WithMaxPartSize] and [WithMaxTotalSize]. Call [CollectedFiles.RemoveAll] when files are not needed,
+// on error temporary files are removed by CollectFiles.
+func CollectFiles(parts iter.Seq2[*Part, error], opts ...CollectOption) (CollectedFiles, error) {
+	c := newCollector(opts)
+	if err := c.collect(parts, false, true); err != nil {
+		c.files.RemoveAll()
+		return nil, err
+	}
+	return c.files, nil
+}
+
+// collector reads values and files from parts accounting limits.
+type collector struct {
+	collectOptions
+	total      int64 // content bytes read
+	memory     int64 // file bytes kept in memory
+	valuesSize int64 // value bytes read
+	values     url.Values
+	files      CollectedFiles
+}
+
+func newCollector(opts []CollectOption) *collector {
+	c := &collector{
+		collectOptions: collectOptions{maxMemory: defaultMaxMemory},
+		values:         make(url.Values),
+		files:          make(CollectedFiles),
+	}
+	for _, opt := range opts {
+		opt(&c.collectOptions)
+	}
+	if c.maxValuesSize == 0 {
+		c.maxValuesSize = c.maxMemory + valuesSizeReserve
+	}
+	return c
+}
+
+func (c *collector) collect(parts iter.Seq2[*Part, error], values, files bool) error {
+	if c.decodeCharset {
+		parts = DecodeCharset(parts, c.charsetReader)
+	}
+	if c.strict {
+		parts = StrictFormParts(parts, c.duplicates)
+	}
+
+	var buf bytes.Buffer
+	for part, err := range parts {
+		if err != nil {
+			return err
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+
+		isFile := part.FileName() != ""
+		switch {
+		case isFile && files:
+			fh, err := c.collectFile(part)
+			if err != nil {
+				return fmt.Errorf("file %q: %w", name, err)
+			}
+			if c.replaceDuplicates() {
+				for _, replaced := range c.files[name] {
+					replaced.Remove()
+				}
+				c.files[name] = nil
+			}
+			c.files[name] = append(c.files[name], fh)
+		case !isFile && values:
+			buf.Reset()
+			if err := c.collectValue(&buf, part.Content); err != nil {
+				return fmt.Errorf("field %q: %w", name, err)
+			}
+			if c.replaceDuplicates() {
+				c.values.Del(name)
+			}
+			c.values.Add(name, buf.String())
+		}
+	}
+	return nil
+}
+
+func (c *collector) replaceDuplicates() bool {
+	return c.strict && c.duplicates == DuplicatesLastWins
+}
+
+func (c *collector) collectFile(part *Part) (*FileHeader, error) {
+	sw := &spillWriter{memoryLimit: c.maxMemory - c.memory, dir: c.tempDir}
+	size, err := c.copyContent(sw, part.Content)
+	if sw.file != nil {
+		if closeErr := sw.file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(sw.file.Name())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fh := &FileHeader{
+		Filename: part.FileName(),
+		Header:   maps.Clone(part.Header),
+		Size:     size,
+	}
+	if sw.file != nil {
+		fh.tmpfile = sw.file.Name()
+	} else {
+		fh.content = sw.buf.Bytes()
+		c.memory += size
+	}
+	return fh, nil
+}
+
+// collectValue reads the value content to buf enforcing the limit of values besides other limits.
+func (c *collector) collectValue(buf *bytes.Buffer, content io.Reader) error {
+	if c.maxValuesSize < 0 || content == nil {
+		_, err := c.copyContent(buf, content)
+		return err
+	}
+
+	n, err := c.copyContent(buf, io.LimitReader(content, c.maxValuesSize-c.valuesSize+1))
+	c.valuesSize += n
+	if err == nil && c.valuesSize > c.maxValuesSize {
+		err = fmt.Errorf("%w: values exceed %d bytes", ErrMessageTooLarge, c.maxValuesSize)
+	}
+	return err
+}
+
+// copyContent copies the part content to dst enforcing size limits.
+func (c *collector) copyContent(dst io.Writer, content io.Reader) (int64, error) {
+	if content == nil {
+		return 0, nil
+	}
+
+	limit, limitErr := int64(-1), ErrPartTooLarge
+	if c.maxPartSize > 0 {
+		limit = c.maxPartSize
+	}
+	if c.maxTotalSize > 0 && (limit < 0 || c.maxTotalSize-c.total < limit) {
+		limit, limitErr = c.maxTotalSize-c.total, ErrMessageTooLarge
+	}
+	if limit >= 0 {
+		content = io.LimitReader(content, limit+1)
+	}
+
+	n, err := io.Copy(dst, content)
+	c.total += n
+	if err == nil && limit >= 0 && n > limit {
+		err = limitErr
+	}
+	return n, err
+}
+
+// spillWriter keeps written data in memory until the limit is exceeded and moves it to a temporary file then.
+type spillWriter struct {
+	buf         bytes.Buffer
+	memoryLimit int64
+	dir         string
+	file        *os.File
+}
+
+func (sw *spillWriter) Write(p []byte) (int, error) {
+	if sw.file == nil && int64(sw.buf.Len()+len(p)) <= sw.memoryLimit {
+		return sw.buf.Write(p)
+	}
+	if sw.file == nil {
+		file, err := os.CreateTemp(sw.dir, "multipart-")
+		if err != nil {
+			return 0, err
+		}
+		sw.file = file
+		if _, err := sw.buf.WriteTo(file); err != nil {
+			return 0, err
+		}
+	}
+	return sw.file.Write(p)
+}
@@ -0,0 +1,119 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func formParts() []*itermultipart.Part {
+	return []*itermultipart.Part{
+		itermultipart.NewFieldPart("a", "1"),
+		itermultipart.NewFieldPart("a", "2"),
+		itermultipart.NewPart().SetFormName("small").SetFileName("small.txt").SetContentString("small file"),
+		itermultipart.NewPart().SetFormName("large").SetFileName("large.txt").SetContentString(strings.Repeat("x", 1000)),
+		itermultipart.NewPart().SetInline().SetContentString("not a form field"),
+	}
+}
+
+func TestCollectValues(t *testing.T) {
+	values, err := itermultipart.CollectValues(itermultipart.PartSeq(formParts()...))
+	if err != nil {
+		t.Fatalf("CollectValues: unexpected error %s", err)
+	}
+	if got := values.Encode(); got != "a=1&a=2" {
+		t.Errorf("got values %s, want a=1&a=2", got)
+	}
+
+	_, err = itermultipart.CollectValues(itermultipart.PartSeq(itermultipart.NewFieldPart("a", "long value")),
+		itermultipart.WithMaxPartSize(5))
+	if !errors.Is(err, itermultipart.ErrPartTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrPartTooLarge)
+	}
+
+	_, err = itermultipart.CollectValues(itermultipart.PartSeq(formParts()...),
+		itermultipart.WithMaxPartSize(5), itermultipart.WithMaxTotalSize(1))
+	if !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+
+	_, err = itermultipart.CollectValues(itermultipart.PartSeq(formParts()...), itermultipart.WithMaxValuesSize(1))
+	if !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+
+	// values are limited by default even without the total limit
+	_, err = itermultipart.CollectValues(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "1"),
+		itermultipart.NewPart().SetFormName("b").SetContent(io.LimitReader(zeroReader{}, 1<<30)),
+	), itermultipart.WithMaxMemory(1<<20))
+	if !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	dir := t.TempDir()
+	files, err := itermultipart.CollectFiles(itermultipart.PartSeq(formParts()...),
+		itermultipart.WithMaxMemory(100), itermultipart.WithTempDir(dir))
+	if err != nil {
+		t.Fatalf("CollectFiles: unexpected error %s", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+
+	tempFiles, _ := os.ReadDir(dir)
+	if len(tempFiles) != 1 {
+		t.Errorf("got %d temporary files, want 1 for the large file", len(tempFiles))
+	}
+	for name, want := range map[string]string{"small": "small file", "large": strings.Repeat("x", 1000)} {
+		fh := files[name][0]
+		if fh.Filename != name+".txt" || fh.Size != int64(len(want)) {
+			t.Errorf("%s: got file %q of %d bytes", name, fh.Filename, fh.Size)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			t.Fatalf("%s: Open: unexpected error %s", name, err)
+		}
+		content, _ := io.ReadAll(f)
+		f.Close()
+		if string(content) != want {
+			t.Errorf("%s: got content %.20q", name, content)
+		}
+	}
+
+	if err := files.RemoveAll(); err != nil {
+		t.Errorf("RemoveAll: unexpected error %s", err)
+	}
+	if tempFiles, _ := os.ReadDir(dir); len(tempFiles) != 0 {
+		t.Errorf("got %d temporary files after RemoveAll", len(tempFiles))
+	}
+
+	_, err = itermultipart.CollectFiles(itermultipart.PartSeq(formParts()...),
+		itermultipart.WithMaxMemory(0), itermultipart.WithTempDir(dir), itermultipart.WithMaxTotalSize(500))
+	if !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+	if tempFiles, _ := os.ReadDir(dir); len(tempFiles) != 0 {
+		t.Errorf("got %d temporary files after error", len(tempFiles))
+	}
+}
+
+func TestCollectValuesCharsetDecoding(t *testing.T) {
+	parts := itermultipart.PartSeq(
+		itermultipart.NewFieldPart("_charset_", "ISO-8859-1"),
+		itermultipart.NewFieldPart("a", string([]byte{'c', 'a', 'f', 0xe9})),
+	)
+	values, err := itermultipart.CollectValues(parts, itermultipart.WithCharsetDecoding(nil))
+	if err != nil {
+		t.Fatalf("CollectValues: unexpected error %s", err)
+	}
+	if got := values.Get("a"); got != "café" {
+		t.Errorf("got value %q, want %q", got, "café")
+	}
+}
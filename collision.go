@@ -0,0 +1,132 @@
+package itermultipart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrBoundaryCollision is returned when a part content contains the boundary, so the message would be corrupted.
+var ErrBoundaryCollision = errors.New("content contains the boundary")
+
+// maxBoundaryAttempts limits boundary regeneration by [Source.EnsureBoundary].
+const maxBoundaryAttempts = 10
+
+// WithBoundaryCollisionCheck makes [Source] scan every part content for the boundary before writing the part
+// and fail with [ErrBoundaryCollision] instead of generating a corrupted message.
+// Only in-memory and seekable contents are scanned, seekable ones are read and rewound to the initial offset.
+// Random boundaries practically never collide, the check is useful for boundaries set explicitly
+// or for untrusted contents crafted to contain the boundary. See also [Source.EnsureBoundary].
+func WithBoundaryCollisionCheck() SourceOption {
+	return func(s *Source) {
+		s.checkCollisions = true
+	}
+}
+
+// EnsureBoundary scans in-memory and seekable contents of all parts for the boundary and generates a new boundary
+// while it collides with any of them. [ErrBoundaryCollision] is returned if no suitable boundary was generated.
+// Like [Source.ContentLength] it iterates the part sequence, so the sequence must support multiple iterations,
+// and it must be called before reading from the [Source].
+func (s *Source) EnsureBoundary() error {
+	if s.pull != nil || s.firstHeadingWritten {
+		return errors.New("boundary can't be changed after reading started")
+	}
+
+	for range maxBoundaryAttempts {
+		if s.boundaryErr != nil {
+			return s.boundaryErr
+		}
+		collides, err := s.partsContainBoundary()
+		if err != nil || !collides {
+			return err
+		}
+		s.populateRandomBoundary()
+	}
+	return fmt.Errorf("%w after %d attempts", ErrBoundaryCollision, maxBoundaryAttempts)
+}
+
+func (s *Source) partsContainBoundary() (bool, error) {
+	for part, err := range s.parts {
+		if err != nil {
+			return false, err
+		}
+		if !part.included(s.context()) {
+			continue
+		}
+		collides, err := s.contentContainsBoundary(part)
+		if err != nil || collides {
+			return collides, err
+		}
+	}
+	return false, nil
+}
+
+// contentContainsBoundary reports whether the content of the part contains the boundary.
+// Contents which can't be scanned without consuming them are reported as not colliding.
+func (s *Source) contentContainsBoundary(part *Part) (bool, error) {
+	if part.transferEncoding == TransferEncodingBase64 {
+		return false, nil // base64 alphabet has no dashes
+	}
+	if part.contentEncoding != "" {
+		return false, nil // compressed content can't be scanned without compressing it
+	}
+
+	needle := []byte("--" + s.boundary)
+	switch c := part.Content.(type) {
+	case *bytes.Buffer:
+		return bytes.Contains(c.Bytes(), needle), nil
+	case *Source:
+		return false, nil // nested messages use their own boundaries
+	case io.ReadSeeker:
+		offset, err := c.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return false, err
+		}
+		found, err := readerContains(c, needle)
+		if _, seekErr := c.Seek(offset, io.SeekStart); err == nil {
+			err = seekErr
+		}
+		return found, err
+	default:
+		return false, nil
+	}
+}
+
+// checkCollision fails if the content of the started part contains the boundary and collision checks are enabled.
+func (s *Source) checkCollision(part *Part) error {
+	if !s.checkCollisions {
+		return nil
+	}
+	collides, err := s.contentContainsBoundary(part)
+	if err == nil && collides {
+		err = fmt.Errorf("part %d: %w", s.partIndex-1, ErrBoundaryCollision)
+	}
+	if err != nil {
+		s.finishPart()
+	}
+	return err
+}
+
+// readerContains reports whether the needle occurs in the data read from r.
+func readerContains(r io.Reader, needle []byte) (bool, error) {
+	buf := make([]byte, max(4<<10, 2*len(needle)))
+	kept := 0 // tail of the previous chunk which may start the needle
+	for {
+		n, err := r.Read(buf[kept:])
+		if bytes.Contains(buf[:kept+n], needle) {
+			return true, nil
+		}
+		if kept+n >= len(needle) {
+			kept = copy(buf, buf[kept+n-len(needle)+1:kept+n])
+		} else {
+			kept += n
+		}
+		switch {
+		case errors.Is(err, io.EOF):
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+	}
+}
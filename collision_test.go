@@ -0,0 +1,83 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestSourceWithBoundaryCollisionCheck(t *testing.T) {
+	// the boundary crosses the border of scanned chunks
+	colliding := strings.Repeat("x", 4090) + "\r\n--boundary\r\n" + strings.Repeat("x", 10)
+	consumers := map[string]func(*itermultipart.Source) error{
+		"Read": func(src *itermultipart.Source) error {
+			_, err := io.Copy(io.Discard, struct{ io.Reader }{src})
+			return err
+		},
+		"WriteTo": func(src *itermultipart.Source) error {
+			_, err := src.WriteTo(io.Discard)
+			return err
+		},
+	}
+	contents := map[string]func(string) *itermultipart.Part{
+		"seekable": func(s string) *itermultipart.Part { return itermultipart.NewPart().SetContentString(s) },
+		"buffer":   func(s string) *itermultipart.Part { return itermultipart.NewPart().SetContent(bytes.NewBufferString(s)) },
+	}
+
+	for consumerName, consume := range consumers {
+		for contentName, newPart := range contents {
+			src := itermultipart.NewSource(itermultipart.PartSeq(
+				newPart("safe"), newPart(colliding),
+			), itermultipart.WithBoundaryCollisionCheck())
+			if err := src.SetBoundary("boundary"); err != nil {
+				t.Fatalf("SetBoundary: unexpected error %s", err)
+			}
+			if err := consume(src); !errors.Is(err, itermultipart.ErrBoundaryCollision) || !strings.Contains(err.Error(), "part 1") {
+				t.Errorf("%s %s: got error %v, want %s of part 1", consumerName, contentName, err, itermultipart.ErrBoundaryCollision)
+			}
+		}
+	}
+
+	// scanned contents are rewound
+	content := strings.Repeat("y", 10<<10)
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentString(content),
+	), itermultipart.WithBoundaryCollisionCheck())
+	var sb strings.Builder
+	if _, err := src.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if !strings.Contains(sb.String(), "\r\n\r\n"+content+"\r\n--") {
+		t.Error("content is not written completely")
+	}
+}
+
+func TestSourceEnsureBoundary(t *testing.T) {
+	parts := itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentString("contains --b1 and --b2"),
+	)
+	src := itermultipart.NewSource(parts, itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")))
+	if err := src.EnsureBoundary(); err != nil {
+		t.Fatalf("EnsureBoundary: unexpected error %s", err)
+	}
+	if got := src.Boundary(); got != "b3" {
+		t.Errorf("got boundary %q, want b3", got)
+	}
+
+	src = itermultipart.NewSource(parts, itermultipart.WithBoundaryFunc(func() (string, error) { return "b1", nil }))
+	if err := src.EnsureBoundary(); !errors.Is(err, itermultipart.ErrBoundaryCollision) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrBoundaryCollision)
+	}
+
+	src = itermultipart.NewSource(parts)
+	if _, err := src.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Read: unexpected error %s", err)
+	}
+	if err := src.EnsureBoundary(); err == nil {
+		t.Error("EnsureBoundary: expected error after reading started")
+	}
+}
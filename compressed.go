@@ -0,0 +1,98 @@
+package itermultipart
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// CompressedSource compresses the whole multipart message generated by the [Source] while it streams,
+// i.e. to upload many small text parts over a slow link. See [Part.SetContentEncoding] to compress single parts.
+type CompressedSource struct {
+	src      *Source
+	encoding string
+	r        io.Reader
+}
+
+// NewCompressedSource returns the [CompressedSource] compressing the message with the encoding,
+// [ContentEncodingGzip] or [ContentEncodingDeflate].
+func NewCompressedSource(src *Source, encoding string) (*CompressedSource, error) {
+	encoding = strings.ToLower(encoding)
+	r, ok := compressingReader(src, encoding)
+	if !ok {
+		return nil, fmt.Errorf("multipart: unsupported content encoding %q", encoding)
+	}
+	return &CompressedSource{src: src, encoding: encoding, r: r}, nil
+}
+
+// Read reads the compressed message.
+func (c *CompressedSource) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Close closes the underlying [Source].
+func (c *CompressedSource) Close() error {
+	return c.src.Close()
+}
+
+// Rewind rewinds the underlying [Source], see [Source.Rewind], so the message is compressed again from the beginning.
+func (c *CompressedSource) Rewind() error {
+	if err := c.src.Rewind(); err != nil {
+		return err
+	}
+	c.r, _ = compressingReader(c.src, c.encoding)
+	return nil
+}
+
+// Source returns the underlying [Source], i.e. to get its boundary.
+func (c *CompressedSource) Source() *Source {
+	return c.src
+}
+
+// ContentEncoding returns the value of the "Content-Encoding" header of the compressed message.
+func (c *CompressedSource) ContentEncoding() string {
+	return c.encoding
+}
+
+// Header returns headers to send with the compressed message: the given Content-Type of the original message,
+// i.e. [Source.FormDataContentType], and Content-Encoding.
+func (c *CompressedSource) Header(contentType string) http.Header {
+	return http.Header{
+		contentTypeHeader:     {contentType},
+		contentEncodingHeader: {c.encoding},
+	}
+}
+
+// NewCompressedRequest is like [NewRequest] but compresses the body with the encoding, see [NewCompressedSource].
+// Content length of the request is unknown. GetBody is set if the [Source] can be cloned like for [NewRequest].
+func NewCompressedRequest(ctx context.Context, method, url string, src *Source, encoding string) (*http.Request, error) {
+	if src.boundaryErr != nil {
+		return nil, src.boundaryErr
+	}
+	cs, err := NewCompressedSource(src, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, cs)
+	if err != nil {
+		return nil, err
+	}
+
+	src.ctx = ctx // for part conditions
+	for k, v := range cs.Header(src.FormDataContentType()) {
+		req.Header[k] = v
+	}
+	if rewindable(src) {
+		req.GetBody = func() (io.ReadCloser, error) {
+			clone, err := src.Clone()
+			if err != nil {
+				return nil, err
+			}
+			return NewCompressedSource(clone, encoding)
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,63 @@
+package itermultipart_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestNewCompressedRequest(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enc := r.Header.Get("Content-Encoding"); enc != "gzip" {
+			t.Errorf("got Content-Encoding %q", enc)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("gzip: unexpected error %s", err)
+			return
+		}
+		r.Body = io.NopCloser(zr)
+		r.Header.Del("Content-Encoding")
+		for part, err := range itermultipart.PartsFromRequest(r, true) {
+			if err != nil {
+				t.Errorf("unexpected error %s", err)
+				return
+			}
+			content, _ := io.ReadAll(part.Content)
+			got = append(got, part.FormName()+"="+string(content))
+		}
+	}))
+	defer srv.Close()
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", strings.Repeat("1", 100)),
+		itermultipart.NewFieldPart("b", "2"),
+	), itermultipart.WithReplayableParts())
+	req, err := itermultipart.NewCompressedRequest(context.Background(), http.MethodPost, srv.URL, src, "gzip")
+	if err != nil {
+		t.Fatalf("NewCompressedRequest: unexpected error %s", err)
+	}
+	if req.GetBody == nil {
+		t.Error("GetBody is not set")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: unexpected error %s", err)
+	}
+	resp.Body.Close()
+
+	if want := "a=" + strings.Repeat("1", 100) + " b=2"; strings.Join(got, " ") != want {
+		t.Errorf("got fields %q, want %q", strings.Join(got, " "), want)
+	}
+
+	if _, err := itermultipart.NewCompressedSource(src, "br"); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+}
@@ -0,0 +1,49 @@
+package itermultipart
+
+import (
+	"context"
+	"iter"
+)
+
+// ConditionalPart makes the part included into the message only if the predicate holds.
+// Predicate is evaluated by [Source] when it reaches the part, so the same part sequence may produce different messages.
+// Context passed to the predicate is the one provided to [NewRequest] or [context.Background] otherwise.
+// Length of messages with conditional parts is unknown, see [Source.ContentLength].
+// Conditions are ignored by [Part.AddToWriter].
+func ConditionalPart(pred func(ctx context.Context) bool, part *Part) *Part {
+	if prev := part.condition; prev != nil {
+		part.condition = func(ctx context.Context) bool {
+			return prev(ctx) && pred(ctx)
+		}
+	} else {
+		part.condition = pred
+	}
+	return part
+}
+
+// ConditionalSeq makes all parts of the sequence included into the message only if the predicate holds.
+// Predicate is evaluated for every part of the sequence, see [ConditionalPart].
+// Parts are not modified permanently: the condition is attached only while the part is yielded.
+func ConditionalSeq(pred func(ctx context.Context) bool, parts iter.Seq2[*Part, error]) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			prev := part.condition
+			next := yield(ConditionalPart(pred, part), nil)
+			part.condition = prev
+			if !next {
+				return
+			}
+		}
+	}
+}
+
+func (p *Part) included(ctx context.Context) bool {
+	return p.condition == nil || p.condition(ctx)
+}
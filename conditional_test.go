@@ -0,0 +1,93 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+type featureKey struct{}
+
+func featureEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(featureKey{}).(bool)
+	return enabled
+}
+
+func TestConditionalParts(t *testing.T) {
+	always := func(context.Context) bool { return true }
+	never := func(context.Context) bool { return false }
+
+	parts := itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("plain").SetContentString("1"),
+		itermultipart.ConditionalPart(always, itermultipart.NewPart().SetFormName("always").SetContentString("2")),
+		itermultipart.ConditionalPart(never, itermultipart.NewPart().SetFormName("never").SetContentString("3")),
+		itermultipart.ConditionalPart(always, itermultipart.ConditionalPart(never, itermultipart.NewPart().SetFormName("both").SetContentString("4"))),
+	)
+	parts = itermultipart.ConditionalSeq(always, parts)
+
+	for _, write := range []bool{false, true} {
+		src := itermultipart.NewSource(parts, itermultipart.WithReplayableParts())
+		if length, ok := src.ContentLength(); ok {
+			t.Errorf("ContentLength = %d; want unknown for conditional parts", length)
+		}
+
+		var b bytes.Buffer
+		var err error
+		if write {
+			_, err = src.WriteTo(&b)
+		} else {
+			_, err = b.ReadFrom(src)
+		}
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		r := multipart.NewReader(&b, src.Boundary())
+		var names []string
+		for part, err := range itermultipart.PartsFromReader(r, false) {
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			names = append(names, part.FormName())
+		}
+		if g, e := fmt.Sprint(names), "[plain always]"; g != e {
+			t.Errorf("write=%v: got parts %s; want %s", write, g, e)
+		}
+	}
+
+	src := itermultipart.NewSource(itermultipart.ConditionalSeq(never, parts))
+	var b bytes.Buffer
+	if _, err := src.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if g, e := b.Len(), len(src.Boundary())+8; g != e {
+		t.Errorf("ConditionalSeq: expected empty message of %d bytes, got %d", e, g)
+	}
+}
+
+func ExampleConditionalPart() {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("key").SetContentString("val"),
+		itermultipart.ConditionalPart(featureEnabled, itermultipart.NewPart().SetFormName("beta").SetContentString("on")),
+	))
+	src.SetBoundary("boundary")
+
+	ctx := context.WithValue(context.Background(), featureKey{}, true)
+	req, err := itermultipart.NewRequest(ctx, "POST", "http://example.com/upload", src)
+	if err != nil {
+		panic(err)
+	}
+
+	for part, err := range itermultipart.PartsFromRequest(req, false) {
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(part.FormName())
+	}
+	// Output:
+	// key
+	// beta
+}
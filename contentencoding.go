@@ -0,0 +1,119 @@
+package itermultipart
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"iter"
+	"strings"
+)
+
+const contentEncodingHeader = "Content-Encoding"
+
+// Content encodings supported by [Part.SetContentEncoding].
+const (
+	ContentEncodingGzip    = "gzip"
+	ContentEncodingDeflate = "deflate" // zlib format like in HTTP
+)
+
+// SetContentEncoding sets the "Content-Encoding" header of the part and makes [Source]
+// and [Part.AddToWriter] compress the content while it streams, before the transfer encoding is applied.
+// Content must be set uncompressed. Other encodings only set the header.
+// Content length of the message with compressed parts is unknown.
+// Parts read from a message are never compressed again, even if they have the header.
+func (p *Part) SetContentEncoding(encoding string) *Part {
+	p.contentEncoding = strings.ToLower(encoding)
+	return p.SetHeaderValue(contentEncodingHeader, p.contentEncoding)
+}
+
+// compresses reports whether the content is compressed by the Source.
+func (p *Part) compresses() bool {
+	return p.contentEncoding == ContentEncodingGzip || p.contentEncoding == ContentEncodingDeflate
+}
+
+// compressedContent returns the content compressed according to the content encoding.
+func (p *Part) compressedContent(content io.Reader) io.Reader {
+	if compressed, ok := compressingReader(content, p.contentEncoding); ok {
+		return compressed
+	}
+	return content
+}
+
+// compressingReader returns the reader compressing r with the encoding, reports false if the encoding is not supported.
+func compressingReader(r io.Reader, encoding string) (io.Reader, bool) {
+	er := &encodingReader{src: r}
+	switch encoding {
+	case ContentEncodingGzip:
+		er.enc = gzip.NewWriter(&er.buf)
+	case ContentEncodingDeflate:
+		er.enc = zlib.NewWriter(&er.buf)
+	default:
+		return nil, false
+	}
+	return er, true
+}
+
+// DecodeContentEncoding decompresses contents of the parts according to their "Content-Encoding" header.
+// Gzip and deflate (zlib) contents are decompressed and the header is removed,
+// parts with other encodings are yielded untouched so the caller can handle them.
+// Decompression errors, including malformed compressed data headers, are returned by content reads.
+// Parts are not modified permanently: the content and the header are restored after the part is yielded.
+func DecodeContentEncoding(parts iter.Seq2[*Part, error]) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			encoding := part.Header.Get(contentEncodingHeader)
+			if part.contentEncoding != "" {
+				encoding = "" // content is not compressed yet, see SetContentEncoding
+			}
+
+			content := part.Content
+			var newReader func(r io.Reader) (io.Reader, error)
+			switch strings.ToLower(strings.TrimSpace(encoding)) {
+			case ContentEncodingGzip, "x-gzip":
+				newReader = func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+			case ContentEncodingDeflate:
+				newReader = func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }
+			}
+			if newReader == nil || content == nil {
+				if !yield(part, nil) {
+					return
+				}
+				continue
+			}
+
+			part.Content = &decompressingReader{src: content, newReader: newReader}
+			part.Header.Del(contentEncodingHeader)
+			next := yield(part, nil)
+			part.Content = content
+			part.Header.Set(contentEncodingHeader, encoding)
+			if !next {
+				return
+			}
+		}
+	}
+}
+
+// decompressingReader creates the decompressor on the first read, as creating it reads the compressed data header.
+type decompressingReader struct {
+	src       io.Reader
+	newReader func(r io.Reader) (io.Reader, error)
+	r         io.Reader
+	err       error
+}
+
+func (dr *decompressingReader) Read(p []byte) (int, error) {
+	if dr.r == nil && dr.err == nil {
+		dr.r, dr.err = dr.newReader(dr.src)
+	}
+	if dr.err != nil {
+		return 0, dr.err
+	}
+	return dr.r.Read(p)
+}
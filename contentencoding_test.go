@@ -0,0 +1,79 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestPartSetContentEncoding(t *testing.T) {
+	content := strings.Repeat("compressible text ", 1000)
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("gzip").SetContentEncoding(itermultipart.ContentEncodingGzip).SetContentString(content),
+		itermultipart.NewPart().SetFormName("deflate").SetContentEncoding(itermultipart.ContentEncodingDeflate).
+			SetTransferEncoding(itermultipart.TransferEncodingBase64).SetContentString(content),
+		itermultipart.NewPart().SetFormName("plain").SetContentString(content),
+	))
+	if _, ok := src.ContentLength(); ok {
+		t.Error("content length of compressed parts must be unknown")
+	}
+
+	var message bytes.Buffer
+	if _, err := src.WriteTo(&message); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if message.Len() > 2*len(content) {
+		t.Errorf("message of %d bytes is not compressed", message.Len())
+	}
+
+	parts := itermultipart.NewParser(&message, src.Boundary()).Parts()
+	for part, err := range itermultipart.DecodeContentEncoding(itermultipart.DecodeTransferEncoding(parts)) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if enc := part.Header.Get("Content-Encoding"); enc != "" {
+			t.Errorf("%s: Content-Encoding %q is not removed", part.FormName(), enc)
+		}
+		got, err := io.ReadAll(part.Content)
+		if err != nil {
+			t.Fatalf("%s: reading content: unexpected error %s", part.FormName(), err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: content mismatch", part.FormName())
+		}
+	}
+}
+
+func TestDecodeContentEncoding(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("content"))
+	zw.Close()
+
+	part := itermultipart.NewPart().SetHeaderValue("Content-Encoding", "gzip").SetContentBytes(compressed.Bytes())
+	broken := itermultipart.NewPart().SetHeaderValue("Content-Encoding", "gzip").SetContentString("not gzip")
+	unknown := itermultipart.NewPart().SetHeaderValue("Content-Encoding", "br").SetContentString("brotli")
+
+	var got []string
+	for p, err := range itermultipart.DecodeContentEncoding(itermultipart.PartSeq(part, broken, unknown)) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		content, err := io.ReadAll(p.Content)
+		if err != nil {
+			got = append(got, "error")
+			continue
+		}
+		got = append(got, p.Header.Get("Content-Encoding")+":"+string(content))
+	}
+	if want := ":content error br:brotli"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+	if part.Header.Get("Content-Encoding") != "gzip" {
+		t.Error("header is not restored")
+	}
+}
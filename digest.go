@@ -0,0 +1,201 @@
+package itermultipart
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// DigestAlgorithm is the content digest algorithm named like in the RFC 9530 hash algorithm registry.
+type DigestAlgorithm string
+
+// Digest algorithms supported by [WithPartDigests] and [Part.SetDigestHeaders].
+const (
+	DigestMD5    DigestAlgorithm = "md5"
+	DigestSHA256 DigestAlgorithm = "sha-256"
+	DigestSHA512 DigestAlgorithm = "sha-512"
+)
+
+func (a DigestAlgorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case DigestMD5:
+		return md5.New(), nil
+	case DigestSHA256:
+		return sha256.New(), nil
+	case DigestSHA512:
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", string(a))
+	}
+}
+
+// WithPartDigests makes [Source] compute digests of every part content while it streams,
+// they are available in [Stats.PartDigests] once the part is written. Digests are computed
+// over the content before compression and transfer encoding. Unsupported algorithms are ignored.
+// See [Part.SetDigestHeaders] to send digests along with parts.
+func WithPartDigests(algorithms ...DigestAlgorithm) SourceOption {
+	return func(s *Source) {
+		s.digestAlgorithms = nil
+		for _, a := range algorithms {
+			if _, err := a.newHash(); err == nil {
+				s.digestAlgorithms = append(s.digestAlgorithms, a)
+			}
+		}
+	}
+}
+
+// partContent returns the content of the part as it must be written to the message hashing it if necessary.
+func (s *Source) partContent(part *Part) io.Reader {
+	content := part.Content
+	if len(s.digestAlgorithms) > 0 {
+		s.partHashers = make([]hash.Hash, len(s.digestAlgorithms))
+		writers := make([]io.Writer, len(s.digestAlgorithms))
+		for i, a := range s.digestAlgorithms {
+			s.partHashers[i], _ = a.newHash()
+			writers[i] = s.partHashers[i]
+		}
+		if content != nil {
+			content = io.TeeReader(content, io.MultiWriter(writers...))
+		}
+	}
+	return part.encodedContent(content)
+}
+
+// finishDigests saves digests of the current part to stats.
+func (s *Source) finishDigests() {
+	if s.partHashers == nil {
+		return
+	}
+	digests := make(map[DigestAlgorithm][]byte, len(s.partHashers))
+	for i, h := range s.partHashers {
+		digests[s.digestAlgorithms[i]] = h.Sum(nil)
+	}
+	s.stats.PartDigests = append(s.stats.PartDigests, digests)
+	s.partHashers = nil
+}
+
+// SetDigestHeaders computes digests of the part content and sets the RFC 9530 "Repr-Digest" header,
+// the legacy RFC 3230 "Digest" header and "Content-MD5" if [DigestMD5] is requested,
+// i.e. for S3-compatible and other integrity-sensitive APIs.
+// The content must be seekable, like ones set with [Part.SetContentBytes] or [Part.SetContentString],
+// it's read to the end and sought back to the current position. Digests are computed over the content
+// before compression and transfer encoding.
+func (p *Part) SetDigestHeaders(algorithms ...DigestAlgorithm) error {
+	hashers := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, a := range algorithms {
+		h, err := a.newHash()
+		if err != nil {
+			return err
+		}
+		hashers[i], writers[i] = h, h
+	}
+
+	if p.contentFactory != nil {
+		return errors.New("content is opened only when it's written")
+	}
+	if p.Content != nil {
+		seeker, ok := p.Content.(io.ReadSeeker)
+		if !ok {
+			return errors.New("content is not seekable")
+		}
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.MultiWriter(writers...), seeker); err != nil {
+			return err
+		}
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	var reprDigest, digest []string
+	for i, a := range algorithms {
+		sum := base64.StdEncoding.EncodeToString(hashers[i].Sum(nil))
+		reprDigest = append(reprDigest, string(a)+"=:"+sum+":")
+		digest = append(digest, strings.ToUpper(string(a))+"="+sum)
+		if a == DigestMD5 {
+			p.SetHeaderValue("Content-Md5", sum)
+		}
+	}
+	if len(algorithms) > 0 {
+		p.SetHeaderValue("Repr-Digest", strings.Join(reprDigest, ", "))
+		p.SetHeaderValue("Digest", strings.Join(digest, ", "))
+	}
+	return nil
+}
+
+// WithBodyDigests makes [Source] compute digests of the whole message exactly as it's generated,
+// they are available in [Stats.BodyDigests] once the message is finished without skipping bytes, i.e. to set an ETag
+// or to sign the upload without a second pass over the body. Unsupported algorithms are ignored.
+// Unlike [Source.EnableStreamChecksum] it takes several algorithms and is kept by [Source.Clone].
+func WithBodyDigests(algorithms ...DigestAlgorithm) SourceOption {
+	return func(s *Source) {
+		s.bodyAlgorithms = nil
+		for _, a := range algorithms {
+			if _, err := a.newHash(); err == nil {
+				s.bodyAlgorithms = append(s.bodyAlgorithms, a)
+			}
+		}
+	}
+}
+
+// bodyWriter returns the writer hashing the message with body digests and the stream checksum
+// or nil if neither is enabled.
+func (s *Source) bodyWriter() io.Writer {
+	if len(s.bodyAlgorithms) == 0 && s.checksum == nil {
+		return nil
+	}
+	if s.bodyHashers == nil {
+		s.bodyHashers = make([]hash.Hash, len(s.bodyAlgorithms))
+		for i, a := range s.bodyAlgorithms {
+			s.bodyHashers[i], _ = a.newHash()
+		}
+	}
+	writers := make([]io.Writer, 0, len(s.bodyHashers)+1)
+	for _, h := range s.bodyHashers {
+		writers = append(writers, h)
+	}
+	if s.checksum != nil {
+		writers = append(writers, s.checksum)
+	}
+	return io.MultiWriter(writers...)
+}
+
+// finishBodyDigests saves digests of the message to stats and the stream checksum.
+// Digests of the message with skipped bytes are not saved, they would describe only a part of it.
+func (s *Source) finishBodyDigests() {
+	if s.skipped {
+		return
+	}
+	if s.checksum != nil {
+		s.checksumSum = s.checksum.Sum(nil)
+	}
+	if len(s.bodyAlgorithms) == 0 {
+		return
+	}
+	s.bodyWriter() // the message may be empty
+	s.stats.BodyDigests = make(map[DigestAlgorithm][]byte, len(s.bodyHashers))
+	for i, h := range s.bodyHashers {
+		s.stats.BodyDigests[s.bodyAlgorithms[i]] = h.Sum(nil)
+	}
+}
+
+// ETag returns the strong entity tag made of the first body digest enabled with [WithBodyDigests].
+// It returns false if body digests are not enabled, the message is not generated completely yet
+// or bytes of it were skipped with [Source.SkipBytes].
+func (s *Source) ETag() (string, bool) {
+	if len(s.bodyAlgorithms) == 0 || s.stats.BodyDigests == nil {
+		return "", false
+	}
+	return `"` + hex.EncodeToString(s.stats.BodyDigests[s.bodyAlgorithms[0]]) + `"`, true
+}
@@ -0,0 +1,134 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestWithPartDigests(t *testing.T) {
+	large := strings.Repeat("large content ", 10000)
+	newSource := func() *itermultipart.Source {
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("small", "small content"),
+			itermultipart.NewPart().SetFormName("large").SetContent(iotest.HalfReader(strings.NewReader(large))),
+			itermultipart.NewPart().SetFormName("encoded").SetContentEncoding("gzip").SetContentString("encoded"),
+		), itermultipart.WithPartDigests(itermultipart.DigestMD5, itermultipart.DigestSHA256))
+	}
+	want := []string{"small content", large, "encoded"}
+
+	generate := map[string]func(src *itermultipart.Source) error{
+		"WriteTo": func(src *itermultipart.Source) error { _, err := src.WriteTo(io.Discard); return err },
+		"Read": func(src *itermultipart.Source) error {
+			_, err := io.Copy(io.Discard, struct{ io.Reader }{src})
+			return err
+		},
+	}
+	for name, gen := range generate {
+		src := newSource()
+		if err := gen(src); err != nil {
+			t.Fatalf("%s: unexpected error %s", name, err)
+		}
+
+		digests := src.Stats().PartDigests
+		if len(digests) != len(want) {
+			t.Fatalf("%s: got %d part digests, want %d", name, len(digests), len(want))
+		}
+		for i, content := range want {
+			md5Sum, sha256Sum := md5.Sum([]byte(content)), sha256.Sum256([]byte(content))
+			if !bytes.Equal(digests[i][itermultipart.DigestMD5], md5Sum[:]) {
+				t.Errorf("%s: part %d: MD5 mismatch", name, i)
+			}
+			if !bytes.Equal(digests[i][itermultipart.DigestSHA256], sha256Sum[:]) {
+				t.Errorf("%s: part %d: SHA-256 mismatch", name, i)
+			}
+		}
+	}
+}
+
+func TestPartSetDigestHeaders(t *testing.T) {
+	part := itermultipart.NewFieldPart("a", "content")
+	if err := part.SetDigestHeaders(itermultipart.DigestMD5, itermultipart.DigestSHA256); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	md5Sum, sha256Sum := md5.Sum([]byte("content")), sha256.Sum256([]byte("content"))
+	md5B64, sha256B64 := base64.StdEncoding.EncodeToString(md5Sum[:]), base64.StdEncoding.EncodeToString(sha256Sum[:])
+
+	for key, want := range map[string]string{
+		"Content-MD5": md5B64,
+		"Repr-Digest": "md5=:" + md5B64 + ":, sha-256=:" + sha256B64 + ":",
+		"Digest":      "MD5=" + md5B64 + ", SHA-256=" + sha256B64,
+	} {
+		if got := part.Header.Get(key); got != want {
+			t.Errorf("%s: got %q, want %q", key, got, want)
+		}
+	}
+	if content, _ := io.ReadAll(part.Content); string(content) != "content" {
+		t.Errorf("content is not sought back: %q", content)
+	}
+
+	if err := itermultipart.NewPart().SetContent(iotest.HalfReader(strings.NewReader("x"))).SetDigestHeaders(itermultipart.DigestMD5); err == nil {
+		t.Error("expected error for non-seekable content")
+	}
+	if err := itermultipart.NewFieldPart("a", "b").SetDigestHeaders("crc32"); err == nil {
+		t.Error("expected error for unsupported algorithm")
+	}
+}
+
+func TestWithBodyDigests(t *testing.T) {
+	newSource := func() *itermultipart.Source {
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("a", "first"),
+			itermultipart.NewPart().SetFormName("b").SetContent(iotest.HalfReader(strings.NewReader(strings.Repeat("x", 100000)))),
+		), itermultipart.WithBodyDigests(itermultipart.DigestSHA256, itermultipart.DigestMD5))
+	}
+
+	generate := map[string]func(src *itermultipart.Source, w io.Writer) error{
+		"WriteTo": func(src *itermultipart.Source, w io.Writer) error { _, err := src.WriteTo(w); return err },
+		"Read": func(src *itermultipart.Source, w io.Writer) error {
+			_, err := io.Copy(w, struct{ io.Reader }{src})
+			return err
+		},
+	}
+	for name, gen := range generate {
+		src := newSource()
+		checksum := crc32.NewIEEE()
+		if err := src.EnableStreamChecksum(checksum); err != nil {
+			t.Fatalf("%s: EnableStreamChecksum: unexpected error %s", name, err)
+		}
+		if _, ok := src.ETag(); ok {
+			t.Errorf("%s: ETag is available before generation", name)
+		}
+
+		var body bytes.Buffer
+		if err := gen(src, &body); err != nil {
+			t.Fatalf("%s: unexpected error %s", name, err)
+		}
+
+		sha256Sum, md5Sum := sha256.Sum256(body.Bytes()), md5.Sum(body.Bytes())
+		digests := src.Stats().BodyDigests
+		if !bytes.Equal(digests[itermultipart.DigestSHA256], sha256Sum[:]) {
+			t.Errorf("%s: SHA-256 mismatch", name)
+		}
+		if !bytes.Equal(digests[itermultipart.DigestMD5], md5Sum[:]) {
+			t.Errorf("%s: MD5 mismatch", name)
+		}
+		if etag, ok := src.ETag(); !ok || etag != `"`+hex.EncodeToString(sha256Sum[:])+`"` {
+			t.Errorf("%s: unexpected ETag %q", name, etag)
+		}
+		// the stream checksum is fed along with body digests
+		if sum, ok := src.StreamChecksum(); !ok || crc32.ChecksumIEEE(body.Bytes()) != binary.BigEndian.Uint32(sum) {
+			t.Errorf("%s: stream checksum mismatch", name)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+// Package itermultipart works with multipart messages using iterators.
+//
+// Parts are passed around as iter.Seq2[*Part, error] sequences. Producers of sequences in this package,
+// and ones passed to it, follow the same contract:
+//   - when yield returns false, the producer returns immediately without calling yield again
+//     and releases resources it holds: closes opened contents, stops reading the message;
+//   - a yielded [Part] and its content are valid only until the next iteration, consumers must not hold them;
+//   - an error is yielded with a nil [Part]; producers that can't continue after the error return afterwards.
+//
+// So breaking out of a for range loop, or wrapping the sequence with [LimitParts], stops the whole pipeline.
+//
+// Generated messages differ only by random boundaries. For golden-file tests and reproducible builds
+// make them deterministic with [WithBoundaryFunc] and [SequentialBoundaries], or with [WithRand] and a seeded reader.
+package itermultipart
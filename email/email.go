@@ -0,0 +1,248 @@
+// Package email builds RFC 5322 email messages on top of [itermultipart.Source]:
+// top-level headers, text and HTML bodies as multipart/alternative, inline images as multipart/related
+// and attachments as multipart/mixed. Contents are streamed lazily while the message is written.
+package email
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xakep666/itermultipart"
+)
+
+const (
+	contentTypeHeader             = "Content-Type"
+	contentTransferEncodingHeader = "Content-Transfer-Encoding"
+)
+
+// Message is an email message under construction. Setters return the message for chaining.
+type Message struct {
+	header        textproto.MIMEHeader
+	headerOrder   []string
+	text, html    *itermultipart.Part
+	inline        []*itermultipart.Part
+	attachments   []*itermultipart.Part
+	sourceOptions []itermultipart.SourceOption
+}
+
+// NewMessage creates an empty message. Options configure sources generating multipart bodies, i.e. boundaries.
+func NewMessage(opts ...itermultipart.SourceOption) *Message {
+	return &Message{
+		header:        make(textproto.MIMEHeader),
+		sourceOptions: opts,
+	}
+}
+
+// SetHeader sets the top-level header, non-ASCII values are encoded as RFC 2047 encoded-words.
+// Content-Type, Content-Transfer-Encoding and MIME-Version headers are set by the message itself.
+func (m *Message) SetHeader(key, value string) *Message {
+	return m.setHeader(key, mime.QEncoding.Encode("utf-8", value))
+}
+
+func (m *Message) setHeader(key, value string) *Message {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	if _, ok := m.header[key]; !ok {
+		m.headerOrder = append(m.headerOrder, key)
+	}
+	m.header.Set(key, value)
+	return m
+}
+
+// SetAddresses sets the address list header, i.e. "To" or "Cc".
+func (m *Message) SetAddresses(key string, addrs ...*mail.Address) *Message {
+	formatted := make([]string, len(addrs))
+	for i, addr := range addrs {
+		formatted[i] = addr.String()
+	}
+	return m.setHeader(key, strings.Join(formatted, ", "))
+}
+
+// SetFrom sets the "From" header.
+func (m *Message) SetFrom(addr *mail.Address) *Message {
+	return m.SetAddresses("From", addr)
+}
+
+// SetTo sets the "To" header.
+func (m *Message) SetTo(addrs ...*mail.Address) *Message {
+	return m.SetAddresses("To", addrs...)
+}
+
+// SetCc sets the "Cc" header.
+func (m *Message) SetCc(addrs ...*mail.Address) *Message {
+	return m.SetAddresses("Cc", addrs...)
+}
+
+// SetSubject sets the "Subject" header.
+func (m *Message) SetSubject(subject string) *Message {
+	return m.SetHeader("Subject", subject)
+}
+
+// SetDate sets the "Date" header. The current time is used if it's not set.
+func (m *Message) SetDate(t time.Time) *Message {
+	return m.setHeader("Date", t.Format(time.RFC1123Z))
+}
+
+// SetText sets the plain text body encoded as quoted-printable.
+func (m *Message) SetText(content io.Reader) *Message {
+	m.text = textPart("text/plain", content)
+	return m
+}
+
+// SetHTML sets the HTML body encoded as quoted-printable.
+// Inline images added with [Message.AddInline] are referenced from it as "cid:<content id>".
+func (m *Message) SetHTML(content io.Reader) *Message {
+	m.html = textPart("text/html", content)
+	return m
+}
+
+func textPart(mediaType string, content io.Reader) *itermultipart.Part {
+	return itermultipart.NewPart().
+		SetContentType(mediaType + "; charset=utf-8").
+		SetTransferEncoding(itermultipart.TransferEncodingQuotedPrintable).
+		SetSMTPCompat(true).
+		SetContent(content)
+}
+
+// AddInline adds the part, i.e. an image, referenced from the HTML body by the Content-ID.
+// The part gets the "inline" disposition and base64 transfer encoding if it has no transfer encoding,
+// the transfer encoding follows SMTP line length limits, see [itermultipart.Part.SetSMTPCompat].
+func (m *Message) AddInline(cid string, part *itermultipart.Part) *Message {
+	m.inline = append(m.inline, prepareBinary(part.SetContentID(cid).SetInline()))
+	return m
+}
+
+// AddAttachment adds the attachment part, see [NewAttachment].
+// The part gets the "attachment" disposition if it has no disposition
+// and base64 transfer encoding if it has no transfer encoding, the transfer encoding follows SMTP line length limits.
+func (m *Message) AddAttachment(part *itermultipart.Part) *Message {
+	if part.Disposition() == "" {
+		part.SetAttachment()
+	}
+	m.attachments = append(m.attachments, prepareBinary(part))
+	return m
+}
+
+// NewAttachment returns the attachment part with the file name and the content type detected by its extension.
+func NewAttachment(fileName string, content io.Reader) *itermultipart.Part {
+	part := itermultipart.NewPart().SetAttachment().SetFileName(fileName).SetContent(content)
+	if typ := mime.TypeByExtension(filepath.Ext(fileName)); typ != "" {
+		part.SetContentType(typ)
+	}
+	return part
+}
+
+func prepareBinary(part *itermultipart.Part) *itermultipart.Part {
+	if part.Header.Get(contentTransferEncodingHeader) == "" {
+		part.SetTransferEncoding(itermultipart.TransferEncodingBase64)
+	}
+	return part.SetSMTPCompat(true)
+}
+
+// WriteTo writes the message to w, i.e. to the writer returned by [net/smtp.Client.Data].
+// Bodies are composed as follows: text and HTML go to multipart/alternative if both are set,
+// inline parts are added to multipart/related with the body and attachments to multipart/mixed with the rest.
+// The message is written as a single part if it has only one body.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	root := m.body()
+
+	var heading bytes.Buffer
+	if _, ok := m.header["Date"]; !ok {
+		writeHeaderLine(&heading, "Date", time.Now().Format(time.RFC1123Z))
+	}
+	for _, k := range m.headerOrder {
+		writeHeaderLine(&heading, k, m.header.Get(k))
+	}
+	writeHeaderLine(&heading, "MIME-Version", "1.0")
+	for _, k := range []string{contentTypeHeader, contentTransferEncodingHeader} {
+		if v := root.Header.Get(k); v != "" {
+			writeHeaderLine(&heading, k, v)
+		}
+	}
+	heading.WriteString("\r\n")
+
+	n, err := heading.WriteTo(w)
+	if err != nil || root.Content == nil {
+		return n, err
+	}
+
+	content := root.Content
+	if _, ok := content.(*itermultipart.Source); !ok {
+		// single body, encode it here as there is no Source doing that
+		cw := &countingWriter{w: w}
+		qw := quotedprintable.NewWriter(cw)
+		_, err = io.Copy(qw, content)
+		if err == nil {
+			err = qw.Close()
+		}
+		return n + cw.n, err
+	}
+	written, err := io.Copy(w, content)
+	return n + written, err
+}
+
+// body returns the part with the whole body of the message.
+func (m *Message) body() *itermultipart.Part {
+	var body *itermultipart.Part
+	switch {
+	case m.text != nil && m.html != nil:
+		body = m.multipart("multipart/alternative", m.text, m.html)
+	case m.html != nil:
+		body = m.html
+	case m.text != nil:
+		body = m.text
+	}
+
+	if len(m.inline) > 0 {
+		related := m.inline
+		contentType := "multipart/related"
+		if body != nil {
+			related = append([]*itermultipart.Part{body}, related...)
+			mediaType, _, _ := mime.ParseMediaType(body.ContentType())
+			contentType = mime.FormatMediaType(contentType, map[string]string{"type": mediaType})
+		}
+		body = m.multipart(contentType, related...)
+	}
+
+	if len(m.attachments) > 0 {
+		mixed := m.attachments
+		if body != nil {
+			mixed = append([]*itermultipart.Part{body}, mixed...)
+		}
+		body = m.multipart("multipart/mixed", mixed...)
+	}
+
+	if body == nil {
+		body = textPart("text/plain", nil)
+	}
+	return body
+}
+
+func (m *Message) multipart(contentType string, parts ...*itermultipart.Part) *itermultipart.Part {
+	src := itermultipart.NewSource(itermultipart.PartSeq(parts...), append([]itermultipart.SourceOption{itermultipart.WithReplayableParts()}, m.sourceOptions...)...)
+	return itermultipart.NewPart().SetContentType(contentType).SetMultipartContent(src)
+}
+
+func writeHeaderLine(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\r\n")
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,127 @@
+package email_test
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+	"github.com/xakep666/itermultipart/email"
+)
+
+// leaf is a non-multipart part of the message with its path of media types.
+type leaf struct {
+	path    string
+	content string
+}
+
+func walk(t *testing.T, path, contentType string, body io.Reader) []leaf {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("%s: invalid Content-Type %q", path, contentType)
+	}
+	path += "/" + mediaType
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		content, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("%s: reading content: %s", path, err)
+		}
+		return []leaf{{path: path, content: string(content)}}
+	}
+
+	var leaves []leaf
+	for part, err := range itermultipart.NewParser(body, params["boundary"]).Parts() {
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", path, err)
+		}
+		content := part.Content
+		switch part.Header.Get("Content-Transfer-Encoding") {
+		case "quoted-printable":
+			content = quotedprintable.NewReader(content)
+		case "base64":
+			content = strings.NewReader("base64:" + readString(t, content))
+		}
+		leaves = append(leaves, walk(t, path, part.ContentType(), content)...)
+	}
+	return leaves
+}
+
+func readString(t *testing.T, r io.Reader) string {
+	t.Helper()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	return string(b)
+}
+
+func TestMessage(t *testing.T) {
+	msg := email.NewMessage().
+		SetFrom(&mail.Address{Name: "Alice", Address: "alice@example.com"}).
+		SetTo(&mail.Address{Address: "bob@example.com"}, &mail.Address{Name: "Карл", Address: "carl@example.com"}).
+		SetSubject("Привет").
+		SetText(strings.NewReader("Hello, Bob!")).
+		SetHTML(strings.NewReader(`<p>Hello, Bob!</p><img src="cid:logo">`)).
+		AddInline("logo", itermultipart.NewPart().SetContentType("image/png").SetContentString("PNG")).
+		AddAttachment(email.NewAttachment("report.txt", strings.NewReader("report")))
+
+	var b bytes.Buffer
+	if _, err := msg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+
+	parsed, err := mail.ReadMessage(&b)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error %s", err)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); err != nil || subject != "Привет" {
+		t.Errorf("got subject %q (%v)", subject, err)
+	}
+	if to, err := parsed.Header.AddressList("To"); err != nil || len(to) != 2 || to[1].Name != "Карл" {
+		t.Errorf("got To %v (%v)", to, err)
+	}
+	if parsed.Header.Get("Date") == "" || parsed.Header.Get("MIME-Version") != "1.0" {
+		t.Errorf("missing Date or MIME-Version headers: %v", parsed.Header)
+	}
+
+	got := walk(t, "", parsed.Header.Get("Content-Type"), parsed.Body)
+	want := []leaf{
+		{"/multipart/mixed/multipart/related/multipart/alternative/text/plain", "Hello, Bob!"},
+		{"/multipart/mixed/multipart/related/multipart/alternative/text/html", `<p>Hello, Bob!</p><img src="cid:logo">`},
+		{"/multipart/mixed/multipart/related/image/png", "base64:UE5H"},
+		{"/multipart/mixed/text/plain", "base64:cmVwb3J0"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got parts %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("part %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMessageSingleBody(t *testing.T) {
+	var b bytes.Buffer
+	_, err := email.NewMessage().SetSubject("plain").SetText(strings.NewReader("Grüße\n")).WriteTo(&b)
+	if err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+
+	parsed, err := mail.ReadMessage(&b)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error %s", err)
+	}
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if got := readString(t, quotedprintable.NewReader(parsed.Body)); got != "Grüße\r\n" {
+		t.Errorf("got body %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+package itermultipart
+
+import (
+	"iter"
+	"maps"
+	"mime"
+	"net/textproto"
+	"strings"
+)
+
+// DecodeEncodedWords decodes RFC 2047 encoded-words, i.e. "=?UTF-8?B?...?=", in part header values sent by email clients.
+// Name and file name parameters of the Content-Disposition header are decoded too, so [Part.FileName] returns
+// the readable name. UTF-8, US-ASCII and ISO-8859-1 are supported out of the box, other charsets are converted
+// with charsetReader which may be nil. Malformed encoded-words and ones in unsupported charsets are left as is.
+// Parts are not modified permanently: the header is restored after the part is yielded.
+func DecodeEncodedWords(parts iter.Seq2[*Part, error], charsetReader CharsetReader) iter.Seq2[*Part, error] {
+	dec := &mime.WordDecoder{CharsetReader: charsetReader}
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			header := part.Header
+			if decoded, ok := decodeHeaderWords(dec, header); ok {
+				part.Header = decoded
+			}
+			next := yield(part, nil)
+			part.Header = header
+			if !next {
+				return
+			}
+		}
+	}
+}
+
+// decodeHeaderWords returns the copy of the header with decoded values, reports false if nothing is decoded.
+func decodeHeaderWords(dec *mime.WordDecoder, header textproto.MIMEHeader) (textproto.MIMEHeader, bool) {
+	var decoded textproto.MIMEHeader
+	for key, values := range header {
+		for i, v := range values {
+			if !strings.Contains(v, "=?") {
+				continue
+			}
+			var dv string
+			if key == contentDispositionHeader {
+				dv = decodeDispositionWords(dec, v)
+			} else if dv, _ = dec.DecodeHeader(v); dv == "" {
+				dv = v
+			}
+			if dv == v {
+				continue
+			}
+
+			if decoded == nil {
+				decoded = maps.Clone(header)
+			}
+			if &decoded[key][0] == &values[0] {
+				decoded[key] = append([]string(nil), values...) // don't modify values of the original header
+			}
+			decoded[key][i] = dv
+		}
+	}
+	return decoded, decoded != nil
+}
+
+// decodeDispositionWords decodes encoded-words in parameter values which mail clients put into quoted strings.
+func decodeDispositionWords(dec *mime.WordDecoder, v string) string {
+	disposition, params, err := mime.ParseMediaType(v)
+	if err != nil {
+		return v
+	}
+	for k, pv := range params {
+		if dv, err := dec.DecodeHeader(pv); err == nil {
+			params[k] = dv
+		}
+	}
+	if formatted := formatDisposition(disposition, params); formatted != "" {
+		return formatted
+	}
+	return v
+}
+
+// SetEncodedHeaderValue sets the value of the given header key encoding it as RFC 2047 encoded-words
+// if it contains non-ASCII characters, i.e. for email headers like "Subject". ASCII values are set as is.
+// See [DecodeEncodedWords] for the read side.
+func (p *Part) SetEncodedHeaderValue(key, value string) *Part {
+	return p.SetHeaderValue(key, mime.QEncoding.Encode("utf-8", value))
+}
@@ -0,0 +1,43 @@
+package itermultipart_test
+
+import (
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestDecodeEncodedWords(t *testing.T) {
+	part := itermultipart.NewPart().
+		SetHeaderValue("Content-Disposition", `attachment; filename="=?UTF-8?B?0L/RgNC40LLQtdGCLnR4dA==?="`).
+		SetHeaderValue("X-Comment", "=?ISO-8859-1?Q?caf=E9?= au lait").
+		SetHeaderValue("X-Plain", "plain").
+		SetEncodedHeaderValue("Subject", "отчёт")
+
+	if got := part.Header.Get("Subject"); got == "отчёт" {
+		t.Errorf("SetEncodedHeaderValue: value is not encoded")
+	}
+	if got := itermultipart.NewPart().SetEncodedHeaderValue("Subject", "report").Header.Get("Subject"); got != "report" {
+		t.Errorf("SetEncodedHeaderValue: got ASCII value %q, want as is", got)
+	}
+
+	for got, err := range itermultipart.DecodeEncodedWords(itermultipart.PartSeq(part), nil) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if name := got.FileName(); name != "привет.txt" {
+			t.Errorf("got file name %q, want %q", name, "привет.txt")
+		}
+		for key, want := range map[string]string{"X-Comment": "café au lait", "X-Plain": "plain", "Subject": "отчёт"} {
+			if v := got.Header.Get(key); v != want {
+				t.Errorf("header %s: got %q, want %q", key, v, want)
+			}
+		}
+	}
+
+	if got := part.Header.Get("X-Comment"); got != "=?ISO-8859-1?Q?caf=E9?= au lait" {
+		t.Errorf("header is not restored: %q", got)
+	}
+	if got := part.FileName(); got == "привет.txt" {
+		t.Errorf("disposition is not restored")
+	}
+}
@@ -0,0 +1,62 @@
+package itermultipart
+
+import (
+	"iter"
+	"maps"
+	"slices"
+)
+
+// Form is a parsed multipart form mirroring [multipart.Form], so handlers can keep the same shape of data
+// while the form is parsed from a part sequence by [ParseForm].
+type Form struct {
+	Value map[string][]string
+	File  map[string][]*FileHeader
+
+	// TotalBytes is the number of content bytes read from all form parts.
+	TotalBytes int64
+}
+
+// ParseForm reads form fields and files from parts like [net/http.Request.ParseMultipartForm] does:
+// values are kept in memory up to maxMemory plus 10 MiB unless [WithMaxValuesSize] is set, up to maxMemory bytes of files are kept in memory and the rest is stored in temporary files.
+// Parts without a form name are skipped. Options set limits and the temporary directory, see [CollectValues] and [CollectFiles].
+// Call [Form.RemoveAll] when the form is not needed, on error temporary files are removed by ParseForm.
+func ParseForm(parts iter.Seq2[*Part, error], maxMemory int64, opts ...CollectOption) (*Form, error) {
+	c := newCollector(append([]CollectOption{WithMaxMemory(maxMemory)}, opts...))
+	if err := c.collect(parts, true, true); err != nil {
+		c.files.RemoveAll()
+		return nil, err
+	}
+	return &Form{
+		Value:      c.values,
+		File:       c.files,
+		TotalBytes: c.total,
+	}, nil
+}
+
+// RemoveAll removes temporary files of the form.
+func (f *Form) RemoveAll() error {
+	return CollectedFiles(f.File).RemoveAll()
+}
+
+// All returns a sequence of form values as name and value pairs, names are sorted and values of a name keep their order.
+func (f *Form) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, name := range slices.Sorted(maps.Keys(f.Value)) {
+			for _, value := range f.Value[name] {
+				if !yield(name, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Values returns a sequence of values of the named form field.
+func (f *Form) Values(name string) iter.Seq[string] {
+	return slices.Values(f.Value[name])
+}
+
+// Files returns a sequence of files of the named form field.
+func (f *Form) Files(name string) iter.Seq[*FileHeader] {
+	return slices.Values(f.File[name])
+}
@@ -0,0 +1,101 @@
+package itermultipart_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestParseForm(t *testing.T) {
+	dir := t.TempDir()
+	large := strings.Repeat("x", 1000)
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("title", "report"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("small.txt").SetContentString("small"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("large.txt").SetContentString(large),
+	))
+	req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com", src)
+	if err != nil {
+		t.Fatalf("NewRequest: unexpected error %s", err)
+	}
+
+	form, err := itermultipart.ParseForm(itermultipart.PartsFromRequest(req, false), 100, itermultipart.WithTempDir(dir))
+	if err != nil {
+		t.Fatalf("ParseForm: unexpected error %s", err)
+	}
+	if got := form.Value["title"]; len(got) != 1 || got[0] != "report" {
+		t.Errorf("got title %v", got)
+	}
+	if want := int64(len("report") + len("small") + len(large)); form.TotalBytes != want {
+		t.Errorf("got %d total bytes, want %d", form.TotalBytes, want)
+	}
+
+	files := form.File["file"]
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	f, err := files[1].Open()
+	if err != nil {
+		t.Fatalf("Open: unexpected error %s", err)
+	}
+	content, _ := io.ReadAll(f)
+	f.Close()
+	if string(content) != large {
+		t.Errorf("got content of %d bytes, want %d", len(content), len(large))
+	}
+	if tempFiles, _ := os.ReadDir(dir); len(tempFiles) != 1 {
+		t.Errorf("got %d temporary files, want 1", len(tempFiles))
+	}
+
+	if err := form.RemoveAll(); err != nil {
+		t.Errorf("RemoveAll: unexpected error %s", err)
+	}
+	if tempFiles, _ := os.ReadDir(dir); len(tempFiles) != 0 {
+		t.Errorf("got %d temporary files after RemoveAll", len(tempFiles))
+	}
+
+	// values are limited to maxMemory plus 10 MiB by default
+	_, err = itermultipart.ParseForm(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("title").SetContent(io.LimitReader(zeroReader{}, 10<<20+101)),
+	), 100, itermultipart.WithTempDir(dir))
+	if !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+}
+
+func TestFormIterators(t *testing.T) {
+	form, err := itermultipart.ParseForm(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("b", "2"),
+		itermultipart.NewFieldPart("a", "1"),
+		itermultipart.NewFieldPart("b", "3"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("a.txt").SetContentString("a"),
+	), 100)
+	if err != nil {
+		t.Fatalf("ParseForm: unexpected error %s", err)
+	}
+	defer form.RemoveAll()
+
+	var pairs []string
+	for name, value := range form.All() {
+		pairs = append(pairs, name+"="+value)
+	}
+	if got := strings.Join(pairs, "&"); got != "a=1&b=2&b=3" {
+		t.Errorf("got pairs %s, want a=1&b=2&b=3", got)
+	}
+	if got := slices.Collect(form.Values("b")); !slices.Equal(got, []string{"2", "3"}) {
+		t.Errorf("got values %v, want [2 3]", got)
+	}
+	if got := slices.Collect(form.Values("missing")); len(got) != 0 {
+		t.Errorf("got values %v of missing field", got)
+	}
+	if got := slices.Collect(form.Files("file")); len(got) != 1 || got[0].Filename != "a.txt" {
+		t.Errorf("got files %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+package itermultipart
+
+import (
+	"io"
+	"io/fs"
+	"iter"
+	"path"
+	"strings"
+)
+
+type fsOptions struct {
+	fieldName string
+	filter    func(path string, d fs.DirEntry) bool
+}
+
+// FSOption configures [PartsFromFS].
+type FSOption func(*fsOptions)
+
+// WithFSFieldName sets the form name of file parts generated by [PartsFromFS]. Default is "files".
+func WithFSFieldName(name string) FSOption {
+	return func(o *fsOptions) {
+		o.fieldName = name
+	}
+}
+
+// WithFSFilter makes [PartsFromFS] skip entries the filter returns false for.
+// Path is relative to the fs.FS root like in [fs.WalkDirFunc]. Skipped directories are not walked.
+func WithFSFilter(filter func(path string, d fs.DirEntry) bool) FSOption {
+	return func(o *fsOptions) {
+		o.filter = filter
+	}
+}
+
+// PartsFromFS walks the directory tree of fsys rooted at root with [fs.WalkDir] and yields a file part
+// for every regular file. File name of the part is the path relative to root,
+// content type is set by its extension, see [Part.SetContentTypeByExtension].
+// Files are opened only when [Source] reaches the part and closed after their content is written,
+// see [Part.SetContentFactory]. Parts are created on every iteration, so the sequence can be iterated multiple times.
+func PartsFromFS(fsys fs.FS, root string, opts ...FSOption) iter.Seq2[*Part, error] {
+	options := fsOptions{fieldName: "files"}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(yield func(*Part, error) bool) {
+		err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if options.filter != nil && !options.filter(name, d) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			relName := path.Base(name) // root is a file itself
+			switch {
+			case name == root:
+			case root == ".":
+				relName = name
+			default:
+				relName = strings.TrimPrefix(name, root+"/")
+			}
+
+			part := NewPart().
+				SetFormName(options.fieldName).
+				SetFileName(relName).
+				SetContentTypeByExtension().
+				SetContentFactory(func() (io.ReadCloser, error) {
+					return fsys.Open(name)
+				})
+			if !yield(part, nil) {
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
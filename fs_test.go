@@ -0,0 +1,83 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestPartsFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root/a.txt":          {Data: []byte("a")},
+		"root/dir/b.json":     {Data: []byte(`{"b":1}`)},
+		"root/skip/c.txt":     {Data: []byte("c")},
+		"root/dir/link":       {Data: []byte("a.txt"), Mode: fs.ModeSymlink},
+		"other/not-walked.go": {Data: []byte("package other")},
+	}
+
+	tests := []struct {
+		name  string
+		root  string
+		opts  []itermultipart.FSOption
+		files map[string]string
+	}{
+		{"directory", "root", nil, map[string]string{"a.txt": "a", "dir/b.json": `{"b":1}`, "skip/c.txt": "c"}},
+		{"filter", "root", []itermultipart.FSOption{
+			itermultipart.WithFSFilter(func(path string, d fs.DirEntry) bool { return path != "root/skip" }),
+		}, map[string]string{"a.txt": "a", "dir/b.json": `{"b":1}`}},
+		{"file", "root/dir/b.json", nil, map[string]string{"b.json": `{"b":1}`}},
+		{"current directory", ".", []itermultipart.FSOption{
+			itermultipart.WithFSFilter(func(path string, d fs.DirEntry) bool { return path != "root" }),
+		}, map[string]string{"other/not-walked.go": "package other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := itermultipart.NewSource(itermultipart.PartsFromFS(fsys, tt.root, append(tt.opts, itermultipart.WithFSFieldName("upload"))...))
+			req, err := http.NewRequest(http.MethodPost, "http://example.com", src)
+			if err != nil {
+				t.Fatalf("NewRequest: unexpected error %s", err)
+			}
+			req.Header.Set("Content-Type", src.FormDataContentType())
+
+			files := make(map[string]string)
+			for part, err := range itermultipart.PartsFromRequest(req, true) {
+				if err != nil {
+					t.Fatalf("unexpected error %s", err)
+				}
+				if part.FormName() != "upload" {
+					t.Errorf("unexpected form name %q", part.FormName())
+				}
+				if strings.HasSuffix(part.FileName(), ".json") && part.ContentType() != "application/json" {
+					t.Errorf("unexpected content type %q", part.ContentType())
+				}
+				content, err := io.ReadAll(part.Content)
+				if err != nil {
+					t.Fatalf("read: unexpected error %s", err)
+				}
+				_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+				if err != nil {
+					t.Fatalf("Content-Disposition: unexpected error %s", err)
+				}
+				files[params["filename"]] = string(content) // FileName strips the directory
+
+			}
+			if !maps.Equal(files, tt.files) {
+				t.Errorf("got files %v, want %v", files, tt.files)
+			}
+		})
+	}
+
+	for _, err := range itermultipart.PartsFromFS(fsys, "missing") {
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("got error %v, want %v", err, fs.ErrNotExist)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package itermultipart
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const httpMediaType = "application/http"
+
+// NewHTTPRequestPart returns the "application/http" part with the request serialized like [http.Request.Write],
+// i.e. for batch requests of Google APIs or OData $batch sent as multipart/mixed messages.
+// The request is written when [Source] reaches the part, its body is consumed and closed then.
+// Set the Content-ID with [Part.SetContentID] if the batch API requires it.
+func NewHTTPRequestPart(req *http.Request) *Part {
+	return newHTTPPart(req.Write)
+}
+
+// NewHTTPResponsePart returns the "application/http" part with the response serialized like [http.Response.Write],
+// i.e. for responses of a batch API server. See [NewHTTPRequestPart] for details.
+func NewHTTPResponsePart(resp *http.Response) *Part {
+	return newHTTPPart(resp.Write)
+}
+
+func newHTTPPart(write func(w io.Writer) error) *Part {
+	return NewPart().
+		SetContentType(httpMediaType).
+		SetHeaderValue(contentTransferEncodingHeader, "binary").
+		SetContentFunc(func() (io.Reader, error) {
+			pr, pw := io.Pipe()
+			go func() {
+				pw.CloseWithError(write(pw))
+			}()
+			return pr, nil
+		})
+}
+
+// ParseHTTPRequestPart reads the request from the "application/http" part like [http.ReadRequest].
+// The request body reads the part content, so it's valid until the next part is read.
+func ParseHTTPRequestPart(part *Part) (*http.Request, error) {
+	br, err := httpPartReader(part)
+	if err != nil {
+		return nil, err
+	}
+	return http.ReadRequest(br)
+}
+
+// ParseHTTPResponsePart reads the response to req from the "application/http" part like [http.ReadResponse],
+// req may be nil. The response body reads the part content, so it's valid until the next part is read.
+func ParseHTTPResponsePart(part *Part, req *http.Request) (*http.Response, error) {
+	br, err := httpPartReader(part)
+	if err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(br, req)
+}
+
+func httpPartReader(part *Part) (*bufio.Reader, error) {
+	if mediaType, _, _ := mime.ParseMediaType(part.ContentType()); mediaType != httpMediaType {
+		return nil, fmt.Errorf("multipart: not an %s part: %q", httpMediaType, part.ContentType())
+	}
+	if part.Content == nil {
+		return nil, fmt.Errorf("multipart: %s part without content", httpMediaType)
+	}
+	return bufio.NewReader(part.Content), nil
+}
@@ -0,0 +1,77 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestHTTPBatchParts(t *testing.T) {
+	get, _ := http.NewRequest(http.MethodGet, "https://example.com/v1/animals/pony", nil)
+	post, _ := http.NewRequest(http.MethodPost, "https://example.com/v1/animals", strings.NewReader(`{"name":"pony"}`))
+	post.Header.Set("Content-Type", "application/json")
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewHTTPRequestPart(get).SetContentID("item1"),
+		itermultipart.NewHTTPRequestPart(post).SetContentID("item2"),
+	))
+	var message bytes.Buffer
+	if _, err := src.WriteTo(&message); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+
+	var got []string
+	for part, err := range itermultipart.NewParser(&message, src.Boundary()).Parts() {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		req, err := itermultipart.ParseHTTPRequestPart(part)
+		if err != nil {
+			t.Fatalf("ParseHTTPRequestPart: unexpected error %s", err)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("reading body: unexpected error %s", err)
+		}
+		got = append(got, part.ContentID()+" "+req.Method+" "+req.Host+req.URL.Path+" "+req.Header.Get("Content-Type")+" "+string(body))
+	}
+	want := []string{
+		"item1 GET example.com/v1/animals/pony  ",
+		`item2 POST example.com/v1/animals application/json {"name":"pony"}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got requests\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	resp := &http.Response{
+		StatusCode: http.StatusCreated, ProtoMajor: 1, ProtoMinor: 1,
+		Header: http.Header{"Content-Type": {"text/plain"}},
+		Body:   io.NopCloser(strings.NewReader("created")), ContentLength: 7,
+	}
+	src = itermultipart.NewSource(itermultipart.PartSeq(itermultipart.NewHTTPResponsePart(resp)))
+	message.Reset()
+	if _, err := src.WriteTo(&message); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	for part, err := range itermultipart.NewParser(&message, src.Boundary()).Parts() {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		resp, err := itermultipart.ParseHTTPResponsePart(part, nil)
+		if err != nil {
+			t.Fatalf("ParseHTTPResponsePart: unexpected error %s", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusCreated || string(body) != "created" {
+			t.Errorf("got response %d %q", resp.StatusCode, body)
+		}
+	}
+
+	if _, err := itermultipart.ParseHTTPRequestPart(itermultipart.NewFieldPart("a", "b")); err == nil {
+		t.Error("expected error for non application/http part")
+	}
+}
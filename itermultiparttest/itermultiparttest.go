@@ -0,0 +1,218 @@
+// Package itermultiparttest provides utilities for testing code producing multipart messages with [itermultipart]:
+// boundary-independent transcripts of generated messages for golden tests, comparison of part sequences
+// and a builder of fixture part sequences.
+package itermultiparttest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// BoundaryPlaceholder replaces the boundary in transcripts made by [RecordSource].
+const BoundaryPlaceholder = "BOUNDARY"
+
+// RecordSource generates the whole message of src and returns it with the boundary replaced by [BoundaryPlaceholder],
+// so the transcript doesn't depend on the random boundary and can be compared with a golden file.
+func RecordSource(src *itermultipart.Source) (string, error) {
+	var sb strings.Builder
+	if _, err := src.WriteTo(&sb); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(sb.String(), src.Boundary(), BoundaryPlaceholder), nil
+}
+
+// AssertPartsEqual compares part sequences by headers and contents reading both of them,
+// mismatches are reported with t.Errorf. It returns true if the sequences are equal.
+// See [DiffParts] for comparison rules.
+func AssertPartsEqual(t testing.TB, want, got iter.Seq2[*itermultipart.Part, error]) bool {
+	t.Helper()
+	diffs, err := DiffParts(want, got)
+	if err != nil {
+		t.Errorf("comparing parts: %s", err)
+		return false
+	}
+	for _, diff := range diffs {
+		t.Error(diff)
+	}
+	return len(diffs) == 0
+}
+
+// DiffKind is the kind of the [Difference].
+type DiffKind int
+
+const (
+	DiffHeader      DiffKind = iota // header values differ
+	DiffContent                     // contents differ
+	DiffMissingPart                 // the part is missing in the got sequence
+	DiffExtraPart                   // the got sequence has an unexpected part
+)
+
+// Difference describes a mismatch of part sequences found by [DiffParts].
+type Difference struct {
+	Part int      // index of the part
+	Kind DiffKind // kind of the mismatch
+	Key  string   // canonical header key for DiffHeader
+
+	// Want and Got are header values for DiffHeader and single-element contents for DiffContent.
+	// For DiffMissingPart and DiffExtraPart they hold the description of the part.
+	Want, Got []string
+}
+
+// String describes the difference.
+func (d Difference) String() string {
+	switch d.Kind {
+	case DiffHeader:
+		return fmt.Sprintf("part %d: header %s: got %q, want %q", d.Part, d.Key, d.Got, d.Want)
+	case DiffContent:
+		return fmt.Sprintf("part %d: content: got %q, want %q", d.Part, d.Got[0], d.Want[0])
+	case DiffMissingPart:
+		return fmt.Sprintf("part %d: missing part %s", d.Part, d.Want[0])
+	case DiffExtraPart:
+		return fmt.Sprintf("part %d: unexpected part %s", d.Part, d.Got[0])
+	default:
+		return fmt.Sprintf("part %d: unknown difference", d.Part)
+	}
+}
+
+// DiffParts reads both sequences and returns their differences, nil if they are equal.
+// Header values are compared as is, the order of header keys is ignored. Contents are compared byte by byte.
+// Errors of sequences or contents stop the comparison and are returned with differences found so far.
+func DiffParts(want, got iter.Seq2[*itermultipart.Part, error]) ([]Difference, error) {
+	nextWant, stopWant := iter.Pull2(want)
+	defer stopWant()
+	nextGot, stopGot := iter.Pull2(got)
+	defer stopGot()
+
+	var diffs []Difference
+	for i := 0; ; i++ {
+		wantPart, wantErr, wantOK := nextWant()
+		gotPart, gotErr, gotOK := nextGot()
+		switch {
+		case wantErr != nil:
+			return diffs, fmt.Errorf("want part %d: %w", i, wantErr)
+		case gotErr != nil:
+			return diffs, fmt.Errorf("got part %d: %w", i, gotErr)
+		case !wantOK && !gotOK:
+			return diffs, nil
+		case !wantOK:
+			return append(diffs, Difference{Part: i, Kind: DiffExtraPart, Got: []string{gotPart.String()}}), nil
+		case !gotOK:
+			return append(diffs, Difference{Part: i, Kind: DiffMissingPart, Want: []string{wantPart.String()}}), nil
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(mergeKeys(wantPart.Header, gotPart.Header))) {
+			if w, g := wantPart.Header.Values(key), gotPart.Header.Values(key); !slices.Equal(w, g) {
+				diffs = append(diffs, Difference{Part: i, Kind: DiffHeader, Key: key, Want: w, Got: g})
+			}
+		}
+
+		wantContent, err := readContent(wantPart)
+		if err != nil {
+			return diffs, fmt.Errorf("want part %d: read content: %w", i, err)
+		}
+		gotContent, err := readContent(gotPart)
+		if err != nil {
+			return diffs, fmt.Errorf("got part %d: read content: %w", i, err)
+		}
+		if wantContent != gotContent {
+			diffs = append(diffs, Difference{Part: i, Kind: DiffContent, Want: []string{wantContent}, Got: []string{gotContent}})
+		}
+	}
+}
+
+// CheckRoundTrip generates the message from parts with opts, parses it back and compares parsed parts
+// with the parts sequence iterated again, i.e. in fuzz or property tests of pipelines producing parts.
+// parts is called twice and must return equal sequences.
+// Errors of generation or parsing are returned, differences are reported like by [DiffParts].
+func CheckRoundTrip(parts func() iter.Seq2[*itermultipart.Part, error], opts ...itermultipart.SourceOption) ([]Difference, error) {
+	var body bytes.Buffer
+	src := itermultipart.NewSource(parts(), opts...)
+	if _, err := src.WriteTo(&body); err != nil {
+		return nil, fmt.Errorf("generate message: %w", err)
+	}
+	return DiffParts(parts(), itermultipart.NewParser(&body, src.Boundary()).Parts())
+}
+
+func mergeKeys(a, b textproto.MIMEHeader) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+func readContent(part *itermultipart.Part) (string, error) {
+	if part.Content == nil {
+		return "", nil
+	}
+	content, err := io.ReadAll(part.Content)
+	return string(content), err
+}
+
+// Fixture builds a part sequence for tests. Setters return the fixture for chaining.
+// Parts are created on every iteration, so the sequence can be iterated multiple times.
+type Fixture struct {
+	parts []func() *itermultipart.Part
+}
+
+// NewFixture returns an empty [Fixture].
+func NewFixture() *Fixture {
+	return &Fixture{}
+}
+
+// Field adds the form field part.
+func (f *Fixture) Field(name, value string) *Fixture {
+	f.parts = append(f.parts, func() *itermultipart.Part {
+		return itermultipart.NewFieldPart(name, value)
+	})
+	return f
+}
+
+// File adds the form file part, the default content type set by [itermultipart.Part.SetFileName] is kept if contentType is empty.
+func (f *Fixture) File(fieldName, fileName, contentType, content string) *Fixture {
+	f.parts = append(f.parts, func() *itermultipart.Part {
+		part := itermultipart.NewPart().SetFormName(fieldName).SetFileName(fileName).SetContentString(content)
+		if contentType != "" {
+			part.SetContentType(contentType)
+		}
+		return part
+	})
+	return f
+}
+
+// Part adds the part with the header and the content, i.e. for non-form messages.
+func (f *Fixture) Part(header textproto.MIMEHeader, content string) *Fixture {
+	f.parts = append(f.parts, func() *itermultipart.Part {
+		return itermultipart.NewPart().MergeHeaders(header).SetContentString(content)
+	})
+	return f
+}
+
+// Parts returns the sequence of fixture parts.
+func (f *Fixture) Parts() iter.Seq2[*itermultipart.Part, error] {
+	return func(yield func(*itermultipart.Part, error) bool) {
+		for _, newPart := range f.parts {
+			if !yield(newPart(), nil) {
+				return
+			}
+		}
+	}
+}
+
+// Source returns the [itermultipart.Source] generating fixture parts, the sequence is declared replayable
+// with [itermultipart.WithReplayableParts].
+func (f *Fixture) Source(opts ...itermultipart.SourceOption) *itermultipart.Source {
+	return itermultipart.NewSource(f.Parts(), append([]itermultipart.SourceOption{itermultipart.WithReplayableParts()}, opts...)...)
+}
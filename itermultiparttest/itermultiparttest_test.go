@@ -0,0 +1,134 @@
+package itermultiparttest_test
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+	"github.com/xakep666/itermultipart/itermultiparttest"
+)
+
+func fixture() *itermultiparttest.Fixture {
+	return itermultiparttest.NewFixture().
+		Field("name", "value").
+		File("file", "a.txt", "text/plain", "file content").
+		Part(textproto.MIMEHeader{"X-Custom": {"1"}}, "raw")
+}
+
+func TestRecordSource(t *testing.T) {
+	for range 2 {
+		transcript, err := itermultiparttest.RecordSource(fixture().Source())
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		want := "--BOUNDARY\r\n" +
+			"Content-Disposition: form-data; name=name\r\n\r\nvalue\r\n" +
+			"--BOUNDARY\r\n" +
+			"Content-Disposition: form-data; filename=a.txt; name=file\r\nContent-Type: text/plain\r\n\r\nfile content\r\n" +
+			"--BOUNDARY\r\n" +
+			"X-Custom: 1\r\n\r\nraw\r\n" +
+			"--BOUNDARY--\r\n"
+		if transcript != want {
+			t.Errorf("got transcript\n%s\nwant\n%s", transcript, want)
+		}
+	}
+}
+
+func TestAssertPartsEqual(t *testing.T) {
+	var body bytes.Buffer
+	src := fixture().Source()
+	if _, err := src.WriteTo(&body); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	parsed := itermultipart.NewParser(&body, src.Boundary()).Parts()
+	if !itermultiparttest.AssertPartsEqual(t, fixture().Parts(), parsed) {
+		t.Error("parsed parts differ from generated ones")
+	}
+
+	rec := &recordingTB{TB: t}
+	other := itermultiparttest.NewFixture().
+		Field("name", "other").
+		File("file", "b.txt", "text/plain", "file content")
+	if itermultiparttest.AssertPartsEqual(rec, fixture().Parts(), other.Parts()) {
+		t.Error("different parts reported equal")
+	}
+	want := []string{
+		`part 0: content: got "other", want "value"`,
+		`part 1: header Content-Disposition: got ["form-data; filename=b.txt; name=file"], want ["form-data; filename=a.txt; name=file"]`,
+		`part 2: missing part`,
+	}
+	if len(rec.errors) != len(want) {
+		t.Fatalf("got errors %q, want %q", rec.errors, want)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(rec.errors[i], w) {
+			t.Errorf("error %d: got %q, want %q", i, rec.errors[i], w)
+		}
+	}
+}
+
+// recordingTB records reported errors instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Error(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDiffParts(t *testing.T) {
+	other := itermultiparttest.NewFixture().
+		Field("name", "value").
+		File("file", "a.txt", "", "file content").
+		Part(textproto.MIMEHeader{"X-Custom": {"1"}}, "raw").
+		Field("extra", "")
+	diffs, err := itermultiparttest.DiffParts(fixture().Parts(), other.Parts())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("got differences %v, want 2", diffs)
+	}
+	if d := diffs[0]; d.Part != 1 || d.Kind != itermultiparttest.DiffHeader || d.Key != "Content-Type" ||
+		len(d.Want) != 1 || d.Want[0] != "text/plain" || len(d.Got) != 1 || d.Got[0] != "application/octet-stream" {
+		t.Errorf("unexpected difference %#v", d)
+	}
+	if d := diffs[1]; d.Part != 3 || d.Kind != itermultiparttest.DiffExtraPart {
+		t.Errorf("unexpected difference %#v", d)
+	}
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	diffs, err := itermultiparttest.CheckRoundTrip(fixture().Parts)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("unexpected differences %v", diffs)
+	}
+
+	calls := 0
+	unstable := func() iter.Seq2[*itermultipart.Part, error] {
+		calls++
+		return itermultipart.PartSeq(itermultipart.NewFieldPart("call", strconv.Itoa(calls)))
+	}
+	diffs, err = itermultiparttest.CheckRoundTrip(unstable)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != itermultiparttest.DiffContent || diffs[0].Want[0] != "2" || diffs[0].Got[0] != "1" {
+		t.Errorf("unexpected differences %v", diffs)
+	}
+}
@@ -0,0 +1,137 @@
+package itermultipart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+var (
+	// ErrPartTooLarge is returned when the content of a part exceeds the limit.
+	ErrPartTooLarge = errors.New("part is too large")
+	// ErrMessageTooLarge is returned when contents of all parts exceed the limit.
+	ErrMessageTooLarge = errors.New("message is too large")
+	// ErrFieldNameTooLong is returned when the form name of a part exceeds the limit.
+	ErrFieldNameTooLong = errors.New("field name is too long")
+)
+
+// Limits restrict parts yielded by [LimitedParts]. Zero values mean no limit.
+type Limits struct {
+	MaxParts        int   // number of parts, [ErrTooManyParts]
+	MaxPartSize     int64 // content size of every part, [ErrPartTooLarge]
+	MaxTotalSize    int64 // content size of all parts, [ErrMessageTooLarge]
+	MaxHeaderBytes  int   // size of part headers as they are written in the message, [ErrHeaderTooLarge]
+	MaxFieldNameLen int   // length of the form name, [ErrFieldNameTooLong]
+}
+
+// LimitedParts returns the sequence enforcing limits while parts are streamed, i.e. to abort a huge upload
+// as soon as it exceeds the limit instead of after reading it completely.
+// Header limits are checked before the part is yielded. Reading the content beyond the size limits fails,
+// the error is also yielded after the part and stops the sequence, so the underlying parser stops reading the message.
+// Errors of the underlying sequence are passed through. All limit errors wrap the sentinel errors listed in [Limits].
+func LimitedParts(parts iter.Seq2[*Part, error], limits Limits) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		var (
+			count int
+			total int64
+		)
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			if count++; limits.MaxParts > 0 && count > limits.MaxParts {
+				yield(nil, fmt.Errorf("%w: more than %d", ErrTooManyParts, limits.MaxParts))
+				return
+			}
+			if err := checkHeaderLimits(part, limits); err != nil {
+				yield(nil, fmt.Errorf("part %d: %w", count-1, err))
+				return
+			}
+
+			content := part.Content
+			var lr *limitedReader
+			if content != nil && (limits.MaxPartSize > 0 || limits.MaxTotalSize > 0) {
+				lr = &limitedReader{r: content, limits: &limits, total: &total}
+				part.Content = lr
+			}
+			next := yield(part, nil)
+			part.Content = content
+			if !next {
+				return
+			}
+			if lr != nil && lr.err != nil {
+				yield(nil, fmt.Errorf("part %d: %w", count-1, lr.err))
+				return
+			}
+		}
+	}
+}
+
+func checkHeaderLimits(part *Part, limits Limits) error {
+	if limits.MaxHeaderBytes > 0 {
+		size := 0
+		for k, values := range part.Header {
+			for _, v := range values {
+				size += len(k) + len(": ") + len(v) + len("\r\n")
+			}
+		}
+		if size > limits.MaxHeaderBytes {
+			return fmt.Errorf("%w: %d bytes, more than %d", ErrHeaderTooLarge, size, limits.MaxHeaderBytes)
+		}
+	}
+	if limits.MaxFieldNameLen > 0 {
+		if name := part.FormName(); len(name) > limits.MaxFieldNameLen {
+			return fmt.Errorf("%w: %d bytes, more than %d", ErrFieldNameTooLong, len(name), limits.MaxFieldNameLen)
+		}
+	}
+	return nil
+}
+
+// limitedReader fails reading beyond the part and the total size limits.
+type limitedReader struct {
+	r      io.Reader
+	limits *Limits
+	read   int64  // bytes of the part
+	total  *int64 // bytes of all parts
+	err    error  // sticky limit error
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.err != nil {
+		return 0, lr.err
+	}
+
+	remaining, limitErr := int64(-1), error(nil)
+	if lr.limits.MaxPartSize > 0 {
+		remaining = lr.limits.MaxPartSize - lr.read
+		limitErr = fmt.Errorf("%w: more than %d bytes", ErrPartTooLarge, lr.limits.MaxPartSize)
+	}
+	if lr.limits.MaxTotalSize > 0 && (remaining < 0 || lr.limits.MaxTotalSize-*lr.total < remaining) {
+		remaining = lr.limits.MaxTotalSize - *lr.total
+		limitErr = fmt.Errorf("%w: more than %d bytes", ErrMessageTooLarge, lr.limits.MaxTotalSize)
+	}
+
+	if remaining <= 0 {
+		// the limit is reached, fail only if there is more content
+		var probe [1]byte
+		n, err := lr.r.Read(probe[:])
+		if n > 0 {
+			lr.err = limitErr
+			return 0, lr.err
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	*lr.total += int64(n)
+	return n, err
+}
@@ -0,0 +1,63 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestLimitedParts(t *testing.T) {
+	parts := func() []*itermultipart.Part {
+		return []*itermultipart.Part{
+			itermultipart.NewFieldPart("a", "12345"),
+			itermultipart.NewFieldPart("b", "1234567890"),
+			itermultipart.NewFieldPart(strings.Repeat("c", 20), "1"),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		limits  itermultipart.Limits
+		read    int
+		wantErr error
+	}{
+		{name: "unlimited", read: 3},
+		{name: "exact", limits: itermultipart.Limits{MaxParts: 3, MaxPartSize: 10, MaxTotalSize: 16, MaxFieldNameLen: 20}, read: 3},
+		{name: "parts", limits: itermultipart.Limits{MaxParts: 2}, read: 2, wantErr: itermultipart.ErrTooManyParts},
+		{name: "part size", limits: itermultipart.Limits{MaxPartSize: 9}, read: 1, wantErr: itermultipart.ErrPartTooLarge},
+		{name: "total size", limits: itermultipart.Limits{MaxTotalSize: 10}, read: 1, wantErr: itermultipart.ErrMessageTooLarge},
+		{name: "header", limits: itermultipart.Limits{MaxHeaderBytes: 50}, read: 2, wantErr: itermultipart.ErrHeaderTooLarge},
+		{name: "field name", limits: itermultipart.Limits{MaxFieldNameLen: 10}, read: 2, wantErr: itermultipart.ErrFieldNameTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				read int
+				err  error
+			)
+			for part, partErr := range itermultipart.LimitedParts(itermultipart.PartSeq(parts()...), tt.limits) {
+				if partErr != nil {
+					err = partErr
+					break
+				}
+				if _, readErr := io.ReadAll(part.Content); readErr != nil {
+					if !errors.Is(readErr, tt.wantErr) {
+						t.Errorf("got content error %v, want %v", readErr, tt.wantErr)
+					}
+					continue
+				}
+				read++
+			}
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if read != tt.read {
+				t.Errorf("read %d parts, want %d", read, tt.read)
+			}
+		})
+	}
+}
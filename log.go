@@ -0,0 +1,103 @@
+package itermultipart
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// WithLogger makes [Source] log the message generation with the logger at debug level:
+// parts started with their headers, content bytes of finished parts and the finished message with its error if any.
+// Nothing is logged if the debug level is disabled for the logger.
+func WithLogger(logger *slog.Logger) SourceOption {
+	return func(s *Source) {
+		s.logger = logger
+	}
+}
+
+// WithParserLogger makes [Parser] log parsing with the logger at debug level:
+// parsed parts with their headers and offsets, content bytes of parts and parsing errors.
+// Nothing is logged if the debug level is disabled for the logger.
+func WithParserLogger(logger *slog.Logger) ParserOption {
+	return func(p *Parser) {
+		p.logger = logger
+	}
+}
+
+// logPartStarted logs the part which heading is generated.
+func (s *Source) logPartStarted(part *Part) {
+	if !debugEnabled(s.context(), s.logger) {
+		return
+	}
+	s.logger.LogAttrs(s.context(), slog.LevelDebug, "multipart part started",
+		slog.Int("index", len(s.stats.PartSizes)-1),
+		slog.Any("header", part.Header),
+	)
+}
+
+// logPartFinished logs the part which content is generated or failed.
+func (s *Source) logPartFinished() {
+	if !debugEnabled(s.context(), s.logger) {
+		return
+	}
+	s.logger.LogAttrs(s.context(), slog.LevelDebug, "multipart part finished",
+		slog.Int("index", len(s.stats.PartSizes)-1),
+		slog.Int64("content_bytes", s.stats.PartSizes[len(s.stats.PartSizes)-1]),
+	)
+}
+
+// logFinished logs the finished message, err is nil or io.EOF on success.
+func (s *Source) logFinished(err error) {
+	if !debugEnabled(s.context(), s.logger) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("boundary", s.boundary),
+		slog.Int("parts", len(s.stats.PartSizes)),
+		slog.Int64("total_bytes", s.stats.HeaderBytes+s.stats.ContentBytes),
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	s.logger.LogAttrs(s.context(), slog.LevelDebug, "multipart message finished", attrs...)
+}
+
+// logPart logs the part which headers are just parsed.
+func (p *Parser) logPart(part *Part) {
+	if !debugEnabled(context.Background(), p.logger) {
+		return
+	}
+	p.logger.LogAttrs(context.Background(), slog.LevelDebug, "multipart part parsed",
+		slog.Int("index", p.partsRead-1),
+		slog.Int64("offset", p.content.start),
+		slog.Any("header", part.Header),
+	)
+}
+
+// logPartFinished logs the content size of the previous part once its content is consumed.
+func (p *Parser) logPartFinished() {
+	if p.content == nil || !debugEnabled(context.Background(), p.logger) {
+		return
+	}
+	p.logger.LogAttrs(context.Background(), slog.LevelDebug, "multipart part finished",
+		slog.Int("index", p.partsRead-1),
+		slog.Int64("content_bytes", p.content.read),
+	)
+}
+
+// logDone logs the end of parsing, err is nil if the message is parsed completely.
+func (p *Parser) logDone(err error) {
+	if !debugEnabled(context.Background(), p.logger) {
+		return
+	}
+	attrs := []slog.Attr{slog.Int("parts", p.partsRead)}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	p.logger.LogAttrs(context.Background(), slog.LevelDebug, "multipart message parsed", attrs...)
+}
+
+func debugEnabled(ctx context.Context, logger *slog.Logger) bool {
+	return logger != nil && logger.Enabled(ctx, slog.LevelDebug)
+}
@@ -0,0 +1,81 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestWithLogger(t *testing.T) {
+	var logs, body bytes.Buffer
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "first"),
+		itermultipart.NewFieldPart("b", strings.Repeat("x", 10000)),
+	), itermultipart.WithLogger(newTestLogger(&logs)), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")))
+	if _, err := src.WriteTo(&body); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	want := []string{
+		`level=DEBUG msg="multipart part started" index=0 header="map[Content-Disposition:[form-data; name=a]]"`,
+		`level=DEBUG msg="multipart part finished" index=0 content_bytes=5`,
+		`level=DEBUG msg="multipart part started" index=1 header="map[Content-Disposition:[form-data; name=b]]"`,
+		`level=DEBUG msg="multipart part finished" index=1 content_bytes=10000`,
+		`level=DEBUG msg="multipart message finished" boundary=b1 parts=2 total_bytes=` + strconv.Itoa(body.Len()),
+	}
+	if got := strings.Split(strings.TrimSpace(logs.String()), "\n"); !slices.Equal(got, want) {
+		t.Errorf("got logs\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	logs.Reset()
+	parser := itermultipart.NewParser(bytes.NewReader(body.Bytes()), src.Boundary(),
+		itermultipart.WithParserLogger(newTestLogger(&logs)))
+	for _, err := range parser.Parts() {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+	}
+	want = []string{
+		`level=DEBUG msg="multipart part parsed" index=0 offset=48 header="map[Content-Disposition:[form-data; name=a]]"`,
+		`level=DEBUG msg="multipart part finished" index=0 content_bytes=5`,
+		`level=DEBUG msg="multipart part parsed" index=1 offset=103 header="map[Content-Disposition:[form-data; name=b]]"`,
+		`level=DEBUG msg="multipart part finished" index=1 content_bytes=10000`,
+		`level=DEBUG msg="multipart message parsed" parts=2`,
+	}
+	if got := strings.Split(strings.TrimSpace(logs.String()), "\n"); !slices.Equal(got, want) {
+		t.Errorf("got logs\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWithLoggerError(t *testing.T) {
+	var logs bytes.Buffer
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("a").SetContent(io.MultiReader(strings.NewReader("x"), iotest.ErrReader(errors.New("read failed")))),
+	), itermultipart.WithLogger(newTestLogger(&logs)))
+	if _, err := io.Copy(io.Discard, src); err == nil {
+		t.Fatal("error expected")
+	}
+	if !strings.Contains(logs.String(), `msg="multipart message finished"`) || !strings.Contains(logs.String(), "error=") {
+		t.Errorf("error is not logged:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,263 @@
+package itermultipart
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const tagName = "multipart"
+
+var (
+	readerType        = reflect.TypeFor[io.Reader]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// structField describes a struct field participating in marshaling.
+// Tag format is `multipart:"name,option,option=value"` where name defaults to the field name.
+// Supported options are:
+//   - omitempty: skip the field if it has a zero value;
+//   - file: always make file parts from the field;
+//   - filename=name.ext: file name of the file part;
+//   - type=media/type: content type of the file part.
+//
+// Tag "-" excludes the field.
+type structField struct {
+	index       []int
+	name        string
+	omitEmpty   bool
+	file        bool
+	fileName    string
+	contentType string
+}
+
+// structFields returns fields of the struct type including fields of embedded structs.
+func structFields(t reflect.Type) ([]structField, error) {
+	var fields []structField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, tagged := f.Tag.Lookup(tagName)
+		if tag == "-" {
+			continue
+		}
+
+		if f.Anonymous && !tagged {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded, err := structFields(ft)
+				if err != nil {
+					return nil, err
+				}
+				for _, ef := range embedded {
+					ef.index = append([]int{i}, ef.index...)
+					fields = append(fields, ef)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		sf := structField{index: []int{i}, name: f.Name}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name != "" {
+			sf.name = name
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			key, value, _ := strings.Cut(opt, "=")
+			switch key {
+			case "":
+			case "omitempty":
+				sf.omitEmpty = true
+			case "file":
+				sf.file = true
+			case "filename":
+				sf.fileName = value
+			case "type":
+				sf.contentType = value
+			default:
+				return nil, fmt.Errorf("field %s: unknown tag option %q", f.Name, key)
+			}
+		}
+		fields = append(fields, sf)
+	}
+	return fields, nil
+}
+
+// Marshal converts a struct or a pointer to a struct into a sequence of form parts using `multipart` field tags.
+// Strings, booleans, numbers and [encoding.TextMarshaler]s become form fields.
+// [io.Reader]s (like [os.File] or [fs.File]) and byte slices become file parts, file name is taken from the "filename"
+// tag option, file name of the [os.File] or [fs.File] or the form name otherwise.
+// Content type is taken from the "type" tag option or detected by the file name extension.
+// Slices and arrays produce a part per element, nil pointers and interfaces are skipped.
+// Readers are consumed by the [Source] so the sequence produces them only once.
+func Marshal(v any) (iter.Seq2[*Part, error], error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("multipart: Marshal(nil %s)", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("multipart: Marshal(non-struct %s)", rv.Type())
+	}
+
+	fields, err := structFields(rv.Type())
+	if err != nil {
+		return nil, fmt.Errorf("multipart: %w", err)
+	}
+	for _, f := range fields {
+		if ft := rv.Type().FieldByIndex(f.index).Type; !marshalable(ft) {
+			return nil, fmt.Errorf("multipart: field %s has unsupported type %s", f.name, ft)
+		}
+	}
+
+	return func(yield func(*Part, error) bool) {
+		for _, f := range fields {
+			fv, err := rv.FieldByIndexErr(f.index)
+			if err != nil {
+				continue // nil embedded pointer
+			}
+			if !marshalValue(f, fv, yield) {
+				return
+			}
+		}
+	}, nil
+}
+
+func marshalable(t reflect.Type) bool {
+	switch {
+	case t.Implements(readerType), t.Implements(textMarshalerType), t.Kind() == reflect.Interface:
+		return true
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return marshalable(t.Elem())
+	default:
+		return false
+	}
+}
+
+// marshalValue yields parts for the value, reports false if iteration must stop.
+func marshalValue(f structField, v reflect.Value, yield func(*Part, error) bool) bool {
+	if f.omitEmpty && v.IsZero() {
+		return true
+	}
+
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return true
+	}
+
+	switch x := v.Interface().(type) {
+	case io.Reader:
+		return yield(f.filePart(readerFileName(x)).SetContent(x), nil)
+	case []byte:
+		return yield(f.filePart("").SetContentBytes(x), nil)
+	case encoding.TextMarshaler:
+		text, err := x.MarshalText()
+		if err != nil {
+			return yield(nil, fmt.Errorf("multipart: field %s: %w", f.name, err))
+		}
+		return yield(f.fieldPart(text), nil)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return yield(f.filePart("").SetContentBytes(v.Bytes()), nil) // named byte slice type
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.Kind() == reflect.Interface && !marshalable(v.Elem().Type()) {
+			return yield(nil, fmt.Errorf("multipart: field %s has unsupported type %s", f.name, v.Elem().Type()))
+		}
+		return marshalValue(f, v.Elem(), yield)
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if !marshalValue(f, v.Index(i), yield) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var s string
+	switch v.Kind() {
+	case reflect.String:
+		s = v.String()
+	case reflect.Bool:
+		s = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	default:
+		return yield(nil, fmt.Errorf("multipart: field %s has unsupported type %s", f.name, v.Type()))
+	}
+	return yield(f.fieldPart([]byte(s)), nil)
+}
+
+// fieldPart creates a part for the text value, it's a file part only if the "file" option is set.
+func (f structField) fieldPart(value []byte) *Part {
+	if f.file {
+		return f.filePart("").SetContentBytes(value)
+	}
+	return NewPart().SetFormName(f.name).SetContentBytes(value)
+}
+
+// filePart creates a file part, defaultFileName is used if the tag doesn't define it.
+func (f structField) filePart(defaultFileName string) *Part {
+	fileName := f.fileName
+	if fileName == "" {
+		fileName = defaultFileName
+	}
+	if fileName == "" {
+		fileName = f.name
+	}
+
+	p := NewPart().SetFormName(f.name).SetFileName(fileName)
+	if f.contentType != "" {
+		return p.SetContentType(f.contentType)
+	}
+	return p.SetContentTypeByExtension()
+}
+
+// readerFileName returns the base name of the file if the reader is a file.
+func readerFileName(r io.Reader) string {
+	var name string
+	switch file := r.(type) {
+	case interface{ Name() string }:
+		name = file.Name()
+	case fs.File:
+		if info, err := file.Stat(); err == nil {
+			name = info.Name()
+		}
+	}
+	if name == "" {
+		return ""
+	}
+	return filepath.Base(name)
+}
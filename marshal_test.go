@@ -0,0 +1,155 @@
+package itermultipart_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xakep666/itermultipart"
+)
+
+type marshalBase struct {
+	ID int `multipart:"id"`
+}
+
+type marshalTarget struct {
+	marshalBase
+
+	Name     string    `multipart:"name"`
+	Tags     []string  `multipart:"tag"`
+	Enabled  bool      `multipart:"enabled"`
+	Ratio    float64   `multipart:"ratio"`
+	Optional *string   `multipart:"optional"`
+	Empty    string    `multipart:"empty,omitempty"`
+	When     time.Time `multipart:"when"`
+	Note     string    `multipart:"note,file,filename=note.txt"`
+	Avatar   io.Reader `multipart:"avatar,filename=avatar.png"`
+	Raw      []byte    `multipart:"raw,type=application/x-raw"`
+	Document *os.File  `multipart:"document"`
+	Skipped  string    `multipart:"-"`
+	Untagged string
+	hidden   string
+}
+
+func TestMarshal(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "doc.txt"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+	f.WriteString("document")
+	f.Seek(0, io.SeekStart)
+
+	v := marshalTarget{
+		marshalBase: marshalBase{ID: 42},
+		Name:        "name",
+		Tags:        []string{"a", "b"},
+		Enabled:     true,
+		Ratio:       0.5,
+		When:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Note:        "note",
+		Avatar:      strings.NewReader("png"),
+		Raw:         []byte("raw"),
+		Document:    f,
+		Skipped:     "skipped",
+		Untagged:    "untagged",
+		hidden:      "hidden",
+	}
+
+	parts, err := itermultipart.Marshal(&v)
+	if err != nil {
+		t.Fatalf("Marshal: unexpected error %s", err)
+	}
+
+	var got []string
+	for part, err := range parts {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		content, _ := io.ReadAll(part.Content)
+		got = append(got, fmt.Sprintf("%s|%s|%s|%s", part.FormName(), part.FileName(), part.ContentType(), content))
+	}
+
+	want := []string{
+		"id|||42",
+		"name|||name",
+		"tag|||a",
+		"tag|||b",
+		"enabled|||true",
+		"ratio|||0.5",
+		"when|||2024-01-02T03:04:05Z",
+		"note|note.txt|text/plain; charset=utf-8|note",
+		"avatar|avatar.png|image/png|png",
+		"raw|raw|application/x-raw|raw",
+		"document|doc.txt|text/plain; charset=utf-8|document",
+		"Untagged|||untagged",
+	}
+	if g, e := strings.Join(got, "\n"), strings.Join(want, "\n"); g != e {
+		t.Errorf("got parts:\n%s\nwant:\n%s", g, e)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"not a struct", 42},
+		{"nil pointer", (*marshalTarget)(nil)},
+		{"unsupported type", struct{ M map[string]string }{}},
+		{"unknown option", struct {
+			A string `multipart:"a,unknown"`
+		}{}},
+	}
+	for _, tt := range tests {
+		if _, err := itermultipart.Marshal(tt.v); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func ExampleMarshal() {
+	type Upload struct {
+		Title string    `multipart:"title"`
+		Tags  []string  `multipart:"tag,omitempty"`
+		File  io.Reader `multipart:"file,filename=hello.txt"`
+	}
+
+	parts, err := itermultipart.Marshal(Upload{
+		Title: "greeting",
+		Tags:  []string{"hello", "world"},
+		File:  strings.NewReader("Hello, World!"),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	src := itermultipart.NewSource(parts)
+	src.SetBoundary("boundary")
+	var sb strings.Builder
+	src.WriteTo(&sb)
+	fmt.Println(strings.ReplaceAll(sb.String(), "\r\n", "\n"))
+	// Output:
+	// --boundary
+	// Content-Disposition: form-data; name=title
+	//
+	// greeting
+	// --boundary
+	// Content-Disposition: form-data; name=tag
+	//
+	// hello
+	// --boundary
+	// Content-Disposition: form-data; name=tag
+	//
+	// world
+	// --boundary
+	// Content-Disposition: form-data; filename=hello.txt; name=file
+	// Content-Type: text/plain; charset=utf-8
+	//
+	// Hello, World!
+	// --boundary--
+}
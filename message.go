@@ -0,0 +1,146 @@
+package itermultipart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"mime"
+	"strings"
+)
+
+// Message bundles a multipart message: its media type, boundary, preamble, epilogue and parts,
+// so it can be passed around as a single value.
+type Message struct {
+	Subtype  string            // subtype of the multipart media type, i.e. "form-data" or "mixed"
+	Params   map[string]string // Content-Type parameters except the boundary
+	Boundary string
+
+	Preamble []byte // text before the first part, ignored by receivers
+	Epilogue []byte // text after the last part, ignored by receivers
+
+	Parts iter.Seq2[*Part, error]
+}
+
+// NewMessage creates a [Message] of the given subtype with a random boundary.
+func NewMessage(subtype string, parts iter.Seq2[*Part, error]) *Message {
+	return &Message{
+		Subtype:  subtype,
+		Boundary: NewSource(parts).Boundary(),
+		Parts:    parts,
+	}
+}
+
+// ContentType returns the Content-Type header value of the message.
+func (m *Message) ContentType() string {
+	params := make(map[string]string, len(m.Params)+1)
+	maps.Copy(params, m.Params)
+	params["boundary"] = m.Boundary
+	return mime.FormatMediaType("multipart/"+m.Subtype, params)
+}
+
+// Source returns the [Source] generating parts of the message. Preamble and epilogue are not generated by it.
+func (m *Message) Source() (*Source, error) {
+	src := NewSource(m.Parts)
+	if err := src.SetBoundary(m.Boundary); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// Encode writes the message including the preamble and the epilogue to w.
+func (m *Message) Encode(w io.Writer) error {
+	src, err := m.Source()
+	if err != nil {
+		return err
+	}
+
+	target := w
+	if len(m.Preamble) > 0 {
+		if _, err := w.Write(m.Preamble); err != nil {
+			return err
+		}
+		target = &preambleEndWriter{w: w}
+	}
+	if _, err := src.WriteTo(target); err != nil {
+		return err
+	}
+	if len(m.Epilogue) > 0 {
+		if _, err := w.Write(m.Epilogue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMessage reads the multipart message with the given Content-Type header value from r using [Parser].
+// The preamble is read immediately, parts are parsed while the Parts sequence is iterated,
+// the epilogue is filled after the whole sequence is iterated.
+// Preamble and epilogue are limited by [WithMaxHeaderBytes].
+func DecodeMessage(r io.Reader, contentType string, opts ...ParserOption) (*Message, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("multipart: %w", err)
+	}
+	subtype, ok := strings.CutPrefix(mediaType, "multipart/")
+	if !ok {
+		return nil, fmt.Errorf("multipart: not a multipart media type %q", mediaType)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart: no boundary in Content-Type")
+	}
+	delete(params, "boundary")
+
+	parser := NewParser(r, boundary, opts...)
+	parser.capturePreamble = true
+	if err := parser.skipPreamble(); err != nil {
+		return nil, err
+	}
+
+	m := &Message{
+		Subtype:  subtype,
+		Params:   params,
+		Boundary: boundary,
+		Preamble: parser.preamble,
+	}
+	m.Parts = func(yield func(*Part, error) bool) {
+		for part, err := range parser.Parts() {
+			if !yield(part, err) || err != nil {
+				return
+			}
+		}
+
+		if m.Epilogue != nil {
+			return // already read by the previous iteration
+		}
+		epilogue, err := parser.readEpilogue()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		m.Epilogue = epilogue
+	}
+	return m, nil
+}
+
+// preambleEndWriter separates the preamble from the first delimiter with CRLF
+// unless the message already starts with it (the closing delimiter of the message without parts).
+type preambleEndWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (pw *preambleEndWriter) Write(p []byte) (int, error) {
+	if !pw.written && len(p) > 0 {
+		pw.written = true
+		if !bytes.HasPrefix(p, []byte("\r\n")) {
+			if _, err := io.WriteString(pw.w, "\r\n"); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return pw.w.Write(p)
+}
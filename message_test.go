@@ -0,0 +1,108 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name               string
+		preamble, epilogue string
+		parts              []*itermultipart.Part
+	}{
+		{"plain", "", "", []*itermultipart.Part{
+			itermultipart.NewPart().SetContentType("text/plain").SetContentString("plain"),
+			itermultipart.NewPart().SetContentType("text/html").SetContentString("<b>html</b>"),
+		}},
+		{"preamble and epilogue", "This is a multi-part message in MIME format.\r\n--not a delimiter", "--epilogue\r\n", []*itermultipart.Part{
+			itermultipart.NewPart().SetContentString("text"),
+		}},
+		{"no parts", "preamble", "epilogue", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := itermultipart.NewMessage("alternative", itermultipart.PartSeq(tt.parts...))
+			m.Params = map[string]string{"charset": "utf-8"}
+			m.Preamble, m.Epilogue = []byte(tt.preamble), []byte(tt.epilogue)
+
+			var buf bytes.Buffer
+			if err := m.Encode(&buf); err != nil {
+				t.Fatalf("Encode: unexpected error %s", err)
+			}
+			encoded := buf.String()
+
+			// standard library must see the same parts
+			want, err := collectParts(t, itermultipart.PartsFromReader(multipart.NewReader(strings.NewReader(encoded), m.Boundary), true))
+			if err != nil {
+				t.Fatalf("mime/multipart: unexpected error %s", err)
+			}
+
+			decoded, err := itermultipart.DecodeMessage(&buf, m.ContentType())
+			if err != nil {
+				t.Fatalf("DecodeMessage: unexpected error %s", err)
+			}
+			if decoded.Subtype != "alternative" || decoded.Boundary != m.Boundary || decoded.Params["charset"] != "utf-8" || len(decoded.Params) != 1 {
+				t.Errorf("unexpected content type: %s %s %v", decoded.Subtype, decoded.Boundary, decoded.Params)
+			}
+			if string(decoded.Preamble) != tt.preamble {
+				t.Errorf("preamble %q, want %q", decoded.Preamble, tt.preamble)
+			}
+			got, err := collectParts(t, decoded.Parts)
+			if err != nil {
+				t.Fatalf("parse: unexpected error %s", err)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("\n got: %q\nwant: %q", got, want)
+			}
+			if string(decoded.Epilogue) != tt.epilogue {
+				t.Errorf("epilogue %q, want %q", decoded.Epilogue, tt.epilogue)
+			}
+
+			var reencoded bytes.Buffer
+			for i, part := range tt.parts {
+				part.SetContentString(want[i].content) // original contents are consumed
+			}
+			decoded.Parts = itermultipart.PartSeq(tt.parts...)
+			if err := decoded.Encode(&reencoded); err != nil {
+				t.Fatalf("Encode: unexpected error %s", err)
+			}
+			if reencoded.String() != encoded {
+				t.Errorf("re-encoded message differs:\n got: %q\nwant: %q", reencoded.String(), encoded)
+			}
+		})
+	}
+}
+
+func TestDecodeMessageErrors(t *testing.T) {
+	for _, contentType := range []string{"text/plain", "multipart/mixed", "multipart/mixed; boundary=\"unterminated"} {
+		if _, err := itermultipart.DecodeMessage(strings.NewReader("--b--"), contentType); err == nil {
+			t.Errorf("%s: expected error", contentType)
+		}
+	}
+}
+
+func ExampleDecodeMessage() {
+	message := "preamble\r\n--b\r\nContent-Type: text/plain\r\n\r\nhello\r\n--b--\r\nepilogue"
+	m, err := itermultipart.DecodeMessage(strings.NewReader(message), "multipart/mixed; boundary=b")
+	if err != nil {
+		panic(err)
+	}
+
+	for part, err := range m.Parts {
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("part:", part.ContentType())
+	}
+	fmt.Printf("%s, preamble: %q, epilogue: %q\n", m.ContentType(), m.Preamble, m.Epilogue)
+	// Output:
+	// part: text/plain
+	// multipart/mixed; boundary=b, preamble: "preamble", epilogue: "epilogue"
+}
@@ -0,0 +1,136 @@
+package itermultipart
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"iter"
+	"slices"
+)
+
+// MetadataExtractor extracts metadata of file parts, i.e. page count or image dimensions, while their contents stream.
+// Create it with [PeekExtractor] or [StreamExtractor].
+type MetadataExtractor struct {
+	peekSize  int
+	peek      func(part *Part, head []byte)
+	newWriter func(part *Part) MetadataWriter
+}
+
+// MetadataWriter receives the whole content of the file part as it's read.
+// Finish is called once the content is read completely to attach the metadata to the part.
+type MetadataWriter interface {
+	io.Writer
+	Finish(part *Part)
+}
+
+// PeekExtractor creates the [MetadataExtractor] getting up to size first bytes of the content.
+// It's called before the part is yielded, so the metadata is available to the consumer right away.
+func PeekExtractor(size int, extract func(part *Part, head []byte)) MetadataExtractor {
+	return MetadataExtractor{peekSize: size, peek: extract}
+}
+
+// StreamExtractor creates the [MetadataExtractor] getting the whole content while the consumer reads it.
+// newWriter may return nil to skip the part. The metadata is available after the content is read to the end.
+// If the writer fails, it gets no more data and Finish is not called.
+func StreamExtractor(newWriter func(part *Part) MetadataWriter) MetadataExtractor {
+	return MetadataExtractor{newWriter: newWriter}
+}
+
+// SetMeta sets the metadata value of the part.
+func (p *Part) SetMeta(key string, value any) *Part {
+	if p.Meta == nil {
+		p.Meta = make(map[string]any)
+	}
+	p.Meta[key] = value
+	return p
+}
+
+// ExtractMetadata runs the extractors over contents of file parts, ones having a file name,
+// without a second read of the content. Extractors attach the metadata to [Part.Meta].
+// Parts are not modified permanently except for the metadata: the content is restored after the part is yielded.
+func ExtractMetadata(parts iter.Seq2[*Part, error], extractors ...MetadataExtractor) iter.Seq2[*Part, error] {
+	peekSize := 0
+	for _, e := range extractors {
+		peekSize = max(peekSize, e.peekSize)
+	}
+
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if part.FileName() == "" || part.Content == nil {
+				if !yield(part, nil) {
+					return
+				}
+				continue
+			}
+
+			content := part.Content
+			extracted := content
+			if peekSize > 0 {
+				br := bufio.NewReaderSize(content, peekSize)
+				head, err := br.Peek(peekSize)
+				if err != nil && !errors.Is(err, io.EOF) {
+					if !yield(nil, err) {
+						return
+					}
+					continue
+				}
+				for _, e := range extractors {
+					if e.peek != nil {
+						e.peek(part, head[:min(len(head), e.peekSize)])
+					}
+				}
+				extracted = br
+			}
+
+			var writers []MetadataWriter
+			for _, e := range extractors {
+				if e.newWriter == nil {
+					continue
+				}
+				if w := e.newWriter(part); w != nil {
+					writers = append(writers, w)
+				}
+			}
+			if len(writers) > 0 {
+				extracted = &extractingReader{r: extracted, part: part, writers: writers}
+			}
+
+			part.Content = extracted
+			next := yield(part, nil)
+			part.Content = content
+			if !next {
+				return
+			}
+		}
+	}
+}
+
+// extractingReader feeds everything read to the metadata writers and finishes them on EOF.
+type extractingReader struct {
+	r       io.Reader
+	part    *Part
+	writers []MetadataWriter // nil after finishing
+}
+
+func (er *extractingReader) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if n > 0 {
+		er.writers = slices.DeleteFunc(er.writers, func(w MetadataWriter) bool {
+			_, werr := w.Write(p[:n])
+			return werr != nil
+		})
+	}
+	if errors.Is(err, io.EOF) {
+		for _, w := range er.writers {
+			w.Finish(er.part)
+		}
+		er.writers = nil
+	}
+	return n, err
+}
@@ -0,0 +1,93 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// lineCounter counts lines of text files.
+type lineCounter struct {
+	lines int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	c.lines += bytes.Count(p, []byte("\n"))
+	return len(p), nil
+}
+
+func (c *lineCounter) Finish(part *itermultipart.Part) {
+	part.SetMeta("lines", c.lines)
+}
+
+func TestExtractMetadata(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	png = binary.BigEndian.AppendUint32(png, 640)
+	png = binary.BigEndian.AppendUint32(png, 480)
+	png = append(png, "rest of the image"...)
+	text := bytes.Repeat([]byte("line\n"), 10000)
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("field", "value"),
+		itermultipart.NewPart().SetFormName("image").SetFileName("image.png").SetContentBytes(png),
+		itermultipart.NewPart().SetFormName("text").SetFileName("text.txt").SetContentBytes(text),
+	))
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+
+	parts := itermultipart.ExtractMetadata(itermultipart.NewParser(&buf, src.Boundary()).Parts(),
+		itermultipart.PeekExtractor(24, func(part *itermultipart.Part, head []byte) {
+			if len(head) == 24 && bytes.HasPrefix(head, []byte("\x89PNG")) {
+				part.SetMeta("width", int(binary.BigEndian.Uint32(head[16:]))).
+					SetMeta("height", int(binary.BigEndian.Uint32(head[20:])))
+			}
+		}),
+		itermultipart.StreamExtractor(func(part *itermultipart.Part) itermultipart.MetadataWriter {
+			if part.FileName() != "text.txt" {
+				return nil
+			}
+			return new(lineCounter)
+		}),
+	)
+
+	var names []string
+	for part, err := range parts {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		names = append(names, part.FormName())
+		switch part.FormName() {
+		case "image":
+			if part.Meta["width"] != 640 || part.Meta["height"] != 480 {
+				t.Errorf("unexpected image metadata %v", part.Meta)
+			}
+			content, err := io.ReadAll(part.Content)
+			if err != nil || !bytes.Equal(content, png) {
+				t.Errorf("image content is changed: %q, %v", content, err)
+			}
+		case "text":
+			if part.Meta != nil {
+				t.Errorf("metadata %v is available before reading", part.Meta)
+			}
+			content, err := io.ReadAll(part.Content)
+			if err != nil || !bytes.Equal(content, text) {
+				t.Errorf("text content is changed: %v", err)
+			}
+			if part.Meta["lines"] != 10000 {
+				t.Errorf("unexpected text metadata %v", part.Meta)
+			}
+		default:
+			if part.Meta != nil {
+				t.Errorf("unexpected metadata %v of the field", part.Meta)
+			}
+		}
+	}
+	if len(names) != 3 {
+		t.Errorf("got parts %v", names)
+	}
+}
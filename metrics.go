@@ -0,0 +1,81 @@
+package itermultipart
+
+// MetricsHook receives events of message generation or parsing, i.e. to feed counters and histograms
+// of any metrics backend. Methods are called synchronously from the generating or parsing goroutine.
+type MetricsHook interface {
+	// PartStarted is called when the part heading is generated or the part headers are parsed.
+	PartStarted()
+	// PartFinished is called with the number of content bytes when the part content is generated or consumed,
+	// also when generation of the part fails.
+	PartFinished(bytes int64)
+	// SourceFinished is called once per message with the number of message bytes when the message is generated
+	// or parsed completely or fails. The [Parser] counts bytes up to the closing delimiter, the epilogue is ignored.
+	SourceFinished(total int64)
+}
+
+// WithMetrics sets the hook receiving events of the message generation. No events are produced if unset.
+func WithMetrics(hook MetricsHook) SourceOption {
+	return func(s *Source) {
+		s.metrics = hook
+	}
+}
+
+// WithParserMetrics sets the hook receiving events of parsing. No events are produced if unset.
+func WithParserMetrics(hook MetricsHook) ParserOption {
+	return func(p *Parser) {
+		p.metrics = hook
+	}
+}
+
+// partStarted is called when the heading of the part is generated.
+func (s *Source) partStarted(part *Part) {
+	s.partActive = true
+	s.logPartStarted(part)
+	if s.metrics != nil {
+		s.metrics.PartStarted()
+	}
+}
+
+// partFinished is called when the content of the part is generated or generation of the part failed.
+func (s *Source) partFinished() {
+	if !s.partActive {
+		return
+	}
+	s.partActive = false
+	s.logPartFinished()
+	if s.metrics != nil {
+		s.metrics.PartFinished(s.stats.PartSizes[len(s.stats.PartSizes)-1])
+	}
+}
+
+// messageFinished is called once per message when it's generated or failed, err is nil or io.EOF on success.
+func (s *Source) messageFinished(err error) {
+	s.logFinished(err)
+	if s.metrics != nil {
+		s.metrics.SourceFinished(s.stats.HeaderBytes + s.stats.ContentBytes)
+	}
+}
+
+// partParsed is called when headers of the part are parsed.
+func (p *Parser) partParsed(part *Part) {
+	p.logPart(part)
+	if p.metrics != nil {
+		p.metrics.PartStarted()
+	}
+}
+
+// partConsumed is called when the content of the previous part is consumed.
+func (p *Parser) partConsumed() {
+	p.logPartFinished()
+	if p.metrics != nil && p.content != nil {
+		p.metrics.PartFinished(p.content.read)
+	}
+}
+
+// parseDone is called when parsing ends, err is nil if the message is parsed completely.
+func (p *Parser) parseDone(err error) {
+	p.logDone(err)
+	if p.metrics != nil {
+		p.metrics.SourceFinished(p.offset())
+	}
+}
@@ -0,0 +1,57 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// recordingHook records metrics events as strings.
+type recordingHook struct {
+	events []string
+}
+
+func (h *recordingHook) PartStarted() {
+	h.events = append(h.events, "started")
+}
+
+func (h *recordingHook) PartFinished(bytes int64) {
+	h.events = append(h.events, fmt.Sprintf("finished %d", bytes))
+}
+
+func (h *recordingHook) SourceFinished(total int64) {
+	h.events = append(h.events, fmt.Sprintf("source finished %d", total))
+}
+
+func TestWithMetrics(t *testing.T) {
+	var body bytes.Buffer
+	hook := &recordingHook{}
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "first"),
+		itermultipart.NewFieldPart("b", strings.Repeat("x", 10000)),
+	), itermultipart.WithMetrics(hook))
+	if _, err := src.WriteTo(&body); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := []string{"started", "finished 5", "started", "finished 10000", fmt.Sprintf("source finished %d", body.Len())}
+	if !slices.Equal(hook.events, want) {
+		t.Errorf("got events %q, want %q", hook.events, want)
+	}
+
+	hook.events = nil
+	parser := itermultipart.NewParser(bytes.NewReader(body.Bytes()), src.Boundary(), itermultipart.WithParserMetrics(hook))
+	for _, err := range parser.Parts() {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+	}
+	// the closing "--" and the following CRLF belong to the ignored epilogue
+	want[len(want)-1] = fmt.Sprintf("source finished %d", body.Len()-len("--\r\n"))
+	if !slices.Equal(hook.events, want) {
+		t.Errorf("got events %q, want %q", hook.events, want)
+	}
+}
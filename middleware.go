@@ -0,0 +1,75 @@
+package itermultipart
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrPartsConsumed is yielded by [RequestParts.Parts] if the request body was already consumed.
+var ErrPartsConsumed = errors.New("multipart request body is already consumed")
+
+// RequestParts is the multipart body of the request attached to its context by [PartsMiddleware].
+// The body is parsed lazily when [RequestParts.Parts] is iterated.
+type RequestParts struct {
+	MediaType string // i.e. "multipart/form-data"
+	Boundary  string
+	Limits    Limits // enforced with LimitedParts
+
+	body     io.Reader
+	opts     []ParserOption
+	consumed atomic.Bool
+}
+
+// Parts returns the sequence of parts of the request body parsed with [Parser] and limited with [LimitedParts].
+// The body can be consumed only once, the sequence yields [ErrPartsConsumed] if it was already iterated.
+func (rp *RequestParts) Parts() iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		if !rp.consumed.CompareAndSwap(false, true) {
+			yield(nil, ErrPartsConsumed)
+			return
+		}
+		for part, err := range LimitedParts(NewParser(rp.body, rp.Boundary, rp.opts...).Parts(), rp.Limits) {
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+type partsContextKey struct{}
+
+// PartsMiddleware returns the middleware attaching [RequestParts] to the context of multipart requests,
+// so downstream handlers get the body with [PartsFromContext] and share the same limits and parser options.
+// Requests without a multipart Content-Type with a boundary are passed through unchanged.
+func PartsMiddleware(limits Limits, opts ...ParserOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, params, err := mime.ParseMediaType(r.Header.Get(contentTypeHeader))
+			if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" || r.Body == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rp := &RequestParts{
+				MediaType: mediaType,
+				Boundary:  params["boundary"],
+				Limits:    limits,
+				body:      r.Body,
+				opts:      opts,
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), partsContextKey{}, rp)))
+		})
+	}
+}
+
+// PartsFromContext returns the request body attached by [PartsMiddleware], false if the request is not multipart.
+func PartsFromContext(ctx context.Context) (*RequestParts, bool) {
+	rp, ok := ctx.Value(partsContextKey{}).(*RequestParts)
+	return rp, ok
+}
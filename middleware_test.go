@@ -0,0 +1,76 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestPartsMiddleware(t *testing.T) {
+	var (
+		names    []string
+		reused   error
+		attached bool
+	)
+	handler := itermultipart.PartsMiddleware(itermultipart.Limits{MaxPartSize: 5})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rp, ok := itermultipart.PartsFromContext(r.Context())
+		if attached = ok; !ok {
+			return
+		}
+		for part, err := range rp.Parts() {
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if _, err := io.Copy(io.Discard, part.Content); err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			names = append(names, part.FormName())
+		}
+		for _, err := range rp.Parts() {
+			reused = err
+		}
+	}))
+
+	newRequest := func(parts ...*itermultipart.Part) *http.Request {
+		var body bytes.Buffer
+		src := itermultipart.NewSource(itermultipart.PartSeq(parts...))
+		if _, err := src.WriteTo(&body); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", &body)
+		req.Header.Set("Content-Type", src.FormDataContentType())
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest(itermultipart.NewFieldPart("a", "1"), itermultipart.NewFieldPart("b", "2")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+	if !attached || strings.Join(names, ",") != "a,b" {
+		t.Errorf("unexpected parts %q", names)
+	}
+	if !errors.Is(reused, itermultipart.ErrPartsConsumed) {
+		t.Errorf("unexpected error of the second iteration %v", reused)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest(itermultipart.NewFieldPart("a", "too large")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("limit is not applied, status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a=1")))
+	if attached {
+		t.Error("parts are attached to non-multipart request")
+	}
+}
@@ -0,0 +1,199 @@
+// Package otelmultipart instruments [itermultipart] with OpenTelemetry: it creates a span per generated or parsed part
+// and records the number of parts and content bytes. It's a separate module, so the main package stays dependency-free.
+package otelmultipart
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// ScopeName is the instrumentation scope name of tracers and meters.
+const ScopeName = "github.com/xakep666/itermultipart/otelmultipart"
+
+const (
+	directionGenerate = "generate"
+	directionParse    = "parse"
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures [Instrumentation].
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider, the global one is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider, the global one is used by default.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// Instrumentation creates spans and records metrics for part sequences. It's safe for concurrent use.
+//
+// Metrics are recorded with the "multipart.direction" attribute set to "generate" or "parse":
+//   - multipart.parts - counter of parts;
+//   - multipart.content.bytes - counter of content bytes;
+//   - multipart.part.size - histogram of part content sizes.
+type Instrumentation struct {
+	tracer   trace.Tracer
+	parts    metric.Int64Counter
+	bytes    metric.Int64Counter
+	partSize metric.Int64Histogram
+}
+
+// New returns the [Instrumentation] using global providers unless they are set by options.
+func New(opts ...Option) (*Instrumentation, error) {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	meter := c.meterProvider.Meter(ScopeName)
+	parts, err := meter.Int64Counter("multipart.parts",
+		metric.WithDescription("Number of multipart parts"), metric.WithUnit("{part}"))
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := meter.Int64Counter("multipart.content.bytes",
+		metric.WithDescription("Number of multipart part content bytes"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	partSize, err := meter.Int64Histogram("multipart.part.size",
+		metric.WithDescription("Size of multipart part contents"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Instrumentation{
+		tracer:   c.tracerProvider.Tracer(ScopeName),
+		parts:    parts,
+		bytes:    bytes,
+		partSize: partSize,
+	}, nil
+}
+
+// NewSource returns the [itermultipart.Source] generating parts with a "multipart.part generate" span per part,
+// spans are children of the span in ctx. Content bytes are measured with [itermultipart.WithProgress],
+// so the progress callback passed in opts is replaced.
+func (in *Instrumentation) NewSource(ctx context.Context, parts iter.Seq2[*itermultipart.Part, error], opts ...itermultipart.SourceOption) *itermultipart.Source {
+	var partBytes int64
+	track := func(*itermultipart.Part) func() (int64, error) {
+		partBytes = 0
+		return func() (int64, error) { return partBytes, nil }
+	}
+	progress := itermultipart.WithProgress(func(part *itermultipart.Part, n, _ int64) {
+		if part != nil {
+			partBytes = n
+		}
+	})
+	return itermultipart.NewSource(in.instrument(ctx, directionGenerate, parts, track), append(opts, progress)...)
+}
+
+// Parts returns the sequence of parsed parts with a "multipart.part parse" span per part,
+// spans are children of the span in ctx. A span ends when the next part is requested,
+// content read errors are recorded to it.
+func (in *Instrumentation) Parts(ctx context.Context, parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+	track := func(part *itermultipart.Part) func() (int64, error) {
+		content := part.Content
+		if content == nil {
+			return func() (int64, error) { return 0, nil }
+		}
+		cr := &countingReader{r: content}
+		part.Content = cr
+		return func() (int64, error) {
+			part.Content = content
+			return cr.n, cr.err
+		}
+	}
+	return in.instrument(ctx, directionParse, parts, track)
+}
+
+// instrument wraps every part yield with a span, track prepares measuring of the part and returns the function
+// reporting content bytes and the error after the part is processed.
+func (in *Instrumentation) instrument(
+	ctx context.Context,
+	direction string,
+	parts iter.Seq2[*itermultipart.Part, error],
+	track func(part *itermultipart.Part) func() (int64, error),
+) iter.Seq2[*itermultipart.Part, error] {
+	return func(yield func(*itermultipart.Part, error) bool) {
+		directionAttr := attribute.String("multipart.direction", direction)
+		index := 0
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			_, span := in.tracer.Start(ctx, "multipart.part "+direction, trace.WithAttributes(
+				directionAttr,
+				attribute.Int("multipart.part.index", index),
+				attribute.String("multipart.part.form_name", part.FormName()),
+				attribute.String("multipart.part.content_type", part.ContentType()),
+			))
+			finish := track(part)
+			next := yield(part, nil)
+			n, contentErr := finish()
+			in.record(ctx, span, directionAttr, n, contentErr)
+			index++
+			if !next {
+				return
+			}
+		}
+	}
+}
+
+func (in *Instrumentation) record(ctx context.Context, span trace.Span, directionAttr attribute.KeyValue, n int64, err error) {
+	span.SetAttributes(attribute.Int64("multipart.part.content_bytes", n))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	attrs := metric.WithAttributes(directionAttr)
+	in.parts.Add(ctx, 1, attrs)
+	in.bytes.Add(ctx, n, attrs)
+	in.partSize.Record(ctx, n, attrs)
+}
+
+// countingReader counts content bytes and keeps the read error.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		cr.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,108 @@
+package otelmultipart_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/xakep666/itermultipart"
+	"github.com/xakep666/itermultipart/otelmultipart"
+)
+
+func newInstrumentation(t *testing.T) (*otelmultipart.Instrumentation, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	in, err := otelmultipart.New(
+		otelmultipart.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		otelmultipart.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	return in, spans, reader
+}
+
+func TestInstrumentation(t *testing.T) {
+	ctx := context.Background()
+	in, spans, reader := newInstrumentation(t)
+
+	var body bytes.Buffer
+	src := in.NewSource(ctx, itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "first"),
+		itermultipart.NewFieldPart("b", strings.Repeat("x", 10000)),
+	))
+	if _, err := src.WriteTo(&body); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	parser := itermultipart.NewParser(&body, src.Boundary())
+	for part, err := range in.Parts(ctx, parser.Parts()) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if _, err := io.Copy(io.Discard, part.Content); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+	}
+
+	ended := spans.Ended()
+	wantSpans := []struct {
+		name  string
+		bytes int64
+	}{
+		{"multipart.part generate", 5},
+		{"multipart.part generate", 10000},
+		{"multipart.part parse", 5},
+		{"multipart.part parse", 10000},
+	}
+	if len(ended) != len(wantSpans) {
+		t.Fatalf("got %d spans, want %d", len(ended), len(wantSpans))
+	}
+	for i, want := range wantSpans {
+		if ended[i].Name() != want.name {
+			t.Errorf("span %d: got name %q, want %q", i, ended[i].Name(), want.name)
+		}
+		if got := spanAttribute(ended[i].Attributes(), "multipart.part.content_bytes"); got.AsInt64() != want.bytes {
+			t.Errorf("span %d: got %d content bytes, want %d", i, got.AsInt64(), want.bytes)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != "multipart.content.bytes" {
+			continue
+		}
+		sum := m.Data.(metricdata.Sum[int64])
+		for _, dp := range sum.DataPoints {
+			if dp.Value != 10005 {
+				t.Errorf("%v: got %d content bytes, want 10005", dp.Attributes.ToSlice(), dp.Value)
+			}
+		}
+		if len(sum.DataPoints) != 2 {
+			t.Errorf("got %d data points, want 2", len(sum.DataPoints))
+		}
+		return
+	}
+	t.Error("multipart.content.bytes metric is not recorded")
+}
+
+func spanAttribute(attrs []attribute.KeyValue, key attribute.Key) attribute.Value {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
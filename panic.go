@@ -0,0 +1,43 @@
+package itermultipart
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned by [Source] created with [WithPanicRecovery] when user code panics:
+// the part sequence, a part condition or a content factory.
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the goroutine at the moment of the panic
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("multipart: panic while generating message: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it's an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// WithPanicRecovery makes [Source] recover panics raised while generating the message and return them as [*PanicError].
+// The error is sticky: all further reads return it until [Source.Rewind] or [Source.Reset].
+// It prevents a faulty part sequence from crashing the whole program,
+// i.e. when the [Source] is read by [net/http.Client] in its own goroutine.
+func WithPanicRecovery() SourceOption {
+	return func(s *Source) {
+		s.recoverPanics = true
+	}
+}
+
+// recoverPanic must be deferred directly, it stores the recovered panic to err.
+func (s *Source) recoverPanic(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	s.panicErr = &PanicError{Value: r, Stack: debug.Stack()}
+	*err = s.panicErr
+}
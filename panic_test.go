@@ -0,0 +1,68 @@
+package itermultipart_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestSourcePanicRecovery(t *testing.T) {
+	errBoom := errors.New("boom")
+	panicking := func(yield func(*itermultipart.Part, error) bool) {
+		if !yield(itermultipart.NewPart().SetFormName("a").SetContentString("a"), nil) {
+			return
+		}
+		panic(errBoom)
+	}
+	panickingCondition := itermultipart.PartSeq(
+		itermultipart.ConditionalPart(func(context.Context) bool { panic("condition") }, itermultipart.NewPart()),
+	)
+
+	consumers := map[string]func(*itermultipart.Source) error{
+		"Read": func(src *itermultipart.Source) error {
+			_, err := io.Copy(io.Discard, struct{ io.Reader }{src})
+			return err
+		},
+		"WriteTo": func(src *itermultipart.Source) error {
+			_, err := src.WriteTo(io.Discard)
+			return err
+		},
+	}
+	for name, consume := range consumers {
+		for _, parts := range []func(yield func(*itermultipart.Part, error) bool){panicking, panickingCondition} {
+			src := itermultipart.NewSource(parts, itermultipart.WithPanicRecovery())
+			if _, ok := src.ContentLength(); ok {
+				t.Errorf("%s: content length must be unknown", name)
+			}
+
+			err := consume(src)
+			var panicErr *itermultipart.PanicError
+			if !errors.As(err, &panicErr) {
+				t.Fatalf("%s: expected panic error, got %v", name, err)
+			}
+			if !strings.Contains(string(panicErr.Stack), "panic_test.go") {
+				t.Errorf("%s: stack doesn't point to the panic:\n%s", name, panicErr.Stack)
+			}
+			if _, err := src.Read(make([]byte, 10)); !errors.Is(err, panicErr) {
+				t.Errorf("%s: error is not sticky, got %v", name, err)
+			}
+		}
+	}
+
+	src := itermultipart.NewSource(panicking, itermultipart.WithPanicRecovery())
+	if _, err := src.WriteTo(io.Discard); !errors.Is(err, errBoom) {
+		t.Errorf("panic value is not unwrapped, got %v", err)
+	}
+	req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com", src)
+	if err != nil {
+		t.Fatalf("NewRequest: unexpected error %s", err)
+	}
+	if req.GetBody != nil {
+		t.Error("GetBody must not be set")
+	}
+}
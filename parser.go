@@ -0,0 +1,621 @@
+package itermultipart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"mime"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	defaultParserBufferSize     = 4096
+	defaultParserMaxHeaderBytes = 10 << 20 // same as in net/textproto
+)
+
+var (
+	// ErrTooManyParts is returned when the message contains more parts than allowed.
+	ErrTooManyParts = errors.New("too many parts")
+
+	// ErrHeaderTooLarge is returned when part headers are larger than allowed.
+	ErrHeaderTooLarge = errors.New("part header too large")
+)
+
+// ParserOption configures [Parser].
+type ParserOption func(*Parser)
+
+// WithParserBufferSize sets the size of the internal read buffer of the [Parser].
+// Buffer is never smaller than needed to detect the boundary. Default is 4096 bytes.
+func WithParserBufferSize(size int) ParserOption {
+	return func(p *Parser) {
+		p.bufferSize = size
+	}
+}
+
+// WithMaxHeaderBytes limits the total size of header lines of every part. Default is 10 MiB.
+func WithMaxHeaderBytes(n int) ParserOption {
+	return func(p *Parser) {
+		p.maxHeaderBytes = n
+	}
+}
+
+// WithMaxParts limits number of parts in the message. Zero means no limit which is the default.
+func WithMaxParts(n int) ParserOption {
+	return func(p *Parser) {
+		p.maxParts = n
+	}
+}
+
+// Leniency is a set of deviations from RFC 2046 accepted by the [Parser], see [WithLeniency].
+type Leniency uint
+
+const (
+	// LenientLineEndings accepts LF-only line endings in delimiter and header lines,
+	// so a delimiter preceded by LF only ends the part content.
+	LenientLineEndings Leniency = 1 << iota
+	// LenientMissingClose accepts the message ending without the closing delimiter "--boundary--",
+	// the last part ends at the end of the message.
+	LenientMissingClose
+	// LenientBoundaryWhitespace accepts vertical tabs and form feeds after the boundary along with spaces and tabs.
+	LenientBoundaryWhitespace
+	// LenientUnquotedParams accepts unquoted parameter values with spaces and other special characters
+	// in the Content-Disposition header, i.e. filename=my file.txt. Such headers are rewritten in the valid form.
+	LenientUnquotedParams
+
+	// LenientAll accepts all deviations above.
+	LenientAll = LenientLineEndings | LenientMissingClose | LenientBoundaryWhitespace | LenientUnquotedParams
+)
+
+// WithLeniency makes the [Parser] accept the given deviations produced by broken clients. Default is strict parsing.
+func WithLeniency(l Leniency) ParserOption {
+	return func(p *Parser) {
+		p.leniency = l
+	}
+}
+
+// Parser is a streaming parser of multipart messages that doesn't depend on [mime/multipart].
+// Unlike [mime/multipart.Reader.NextPart], it never decodes part contents,
+// so it behaves like [mime/multipart.Reader.NextRawPart].
+type Parser struct {
+	br             *bufio.Reader
+	counter        *countingReader // counts bytes read by br to compute offsets
+	dashBoundary   []byte          // "--boundary"
+	nlDashBoundary []byte          // "\r\n--boundary"
+
+	bufferSize     int
+	maxHeaderBytes int
+	maxParts       int
+	leniency       Leniency
+	logger         *slog.Logger
+	metrics        MetricsHook
+
+	partsRead       int
+	content         *parserContent
+	preambleSkipped bool
+	done            bool
+	scratch         []byte
+
+	capturePreamble bool
+	preamble        []byte // captured if capturePreamble is set
+	closingLineRead bool   // the closing delimiter line is read while skipping the preamble
+}
+
+// NewParser returns a new [Parser] reading the multipart message with the given boundary from r.
+func NewParser(r io.Reader, boundary string, opts ...ParserOption) *Parser {
+	p := &Parser{
+		dashBoundary:   []byte("--" + boundary),
+		nlDashBoundary: []byte("\r\n--" + boundary),
+		bufferSize:     defaultParserBufferSize,
+		maxHeaderBytes: defaultParserMaxHeaderBytes,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	// we need to peek the whole delimiter with some following bytes
+	p.counter = &countingReader{r: r}
+	p.br = bufio.NewReaderSize(p.counter, max(p.bufferSize, len(p.nlDashBoundary)+16))
+	return p
+}
+
+// ParsePart reads a standalone MIME entity written by [Part.WriteTo]: the header up to an empty line
+// and the content being the rest of r. Like [Parser], it doesn't decode the content.
+// Options limiting the header size and enabling leniency apply.
+func ParsePart(r io.Reader, opts ...ParserOption) (*Part, error) {
+	p := NewParser(r, "", opts...)
+	part := NewPart()
+	if err := p.readHeader(part); err != nil {
+		return nil, err
+	}
+	part.Content = p.br
+	return part, nil
+}
+
+// ContentRange returns the byte range [start, end) of the current part's content within the message read by the [Parser],
+// so the original bytes can be taken from a copy of the message without copying the content again.
+// The end is known only after the content is read completely, ok reports that.
+func (p *Parser) ContentRange() (start, end int64, ok bool) {
+	if p.content == nil {
+		return 0, 0, false
+	}
+	return p.content.start, p.content.start + p.content.read, p.content.done
+}
+
+func (p *Parser) lenient(l Leniency) bool {
+	return p.leniency&l != 0
+}
+
+// offset returns the offset of the next unread byte of the message.
+func (p *Parser) offset() int64 {
+	return p.counter.n - int64(p.br.Buffered())
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+	return n, err
+}
+
+// Parts reads each part of the message and yields it to the caller.
+// Parts may be iterated only once.
+// Note that [Part] becomes invalid on the next iteration so reference to it must not be held.
+func (p *Parser) Parts() iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		part := NewPart()
+		for {
+			more, err := p.nextPart(part)
+			if err != nil {
+				p.parseDone(err)
+				yield(nil, err)
+				return
+			}
+			if !more {
+				p.parseDone(nil)
+				return
+			}
+			p.partParsed(part)
+			if !yield(part, nil) {
+				return
+			}
+		}
+	}
+}
+
+// nextPart reads headers of the next part to the provided part, reports false if there are no more parts.
+func (p *Parser) nextPart(part *Part) (bool, error) {
+	if p.done {
+		return false, nil
+	}
+
+	if !p.preambleSkipped {
+		if err := p.skipPreamble(); err != nil {
+			return false, err
+		}
+	} else if p.content != nil {
+		// skip unread content of the previous part
+		if _, err := io.Copy(io.Discard, p.content); err != nil {
+			return false, err
+		}
+		p.partConsumed()
+	}
+	if p.done {
+		return false, nil
+	}
+
+	if p.maxParts > 0 && p.partsRead >= p.maxParts {
+		return false, fmt.Errorf("%w: more than %d", ErrTooManyParts, p.maxParts)
+	}
+	p.partsRead++
+
+	if p.lenient(LenientMissingClose) {
+		if _, err := p.br.Peek(1); errors.Is(err, io.EOF) {
+			p.done = true // the message ends right after the delimiter line
+			return false, nil
+		}
+	}
+
+	part.Reset()
+	if err := p.readHeader(part); err != nil {
+		return false, err
+	}
+
+	p.content = &parserContent{parser: p, start: p.offset()}
+	part.Content = p.content
+	return true, nil
+}
+
+// skipPreamble skips everything before the first delimiter and the delimiter line itself.
+// The preamble is captured up to maxHeaderBytes if capturePreamble is set.
+func (p *Parser) skipPreamble() error {
+	p.preambleSkipped = true
+	for {
+		line, err := p.br.ReadSlice('\n')
+		switch {
+		case errors.Is(err, nil):
+		case errors.Is(err, bufio.ErrBufferFull):
+			p.appendPreamble(line)
+			continue // long preamble line is not a delimiter
+		case errors.Is(err, io.EOF):
+			return fmt.Errorf("multipart: no delimiter found: %w", io.ErrUnexpectedEOF)
+		default:
+			return err
+		}
+
+		rest, ok := bytes.CutPrefix(line, p.dashBoundary)
+		switch {
+		case !ok:
+		case bytes.HasPrefix(rest, []byte("--")):
+			p.done = true
+			p.closingLineRead = true
+			p.preamble = p.trimLineEnd(p.preamble) // CRLF belongs to the delimiter
+			return nil
+		case p.isDelimiterLineEnd(rest):
+			p.preamble = p.trimLineEnd(p.preamble)
+			return nil
+		}
+		p.appendPreamble(line)
+	}
+}
+
+func (p *Parser) appendPreamble(line []byte) {
+	if p.capturePreamble && len(p.preamble)+len(line) <= p.maxHeaderBytes {
+		p.preamble = append(p.preamble, line...)
+	}
+}
+
+// readEpilogue reads the rest of the message after the closing delimiter up to maxHeaderBytes.
+func (p *Parser) readEpilogue() ([]byte, error) {
+	rest, err := io.ReadAll(io.LimitReader(p.br, int64(p.maxHeaderBytes)))
+	if err != nil {
+		return nil, err
+	}
+	if p.closingLineRead {
+		return rest, nil
+	}
+
+	// close-delimiter transport-padding [CRLF epilogue]
+	rest = bytes.TrimPrefix(rest, []byte("--"))
+	rest = bytes.TrimLeft(rest, p.padding())
+	epilogue, ok := bytes.CutPrefix(rest, []byte("\r\n"))
+	if !ok && p.lenient(LenientLineEndings) {
+		epilogue, _ = bytes.CutPrefix(rest, []byte("\n"))
+	}
+	return epilogue, nil
+}
+
+// finishDelimiter reads the rest of the delimiter line after the "\r\n--boundary".
+func (p *Parser) finishDelimiter() error {
+	peek, err := p.br.Peek(2)
+	if err == nil && string(peek) == "--" {
+		p.done = true // epilogue is ignored
+		return nil
+	}
+
+	line, err := p.br.ReadSlice('\n')
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, io.EOF) && p.lenient(LenientMissingClose) && len(bytes.TrimLeft(line, p.padding()+"\r")) == 0:
+		p.done = true
+		return nil
+	case errors.Is(err, io.EOF), errors.Is(err, bufio.ErrBufferFull):
+		return fmt.Errorf("multipart: malformed delimiter line: %w", io.ErrUnexpectedEOF)
+	default:
+		return err
+	}
+	if !p.isDelimiterLineEnd(line) {
+		return fmt.Errorf("multipart: malformed delimiter line %q", line)
+	}
+	return nil
+}
+
+// isDelimiterLineEnd checks that the rest of delimiter line contains only transport padding and CRLF.
+func isDelimiterLineEnd(rest []byte) bool {
+	rest = bytes.TrimLeft(rest, " \t")
+	return string(rest) == "\r\n"
+}
+
+// isDelimiterLineEnd is like the package function but accounts the leniency.
+func (p *Parser) isDelimiterLineEnd(rest []byte) bool {
+	rest = bytes.TrimLeft(rest, p.padding())
+	return string(rest) == "\r\n" || p.lenient(LenientLineEndings) && string(rest) == "\n"
+}
+
+// padding returns characters of the transport padding after the boundary.
+func (p *Parser) padding() string {
+	if p.lenient(LenientBoundaryWhitespace) {
+		return " \t\v\f"
+	}
+	return " \t"
+}
+
+// trimLineEnd removes the line ending preceding the delimiter.
+func (p *Parser) trimLineEnd(b []byte) []byte {
+	if trimmed, ok := bytes.CutSuffix(b, []byte("\r\n")); ok || !p.lenient(LenientLineEndings) {
+		return trimmed
+	}
+	return bytes.TrimSuffix(b, []byte("\n"))
+}
+
+// readHeader reads part headers until the empty line remembering their order.
+func (p *Parser) readHeader(part *Part) error {
+	var (
+		header  = part.Header
+		total   int
+		lastKey string
+	)
+	for {
+		line, err := p.readHeaderLine(&total)
+		if err != nil {
+			return err
+		}
+		if len(line) == 0 {
+			if p.lenient(LenientUnquotedParams) {
+				repairDisposition(part)
+			}
+			return nil
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			// obsolete line folding, continue the previous value
+			values := header[lastKey]
+			if len(values) == 0 {
+				return fmt.Errorf("multipart: malformed header line %q", line)
+			}
+			values[len(values)-1] += " " + string(bytes.TrimSpace(line))
+			continue
+		}
+
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok || len(key) == 0 || bytes.ContainsAny(key, " \t") {
+			return fmt.Errorf("multipart: malformed header line %q", line)
+		}
+		lastKey = textproto.CanonicalMIMEHeaderKey(string(key))
+		header[lastKey] = append(header[lastKey], string(bytes.TrimSpace(value)))
+		part.trackHeader(lastKey)
+	}
+}
+
+// readHeaderLine reads a header line without CRLF and accounts its size.
+func (p *Parser) readHeaderLine(total *int) ([]byte, error) {
+	p.scratch = p.scratch[:0]
+	for {
+		chunk, err := p.br.ReadSlice('\n')
+		*total += len(chunk)
+		if *total > p.maxHeaderBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrHeaderTooLarge, p.maxHeaderBytes)
+		}
+
+		switch {
+		case errors.Is(err, nil):
+			p.scratch = append(p.scratch, chunk...)
+			line, ok := bytes.CutSuffix(p.scratch, []byte("\r\n"))
+			if !ok && p.lenient(LenientLineEndings) {
+				line, ok = bytes.CutSuffix(p.scratch, []byte("\n"))
+			}
+			if !ok {
+				return nil, fmt.Errorf("multipart: header line %q doesn't end with CRLF", p.scratch)
+			}
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			p.scratch = append(p.scratch, chunk...)
+		case errors.Is(err, io.EOF):
+			return nil, fmt.Errorf("multipart: reading header: %w", io.ErrUnexpectedEOF)
+		default:
+			return nil, err
+		}
+	}
+}
+
+// parserContent reads the part content until the delimiter.
+type parserContent struct {
+	parser *Parser
+	start  int64 // offset of the content in the message
+	read   int64
+	done   bool
+	eof    bool // the message ended without the closing delimiter, the rest is the content
+	err    error
+}
+
+func (c *parserContent) Read(d []byte) (int, error) {
+	p := c.parser
+	for {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		if len(d) == 0 {
+			return 0, nil
+		}
+
+		buf, _ := p.br.Peek(p.br.Buffered())
+		if c.eof {
+			if len(buf) == 0 {
+				c.done, p.done = true, true
+				continue
+			}
+			n := copy(d, buf)
+			p.br.Discard(n)
+			c.read += int64(n)
+			return n, nil
+		}
+
+		safe, delimLen := p.scanContent(buf, c.read == 0)
+		switch {
+		case safe > 0:
+			n := copy(d, buf[:safe])
+			p.br.Discard(n)
+			c.read += int64(n)
+			return n, nil
+		case delimLen > 0:
+			p.br.Discard(delimLen)
+			c.done = true
+			c.err = p.finishDelimiter()
+		default:
+			// need more data to make a decision
+			if _, err := p.br.Peek(len(buf) + 1); err != nil {
+				if errors.Is(err, io.EOF) && p.lenient(LenientMissingClose) {
+					if p.isTrailingDelimiter(buf, c.read == 0) {
+						p.br.Discard(len(buf))
+						c.done, p.done = true, true
+					} else {
+						c.eof = true
+					}
+					continue
+				}
+				if errors.Is(err, io.EOF) {
+					err = fmt.Errorf("multipart: reading content: %w", io.ErrUnexpectedEOF)
+				}
+				c.err = err
+			}
+		}
+	}
+}
+
+// scanContent returns the number of bytes from the start of buf that certainly belong to the content
+// and the length of the delimiter found right after them if any.
+// At the start of the content the delimiter may go without the leading CRLF because it's a part of the header ending.
+func (p *Parser) scanContent(buf []byte, atStart bool) (int, int) {
+	if atStart && bytes.HasPrefix(buf, p.dashBoundary) {
+		switch p.isDelimiterEnd(buf, len(p.dashBoundary)) {
+		case needMoreData:
+			return 0, 0
+		case delimiterFound:
+			return 0, len(p.dashBoundary)
+		}
+	}
+	if atStart && bytes.HasPrefix(p.dashBoundary, buf) {
+		return 0, 0
+	}
+
+	lf := p.lenient(LenientLineEndings)
+	delim := p.nlDashBoundary
+	if lf {
+		delim = delim[1:] // "\n--boundary", CR before it is checked separately
+	}
+	// start returns the position of the delimiter found at i including the optional CR
+	start := func(i int) int {
+		if lf && i > 0 && buf[i-1] == '\r' {
+			return i - 1
+		}
+		return i
+	}
+
+	searchFrom := 0
+	for {
+		i := bytes.Index(buf[searchFrom:], delim)
+		if i < 0 {
+			break
+		}
+		i += searchFrom
+
+		switch p.isDelimiterEnd(buf, i+len(delim)) {
+		case needMoreData:
+			return start(i), 0
+		case delimiterFound:
+			return start(i), i + len(delim) - start(i)
+		}
+		// it's not a delimiter, i.e. "--boundaryX", continue search
+		searchFrom = i + 1
+	}
+
+	// keep the tail which may be a beginning of the delimiter
+	if i := bytes.LastIndexByte(buf[searchFrom:], delim[0]); i >= 0 && bytes.HasPrefix(delim, buf[searchFrom+i:]) {
+		return start(searchFrom + i), 0
+	}
+	if lf && bytes.HasSuffix(buf, []byte("\r")) {
+		return len(buf) - 1, 0
+	}
+	return len(buf), 0
+}
+
+const (
+	notDelimiter = iota
+	delimiterFound
+	needMoreData
+)
+
+// isDelimiterEnd checks the byte following the boundary candidate ending at the given position.
+func (p *Parser) isDelimiterEnd(buf []byte, end int) int {
+	if end >= len(buf) {
+		return needMoreData
+	}
+	switch b := buf[end]; {
+	case b == '-', b == '\r', strings.IndexByte(p.padding(), b) >= 0:
+		return delimiterFound
+	case b == '\n' && p.lenient(LenientLineEndings):
+		return delimiterFound
+	default:
+		return notDelimiter
+	}
+}
+
+// isTrailingDelimiter reports whether the rest of the message is the delimiter without the closing "--".
+func (p *Parser) isTrailingDelimiter(rest []byte, atStart bool) bool {
+	if atStart && bytes.Equal(rest, p.dashBoundary) {
+		return true
+	}
+	if bytes.Equal(rest, p.nlDashBoundary) {
+		return true
+	}
+	return p.lenient(LenientLineEndings) && bytes.Equal(rest, p.nlDashBoundary[1:])
+}
+
+// repairDisposition rewrites the Content-Disposition header having unquoted parameter values
+// with special characters, i.e. filename=my file.txt, in the valid form.
+func repairDisposition(part *Part) {
+	v := part.Header[contentDispositionHeader]
+	if len(v) == 0 {
+		return
+	}
+	if _, _, err := mime.ParseMediaType(v[0]); err == nil {
+		return
+	}
+
+	disposition, rest, _ := strings.Cut(v[0], ";")
+	params := make(map[string]string)
+	for rest != "" {
+		var param string
+		param, rest = cutParam(rest)
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = quotedPairReplacer.Replace(value[1 : len(value)-1])
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	if repaired := formatDisposition(strings.TrimSpace(disposition), params); repaired != "" {
+		v[0] = repaired
+	}
+}
+
+var quotedPairReplacer = strings.NewReplacer(`\\`, `\`, `\"`, `"`)
+
+// cutParam cuts the parameter until the semicolon outside of quotes.
+func cutParam(s string) (param, rest string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ';' && !quoted:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
@@ -0,0 +1,256 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"mime/multipart"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+type parsedPart struct {
+	header  string
+	content string
+}
+
+func collectParts(t *testing.T, parts func(yield func(*itermultipart.Part, error) bool)) ([]parsedPart, error) {
+	t.Helper()
+
+	var ret []parsedPart
+	for part, err := range parts {
+		if err != nil {
+			return ret, err
+		}
+		content, err := io.ReadAll(part.Content)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, parsedPart{header: fmt.Sprint(part.Header), content: string(content)})
+	}
+	return ret, nil
+}
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"simple", "--b\r\nContent-Disposition: form-data; name=\"key\"\r\n\r\nval\r\n--b--\r\n"},
+		{"no parts", "--b--\r\n"},
+		{"preamble and epilogue", "preamble\r\n--b\r\nA: 1\r\n\r\nval\r\n--b--\r\nepilogue"},
+		{"empty content", "--b\r\nA: 1\r\n\r\n\r\n--b\r\n\r\n\r\n--b--"},
+		{"transport padding", "--b \t\r\nA: 1\r\n\r\nval\r\n--b \r\nB: 2\r\n\r\nval2\r\n--b--"},
+		{"boundary-like content", "--b\r\nA: 1\r\n\r\nx\r\n--bb\r\n--b_\r\n-\r\n\r\n--b--"},
+		{"delimiter right after header", "--b\r\nA: 1\r\n\r\n--b\r\nB: 2\r\n\r\nval\r\n--b--"},
+		{"repeated headers", "--b\r\nA: 1\r\nA: 2\r\nb-c: 3\r\n\r\nval\r\n--b--"},
+		{"folded header", "--b\r\nA: 1\r\n  continued\r\n\r\nval\r\n--b--"},
+		{"multiple parts", "--b\r\nA: 1\r\n\r\none\r\n--b\r\nA: 2\r\n\r\ntwo\r\n--b\r\nA: 3\r\n\r\nthree\r\n--b--\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, wantErr := collectParts(t, itermultipart.PartsFromReader(multipart.NewReader(strings.NewReader(tt.message), "b"), true))
+			if wantErr != nil {
+				t.Fatalf("mime/multipart: unexpected error %s", wantErr)
+			}
+
+			for _, bufSize := range []int{1, 16, 4096} {
+				parser := itermultipart.NewParser(iotest.HalfReader(strings.NewReader(tt.message)), "b",
+					itermultipart.WithParserBufferSize(bufSize))
+				got, err := collectParts(t, parser.Parts())
+				if err != nil {
+					t.Fatalf("buffer %d: unexpected error %s", bufSize, err)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("buffer %d:\n got: %q\nwant: %q", bufSize, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParserRandom(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	alphabet := []byte("-\r\nab")
+	for i := range 200 {
+		var parts []*itermultipart.Part
+		for range rnd.IntN(4) {
+			content := make([]byte, rnd.IntN(100))
+			for j := range content {
+				content[j] = alphabet[rnd.IntN(len(alphabet))]
+			}
+			parts = append(parts, itermultipart.NewPart().SetFormName("f").SetContentBytes(content))
+		}
+
+		src := itermultipart.NewSource(itermultipart.PartSeq(parts...))
+		src.SetBoundary("ab")
+		var b strings.Builder
+		if _, err := src.WriteTo(&b); err != nil {
+			t.Fatalf("%d: WriteTo: unexpected error %s", i, err)
+		}
+
+		want, wantErr := collectParts(t, itermultipart.PartsFromReader(multipart.NewReader(strings.NewReader(b.String()), "ab"), true))
+		got, err := collectParts(t, itermultipart.NewParser(strings.NewReader(b.String()), "ab", itermultipart.WithParserBufferSize(1)).Parts())
+		if (wantErr == nil) != (err == nil) {
+			t.Fatalf("%d: errors mismatch: got %v, want %v", i, err, wantErr)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("%d: message %q\n got: %q\nwant: %q", i, b.String(), got, want)
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		opts    []itermultipart.ParserOption
+		err     error
+	}{
+		{"no delimiter", "just text", nil, io.ErrUnexpectedEOF},
+		{"truncated content", "--b\r\nA: 1\r\n\r\nval", nil, io.ErrUnexpectedEOF},
+		{"truncated header", "--b\r\nA: 1\r\n", nil, io.ErrUnexpectedEOF},
+		{"too many parts", "--b\r\n\r\n1\r\n--b\r\n\r\n2\r\n--b--", []itermultipart.ParserOption{itermultipart.WithMaxParts(1)}, itermultipart.ErrTooManyParts},
+		{"header too large", "--b\r\nA: 1234567890\r\n\r\n1\r\n--b--", []itermultipart.ParserOption{itermultipart.WithMaxHeaderBytes(10)}, itermultipart.ErrHeaderTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := collectParts(t, itermultipart.NewParser(strings.NewReader(tt.message), "b", tt.opts...).Parts())
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v; want %v", err, tt.err)
+			}
+		})
+	}
+
+	t.Run("malformed header", func(t *testing.T) {
+		_, err := collectParts(t, itermultipart.NewParser(strings.NewReader("--b\r\nno colon\r\n\r\n1\r\n--b--"), "b").Parts())
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestParserLeniency(t *testing.T) {
+	const want = "--b\r\nA: 1\r\n\r\none\r\n--b\r\nA: 2\r\n\r\ntwo\r\n--b--\r\n"
+	tests := []struct {
+		name     string
+		message  string
+		leniency itermultipart.Leniency
+		want     string
+	}{
+		{"LF line endings", "--b\nA: 1\n\none\n--b\nA: 2\n\ntwo\n--b--\n", itermultipart.LenientLineEndings, want},
+		{"mixed line endings", "--b\r\nA: 1\n\r\none\n--b\nA: 2\r\n\ntwo\r\n--b--", itermultipart.LenientLineEndings, want},
+		{"CR in content", "--b\nA: 1\n\none\r\r\n--b\nA: 2\n\ntwo\r\n--b--", itermultipart.LenientLineEndings,
+			"--b\r\nA: 1\r\n\r\none\r\r\n--b\r\nA: 2\r\n\r\ntwo\r\n--b--\r\n"},
+		{"missing close after content", "--b\r\nA: 1\r\n\r\none\r\n--b\r\nA: 2\r\n\r\ntwo", itermultipart.LenientMissingClose, want},
+		{"missing close after delimiter", "--b\r\nA: 1\r\n\r\none\r\n--b\r\nA: 2\r\n\r\ntwo\r\n--b", itermultipart.LenientMissingClose, want},
+		{"missing close after delimiter line", "--b\r\nA: 1\r\n\r\none\r\n--b\r\nA: 2\r\n\r\ntwo\r\n--b\r\n", itermultipart.LenientMissingClose, want},
+		{"whitespace after boundary", "--b\v\r\nA: 1\r\n\r\none\r\n--b \f\r\nA: 2\r\n\r\ntwo\r\n--b--", itermultipart.LenientBoundaryWhitespace, want},
+		{"unquoted file name", "--b\r\nContent-Disposition: form-data; name=f; filename=my file.txt\r\n\r\none\r\n--b--", itermultipart.LenientUnquotedParams,
+			"--b\r\nContent-Disposition: form-data; filename=\"my file.txt\"; name=f\r\n\r\none\r\n--b--"},
+		{"all", "--b \v\nA: 1\n\none\n--b\nA: 2\n\ntwo", itermultipart.LenientAll, want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, wantErr := collectParts(t, itermultipart.PartsFromReader(multipart.NewReader(strings.NewReader(tt.want), "b"), true))
+			if wantErr != nil {
+				t.Fatalf("mime/multipart: unexpected error %s", wantErr)
+			}
+
+			if got, err := collectParts(t, itermultipart.NewParser(strings.NewReader(tt.message), "b").Parts()); err == nil && slices.Equal(got, want) {
+				t.Error("strict parser: expected error or different parts")
+			}
+
+			for _, bufSize := range []int{1, 16, 4096} {
+				parser := itermultipart.NewParser(iotest.OneByteReader(strings.NewReader(tt.message)), "b",
+					itermultipart.WithParserBufferSize(bufSize), itermultipart.WithLeniency(tt.leniency))
+				got, err := collectParts(t, parser.Parts())
+				if err != nil {
+					t.Fatalf("buffer %d: unexpected error %s", bufSize, err)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("buffer %d:\n got: %q\nwant: %q", bufSize, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParserContentRange(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("a").SetContentString("first"),
+		itermultipart.NewPart().SetFormName("empty"),
+		itermultipart.NewPart().SetFormName("b").SetContentString(strings.Repeat("second\r\n", 100)),
+	))
+	var message strings.Builder
+	if _, err := src.WriteTo(&message); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+
+	parser := itermultipart.NewParser(iotest.HalfReader(strings.NewReader("preamble\r\n"+message.String())), src.Boundary(),
+		itermultipart.WithParserBufferSize(64))
+	for part, err := range parser.Parts() {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if _, _, ok := parser.ContentRange(); ok {
+			t.Errorf("%s: range is known before reading", part.FormName())
+		}
+
+		content, err := io.ReadAll(part.Content)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", part.FormName(), err)
+		}
+		start, end, ok := parser.ContentRange()
+		if !ok {
+			t.Fatalf("%s: range is unknown after reading", part.FormName())
+		}
+		if got := ("preamble\r\n" + message.String())[start:end]; got != string(content) {
+			t.Errorf("%s: range [%d, %d) contains %q, want %q", part.FormName(), start, end, got, content)
+		}
+	}
+}
+
+func ExampleParser() {
+	message := `--boundary
+Content-Disposition: form-data; name="myfile"; filename="example.txt"
+
+contents of myfile
+--boundary
+Content-Disposition: form-data; name="key"
+
+value for key
+--boundary--`
+	message = strings.ReplaceAll(message, "\n", "\r\n")
+	parser := itermultipart.NewParser(strings.NewReader(message), "boundary", itermultipart.WithMaxParts(10))
+
+	for part, err := range parser.Parts() {
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Println("---headers---")
+		for _, k := range slices.Sorted(maps.Keys(part.Header)) {
+			fmt.Printf("%s: %s\n", k, part.Header[k])
+		}
+		fmt.Println("---content---")
+		io.Copy(os.Stdout, part.Content)
+		fmt.Println()
+	}
+	// Output:
+	// ---headers---
+	// Content-Disposition: [form-data; name="myfile"; filename="example.txt"]
+	// ---content---
+	// contents of myfile
+	// ---headers---
+	// Content-Disposition: [form-data; name="key"]
+	// ---content---
+	// value for key
+}
@@ -3,13 +3,21 @@ package itermultipart
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"maps"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var emptyParams = make(map[string]string)
@@ -17,6 +25,7 @@ var emptyParams = make(map[string]string)
 const (
 	contentDispositionHeader = "Content-Disposition"
 	contentTypeHeader        = "Content-Type"
+	contentIDHeader          = "Content-Id"
 	formDataDisposition      = "form-data"
 )
 
@@ -24,9 +33,18 @@ const (
 type Part struct {
 	Header  textproto.MIMEHeader
 	Content io.Reader
+	Meta    map[string]any // metadata attached to the part, i.e. by [ExtractMetadata], it's not written to the message
 
+	rawDisposition    string // Content-Disposition value disposition and dispositionParams parsed from
 	disposition       string
 	dispositionParams map[string]string
+	contentFactory    func() (io.ReadCloser, error)
+	condition         func(ctx context.Context) bool
+	transferEncoding  string                 // encoding applied to the content by the Source
+	contentEncoding   string                 // compression applied to the content by the Source
+	smtpCompat        bool                   // transfer encoding follows SMTP line length limits, see SetSMTPCompat
+	headerOrder       []string               // header keys in the order they were added, see WithOrderedHeaders
+	parents           []textproto.MIMEHeader // headers of enclosing multipart parts, see WalkParts
 }
 
 // NewPart creates a new part.
@@ -36,14 +54,39 @@ func NewPart() *Part {
 	}
 }
 
+// NewFieldPart creates a form field part with the given name and value.
+func NewFieldPart(name, value string) *Part {
+	return NewPart().SetFormName(name).SetContentString(value)
+}
+
+// NewFilePart creates a form file part with the content of the file at the path.
+// The file name is the base name of the path and the content type is detected by its extension.
+// The file is opened only when [Source] reaches the part, see [Part.SetContentFactory].
+func NewFilePart(fieldName, path string) *Part {
+	return NewPart().
+		SetFormName(fieldName).
+		SetFileName(filepath.Base(path)).
+		SetContentTypeByExtension().
+		SetContentFactory(func() (io.ReadCloser, error) {
+			return os.Open(path)
+		})
+}
+
+// NewJSONPart creates a form field part with v marshaled to JSON and "application/json" content type.
+func NewJSONPart(name string, v any) (*Part, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return NewPart().SetFormName(name).SetContentType("application/json").SetContentBytes(content), nil
+}
+
 // SetFormName sets the form name of the part.
 func (p *Part) SetFormName(formName string) *Part {
-	if p.dispositionParams == nil {
-		p.dispositionParams = make(map[string]string)
-	}
+	p.ensureDispositionParams()
 	p.dispositionParams["name"] = formName
-	p.disposition = mime.FormatMediaType(formDataDisposition, p.dispositionParams)
-	p.Header.Set(contentDispositionHeader, p.disposition)
+	p.disposition = formDataDisposition
+	p.updateDisposition()
 	return p
 }
 
@@ -59,19 +102,21 @@ func (p *Part) FormName() string {
 	return p.dispositionParams["name"]
 }
 
-// SetFileName sets the file name of the part.
+// SetFileName sets the file name of the part keeping the disposition type, "form-data" if not set.
 // It also sets the "Content-Type" header to "application/octet-stream" like [multipart.Writer.CreateFormFile].
+// Non-ASCII file names are written as the RFC 5987 "filename*" parameter along with
+// the "filename" parameter with non-ASCII characters replaced by "_" for servers not supporting it.
 func (p *Part) SetFileName(fileName string) *Part {
+	p.ensureDispositionParams()
 	p.dispositionParams["filename"] = fileName
-	p.disposition = mime.FormatMediaType(formDataDisposition, p.dispositionParams)
-	p.Header.Set(contentDispositionHeader, p.disposition)
+	p.updateDisposition()
 	// Go's standard multipart.Writer does this when you create a file part
-	p.Header.Set(contentTypeHeader, "application/octet-stream")
-	return p
+	return p.SetContentType("application/octet-stream")
 }
 
 // FileName returns the filename parameter of the [Part]'s Content-Disposition
-// header. If not empty, the filename is passed through filepath.Base (which is
+// header, the RFC 5987 "filename*" parameter takes precedence if present.
+// If not empty, the filename is passed through filepath.Base (which is
 // platform dependent) before being returned.
 func (p *Part) FileName() string {
 	p.parseContentDisposition()
@@ -87,9 +132,64 @@ func (p *Part) FileName() string {
 // SetContent sets the content of the part.
 func (p *Part) SetContent(content io.Reader) *Part {
 	p.Content = content
+	p.contentFactory = nil
+	return p
+}
+
+// SetContentFactory sets the function opening the content of the part.
+// [Source] opens the content when it reaches the part and closes it after the content is written.
+// Because the content is opened for every generated message, [Source] with such parts can be rewound.
+func (p *Part) SetContentFactory(factory func() (io.ReadCloser, error)) *Part {
+	p.Content = nil
+	p.contentFactory = factory
 	return p
 }
 
+// SetContentFunc sets the function producing the content of the part, i.e. from a database or a remote storage.
+// It's called only when [Source] reaches the part, its error is returned by the [Source] read.
+// The content is closed after it's written if it implements [io.Closer], see [Part.SetContentFactory] for other details.
+func (p *Part) SetContentFunc(f func() (io.Reader, error)) *Part {
+	return p.SetContentFactory(func() (io.ReadCloser, error) {
+		content, err := f()
+		if err != nil {
+			return nil, err
+		}
+		if rc, ok := content.(io.ReadCloser); ok {
+			return rc, nil
+		}
+		return io.NopCloser(content), nil
+	})
+}
+
+// SetContentFile sets the content of the part to the file at the path, opened only when [Source] reaches the part
+// and closed after its content is written or the [Source] is closed, see [Part.SetContentFactory].
+// The file name is set to the base name of the path with [Part.SetFileName]. If the file can be stat'ed,
+// its size and modification time are added as RFC 2183 "size" and "modification-date" Content-Disposition parameters.
+func (p *Part) SetContentFile(path string) *Part {
+	p.SetFileName(filepath.Base(path))
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		p.dispositionParams["size"] = strconv.FormatInt(info.Size(), 10)
+		p.dispositionParams["modification-date"] = info.ModTime().Format(time.RFC1123Z)
+		p.updateDisposition()
+	}
+	return p.SetContentFactory(func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+// SetMultipartContent sets the nested multipart message generated by the [Source] as a content of the part.
+// Content-Type of the part is set to "multipart/mixed" with the [Source]'s boundary.
+// If the part already has a multipart Content-Type, only its boundary parameter is changed.
+// Nested message is streamed lazily and rewound together with the outer one.
+func (p *Part) SetMultipartContent(src *Source) *Part {
+	mediaType, params, err := mime.ParseMediaType(p.ContentType())
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		mediaType, params = "multipart/mixed", make(map[string]string)
+	}
+	params["boundary"] = src.Boundary()
+	return p.SetContent(src).SetContentType(mime.FormatMediaType(mediaType, params))
+}
+
 // SetContentString sets the content of the part to the given string.
 func (p *Part) SetContentString(content string) *Part {
 	if sr, ok := p.Content.(*strings.Reader); ok {
@@ -109,12 +209,56 @@ func (p *Part) SetContentBytes(content []byte) *Part {
 	return p.SetContent(bytes.NewReader(content))
 }
 
+// Size returns the number of bytes left in the part's content if it can be determined without reading it.
+// Contents implementing Len() int (like [bytes.Reader] or [strings.Reader]) or Size() int64 are supported,
+// as well as regular [os.File]s and nested [Source]s. Offsets of seekable contents are taken into account.
+func (p *Part) Size() (int64, bool) {
+	if p.contentFactory != nil {
+		return 0, false
+	}
+	return contentSize(p.Content)
+}
+
+// contentSize returns the number of bytes left in the content if it can be determined without reading it, see [Part.Size].
+func contentSize(content io.Reader) (int64, bool) {
+	switch c := content.(type) {
+	case nil:
+		return 0, true
+	case *Source:
+		return c.ContentLength()
+	case interface{ Len() int }:
+		return int64(c.Len()), true
+	case *os.File:
+		info, err := c.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		return sizeFromOffset(c, info.Size())
+	case interface{ Size() int64 }:
+		if seeker, ok := c.(io.Seeker); ok {
+			return sizeFromOffset(seeker, c.Size())
+		}
+		return c.Size(), true
+	default:
+		return 0, false
+	}
+}
+
+func sizeFromOffset(seeker io.Seeker, size int64) (int64, bool) {
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil || offset > size {
+		return 0, false
+	}
+	return size - offset, true
+}
+
 // SetContentType sets the content type of the part.
 func (p *Part) SetContentType(contentType string) *Part {
 	if p.Header == nil {
 		p.Header = make(textproto.MIMEHeader)
 	}
 	p.Header.Set(contentTypeHeader, contentType)
+	p.trackHeader(contentTypeHeader)
 	return p
 }
 
@@ -123,6 +267,20 @@ func (p *Part) ContentType() string {
 	return p.Header.Get(contentTypeHeader)
 }
 
+// SetContentID sets the "Content-ID" header of the part, i.e. to reference it from other parts of multipart/related message.
+// Angle brackets are added to the id if missing.
+func (p *Part) SetContentID(id string) *Part {
+	if !strings.HasPrefix(id, "<") {
+		id = "<" + id + ">"
+	}
+	return p.SetHeaderValue(contentIDHeader, id)
+}
+
+// ContentID returns the "Content-ID" header of the part without angle brackets.
+func (p *Part) ContentID() string {
+	return strings.TrimSuffix(strings.TrimPrefix(p.Header.Get(contentIDHeader), "<"), ">")
+}
+
 // DetectContentType detects the content type of the part using [net/http.DetectContentType].
 // It peeks the first 512 bytes of the content to determine the content type.
 // Content must be already set before calling this method.
@@ -159,6 +317,7 @@ func (p *Part) SetHeaderValue(key, value string) *Part {
 		p.Header = make(textproto.MIMEHeader)
 	}
 	p.Header.Set(key, value)
+	p.trackHeader(key)
 	return p
 }
 
@@ -168,54 +327,328 @@ func (p *Part) AddHeaderValue(key, value string) *Part {
 		p.Header = make(textproto.MIMEHeader)
 	}
 	p.Header.Add(key, value)
+	p.trackHeader(key)
 	return p
 }
 
 // MergeHeaders merges the given headers into the part's headers.
+// New keys are added in sorted order.
 func (p *Part) MergeHeaders(h textproto.MIMEHeader) *Part {
 	if p.Header == nil {
 		p.Header = make(textproto.MIMEHeader)
 	}
-	for k, v := range h {
-		p.Header[k] = v
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		p.Header[k] = h[k]
+		p.trackHeader(k)
 	}
 	return p
 }
 
+// SetHeaderOrder sets the order [Source] writes header keys in when [WithOrderedHeaders] is used,
+// replacing the order they were added in. Keys not listed are written after the listed ones in sorted order.
+func (p *Part) SetHeaderOrder(keys ...string) *Part {
+	p.headerOrder = p.headerOrder[:0]
+	for _, k := range keys {
+		p.trackHeader(k)
+	}
+	return p
+}
+
+// trackHeader remembers the position of the header key if it's not known yet.
+func (p *Part) trackHeader(key string) {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	if !slices.Contains(p.headerOrder, key) {
+		p.headerOrder = append(p.headerOrder, key)
+	}
+}
+
 // AddToWriter adds the part to the standard [mime/multipart.Writer].
 func (p *Part) AddToWriter(mw *multipart.Writer) error {
 	pw, err := mw.CreatePart(p.Header)
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(pw, p.Content)
+
+	content := p.Content
+	if p.contentFactory != nil {
+		rc, err := p.contentFactory()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		content = rc
+	}
+	if content == nil {
+		return nil
+	}
+	_, err = io.Copy(pw, p.encodedContent(content))
 	return err
 }
 
+// OpenContent returns the content of the part opening it with the factory set by [Part.SetContentFactory] if needed,
+// i.e. to send the content alone with another protocol. The caller must close the returned reader,
+// contents set with [Part.SetContent] are not closed by that. Transfer and content encodings are not applied.
+func (p *Part) OpenContent() (io.ReadCloser, error) {
+	switch {
+	case p.contentFactory != nil:
+		return p.contentFactory()
+	case p.Content == nil:
+		return io.NopCloser(strings.NewReader("")), nil
+	default:
+		return io.NopCloser(p.Content), nil
+	}
+}
+
+// WriteTo writes the part as a standalone MIME entity without multipart delimiters: the header with sorted keys,
+// an empty line and the content with transfer and content encodings applied like [Source] does,
+// i.e. to store a single part or to craft an "application/http" body. It's the inverse of [ParsePart].
+// Content set by a factory is opened and closed, other contents are read to the end.
+func (p *Part) WriteTo(w io.Writer) (int64, error) {
+	var heading bytes.Buffer
+	for _, k := range slices.Sorted(maps.Keys(p.Header)) {
+		for _, v := range p.Header[k] {
+			heading.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	heading.WriteString("\r\n")
+	n, err := heading.WriteTo(w)
+	if err != nil {
+		return n, err
+	}
+
+	content := p.Content
+	if p.contentFactory != nil {
+		rc, err := p.contentFactory()
+		if err != nil {
+			return n, err
+		}
+		defer rc.Close()
+		content = rc
+	}
+	if content = p.encodedContent(content); content == nil {
+		return n, nil
+	}
+	m, err := io.Copy(w, content)
+	return n + m, err
+}
+
+// String returns a short description of the part for diagnostics: form name, file name, content type,
+// number of headers and content size if it's known. Content is never read.
+func (p *Part) String() string {
+	var sb strings.Builder
+	sb.WriteString("Part{")
+	if name := p.FormName(); name != "" {
+		fmt.Fprintf(&sb, "name: %q, ", name)
+	}
+	if fileName := p.FileName(); fileName != "" {
+		fmt.Fprintf(&sb, "filename: %q, ", fileName)
+	}
+	if contentType := p.ContentType(); contentType != "" {
+		fmt.Fprintf(&sb, "content-type: %q, ", contentType)
+	}
+	fmt.Fprintf(&sb, "headers: %d, content: %s}", len(p.Header), p.contentHint())
+	return sb.String()
+}
+
+// GoString implements [fmt.GoStringer]. It prints headers as is and only the type and size of the content.
+func (p *Part) GoString() string {
+	return fmt.Sprintf("&itermultipart.Part{Header: %#v, Content: %s}", p.Header, p.contentHint())
+}
+
+// contentHint describes the content without reading it.
+func (p *Part) contentHint() string {
+	switch {
+	case p.contentFactory != nil:
+		return "factory"
+	case p.Content == nil:
+		return "none"
+	}
+
+	if _, ok := p.Content.(*Source); ok {
+		return "nested multipart" // computing size requires iterating the nested parts
+	}
+	if size, ok := p.Size(); ok {
+		return fmt.Sprintf("%T (%d bytes)", p.Content, size)
+	}
+	return fmt.Sprintf("%T (size unknown)", p.Content)
+}
+
 // Reset resets the part to its initial state.
 func (p *Part) Reset() {
 	clear(p.Header)
 	p.Content = nil
+	p.Meta = nil
+	p.contentFactory = nil
+	p.condition = nil
+	p.transferEncoding = ""
+	p.contentEncoding = ""
+	p.smtpCompat = false
+	p.headerOrder = p.headerOrder[:0]
+	p.parents = nil
+	p.rawDisposition = ""
 	p.disposition = ""
 	p.dispositionParams = nil // to be able to parse again
 }
 
+// SetDisposition sets the Content-Disposition type of the part, i.e. "form-data", "inline" or "attachment",
+// keeping its parameters.
+func (p *Part) SetDisposition(disposition string) *Part {
+	p.ensureDispositionParams()
+	p.disposition = disposition
+	p.updateDisposition()
+	return p
+}
+
+// Disposition returns the Content-Disposition type of the part or the empty string if it's not set.
+func (p *Part) Disposition() string {
+	p.parseContentDisposition()
+	return p.disposition
+}
+
+// SetInline sets the "inline" disposition type, i.e. for email bodies, see [Part.SetDisposition].
+func (p *Part) SetInline() *Part {
+	return p.SetDisposition("inline")
+}
+
+// SetAttachment sets the "attachment" disposition type, i.e. for email attachments, see [Part.SetDisposition].
+func (p *Part) SetAttachment() *Part {
+	return p.SetDisposition("attachment")
+}
+
+// ensureDispositionParams makes dispositionParams safe to modify defaulting the disposition to "form-data".
+func (p *Part) ensureDispositionParams() {
+	p.parseContentDisposition()
+	if p.disposition == "" {
+		p.disposition = formDataDisposition
+	}
+	p.dispositionParams = maps.Clone(p.dispositionParams) // parsed params may be shared emptyParams
+}
+
+// updateDisposition writes the disposition and its parameters to the header.
+func (p *Part) updateDisposition() {
+	if p.Header == nil {
+		p.Header = make(textproto.MIMEHeader)
+	}
+	p.rawDisposition = formatDisposition(p.disposition, p.dispositionParams)
+	p.Header.Set(contentDispositionHeader, p.rawDisposition)
+	p.trackHeader(contentDispositionHeader)
+}
+
 func (p *Part) parseContentDisposition() {
 	v := p.Header[contentDispositionHeader]
 	if len(v) == 0 {
+		p.rawDisposition = ""
 		p.disposition = ""
 		p.dispositionParams = emptyParams
 		return
 	}
 
-	if p.dispositionParams != nil && p.disposition == v[0] {
+	if p.dispositionParams != nil && p.rawDisposition == v[0] {
 		// if header is already parsed, verify that it's the same
 		return
 	}
 
 	var err error
+	p.rawDisposition = v[0]
 	p.disposition, p.dispositionParams, err = mime.ParseMediaType(v[0])
 	if err != nil {
 		p.dispositionParams = emptyParams
 	}
 }
+
+// formatDisposition formats the Content-Disposition value like [mime.FormatMediaType],
+// but writes a non-ASCII file name both as an ASCII fallback and an extended parameter.
+func formatDisposition(disposition string, params map[string]string) string {
+	fileName, ok := params["filename"]
+	if !ok || isASCII(fileName) {
+		return mime.FormatMediaType(disposition, params)
+	}
+
+	rest := make(map[string]string, len(params))
+	for k, v := range params {
+		if k != "filename" {
+			rest[k] = v
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(mime.FormatMediaType(disposition, rest))
+	sb.WriteString(`; filename="`)
+	for _, r := range fileName {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < ' ' || r > '~':
+			sb.WriteByte('_')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString(`"; filename*=UTF-8''`)
+	for _, b := range []byte(fileName) {
+		if isAttrChar(b) {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// quoteEscaper escapes disposition parameter values like [multipart.Writer] does, CR and LF are encoded like browsers do.
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "%0D", "\n", "%0A")
+
+// formatStdlibDisposition reformats the Content-Disposition value like [multipart.Writer] does:
+// "name" and "filename" parameters go first, all values are quoted.
+// Values that can't be parsed are returned as is.
+func formatStdlibDisposition(raw string) string {
+	disposition, params, err := mime.ParseMediaType(raw)
+	if err != nil {
+		return raw
+	}
+
+	keys := slices.Sorted(maps.Keys(params))
+	slices.SortStableFunc(keys, func(a, b string) int {
+		rank := func(k string) int {
+			switch k {
+			case "name":
+				return 0
+			case "filename":
+				return 1
+			default:
+				return 2
+			}
+		}
+		return rank(a) - rank(b)
+	})
+
+	var sb strings.Builder
+	sb.WriteString(disposition)
+	for _, k := range keys {
+		sb.WriteString("; ")
+		sb.WriteString(k)
+		sb.WriteString(`="`)
+		sb.WriteString(quoteEscaper.Replace(params[k]))
+		sb.WriteString(`"`)
+	}
+	return sb.String()
+}
+
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] < ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// isAttrChar reports whether the byte may be written as is in the RFC 5987 extended parameter value.
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
@@ -2,15 +2,20 @@ package itermultipart_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"maps"
+	"mime"
 	"mime/multipart"
 	"net/textproto"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/xakep666/itermultipart"
 )
@@ -126,3 +131,417 @@ func ExamplePart_DetectContentType() {
 	// Output:
 	// text/html; charset=utf-8
 }
+
+func TestPartSize(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "part")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("file contents"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	if _, err := f.Seek(5, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	partialReader := strings.NewReader("string contents")
+	partialReader.Seek(7, io.SeekStart)
+
+	tests := []struct {
+		name    string
+		content io.Reader
+		size    int64
+		ok      bool
+	}{
+		{"nil", nil, 0, true},
+		{"bytes", bytes.NewReader([]byte("bytes")), 5, true},
+		{"string", strings.NewReader("string"), 6, true},
+		{"partially read string", partialReader, 8, true},
+		{"buffer", bytes.NewBufferString("buffer"), 6, true},
+		{"section", io.NewSectionReader(strings.NewReader("section"), 1, 3), 3, true},
+		{"file", f, 8, true},
+		{"unknown", io.MultiReader(strings.NewReader("unknown")), 0, false},
+	}
+	for _, tt := range tests {
+		size, ok := itermultipart.NewPart().SetContent(tt.content).Size()
+		if size != tt.size || ok != tt.ok {
+			t.Errorf("%s: Size() = %d, %v; want %d, %v", tt.name, size, ok, tt.size, tt.ok)
+		}
+	}
+}
+
+func TestPartSetMultipartContent(t *testing.T) {
+	inner := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentType("text/plain").SetContentString("plain text"),
+		itermultipart.NewPart().SetContentType("text/html").SetContentString("<p>html</p>"),
+	), itermultipart.WithReplayableParts())
+	outer := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("key").SetContentString("val"),
+		itermultipart.NewPart().SetFormName("nested").SetContentType("multipart/alternative").SetMultipartContent(inner),
+	), itermultipart.WithReplayableParts())
+
+	length, ok := outer.ContentLength()
+	if !ok {
+		t.Fatal("ContentLength: expected known length")
+	}
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(outer); err != nil {
+		t.Fatalf("ReadFrom: unexpected error %s", err)
+	}
+	if g, e := int64(b.Len()), length; g != e {
+		t.Errorf("ContentLength = %d; actual length %d", e, g)
+	}
+	message := b.String()
+
+	var got []string
+	for part, err := range itermultipart.PartsFromReader(multipart.NewReader(&b, outer.Boundary()), false) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if part.FormName() != "nested" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part.ContentType())
+		if err != nil {
+			t.Fatalf("ParseMediaType: %s", err)
+		}
+		if mediaType != "multipart/alternative" || params["boundary"] != inner.Boundary() {
+			t.Errorf("unexpected Content-Type %q", part.ContentType())
+		}
+		for nested, err := range itermultipart.PartsFromReader(multipart.NewReader(part.Content, params["boundary"]), false) {
+			if err != nil {
+				t.Fatalf("nested: unexpected error %s", err)
+			}
+			content, _ := io.ReadAll(nested.Content)
+			got = append(got, nested.ContentType()+": "+string(content))
+		}
+	}
+	if g, e := strings.Join(got, ", "), "text/plain: plain text, text/html: <p>html</p>"; g != e {
+		t.Errorf("nested parts = %q; want %q", g, e)
+	}
+
+	if err := outer.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	rewound, err := io.ReadAll(outer)
+	if err != nil {
+		t.Fatalf("ReadAll: unexpected error %s", err)
+	}
+	if string(rewound) != message {
+		t.Errorf("rewound message differs:\n got: %q\nwant: %q", rewound, message)
+	}
+}
+
+func TestPartString(t *testing.T) {
+	part := itermultipart.NewPart().
+		SetFormName("file").
+		SetFileName("file.txt").
+		SetContentString("contents")
+
+	if g, e := part.String(), `Part{name: "file", filename: "file.txt", content-type: "application/octet-stream", headers: 2, content: *strings.Reader (8 bytes)}`; g != e {
+		t.Errorf("String() = %s; want %s", g, e)
+	}
+	if g := fmt.Sprintf("%#v", part); !strings.Contains(g, `Content: *strings.Reader (8 bytes)}`) {
+		t.Errorf("unexpected GoString() %s", g)
+	}
+	if g, e := fmt.Sprint(itermultipart.NewPart().SetContent(io.MultiReader())), "Part{headers: 0, content: *io.multiReader (size unknown)}"; g != e {
+		t.Errorf("String() = %s; want %s", g, e)
+	}
+
+	// content must not be consumed
+	content, _ := io.ReadAll(part.Content)
+	if string(content) != "contents" {
+		t.Errorf("content consumed, left %q", content)
+	}
+}
+
+func TestPartSetFileNameNonASCII(t *testing.T) {
+	tests := []struct {
+		fileName    string
+		disposition string
+	}{
+		{"plain.txt", `form-data; filename=plain.txt; name=file`},
+		{`résumé "v2".pdf`, `form-data; name=file; filename="r_sum_ \"v2\".pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%20%22v2%22.pdf`},
+		{"файл.txt", `form-data; name=file; filename="____.txt"; filename*=UTF-8''%D1%84%D0%B0%D0%B9%D0%BB.txt`},
+	}
+	for _, tt := range tests {
+		part := itermultipart.NewPart().SetFormName("file").SetFileName(tt.fileName)
+		if got := part.Header.Get("Content-Disposition"); got != tt.disposition {
+			t.Errorf("%s: Content-Disposition\n got: %s\nwant: %s", tt.fileName, got, tt.disposition)
+		}
+
+		// file name is decoded by other implementations as well
+		for _, p := range []*itermultipart.Part{part, {Header: part.Header}} {
+			if got := p.FileName(); got != tt.fileName {
+				t.Errorf("%s: FileName() = %q", tt.fileName, got)
+			}
+		}
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		part.AddToWriter(mw)
+		mw.Close()
+		mp, err := multipart.NewReader(&buf, mw.Boundary()).NextPart()
+		if err != nil {
+			t.Fatalf("%s: NextPart: unexpected error %s", tt.fileName, err)
+		}
+		if got := mp.FileName(); got != tt.fileName {
+			t.Errorf("%s: multipart.Part.FileName() = %q", tt.fileName, got)
+		}
+	}
+}
+
+func TestPartConstructors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(path, []byte(`{"from":"file"}`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+	jsonPart, err := itermultipart.NewJSONPart("json", map[string]int{"a": 1})
+	if err != nil {
+		t.Fatalf("NewJSONPart: unexpected error %s", err)
+	}
+	if _, err := itermultipart.NewJSONPart("bad", func() {}); err == nil {
+		t.Error("NewJSONPart: expected error for unsupported value")
+	}
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("field", "value"),
+		itermultipart.NewFilePart("file", path),
+		jsonPart,
+	))
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+
+	got, err := collectParts(t, itermultipart.NewParser(&buf, src.Boundary()).Parts())
+	if err != nil {
+		t.Fatalf("parse: unexpected error %s", err)
+	}
+	want := []parsedPart{
+		{header: "map[Content-Disposition:[form-data; name=field]]", content: "value"},
+		{header: "map[Content-Disposition:[form-data; filename=data.json; name=file] Content-Type:[application/json]]", content: `{"from":"file"}`},
+		{header: "map[Content-Disposition:[form-data; name=json] Content-Type:[application/json]]", content: `{"a":1}`},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("\n got: %q\nwant: %q", got, want)
+	}
+
+	// missing file is reported while generating the message
+	src = itermultipart.NewSource(itermultipart.PartSeq(itermultipart.NewFilePart("file", filepath.Join(t.TempDir(), "missing"))))
+	if _, err := src.WriteTo(io.Discard); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestPartSetContentFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte("a,b\n1,2\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+	modTime := time.Date(2024, 2, 29, 12, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes: %s", err)
+	}
+
+	part := itermultipart.NewPart().SetContentFile(path).SetFormName("report")
+	if part.FileName() != "report.csv" || part.FormName() != "report" {
+		t.Errorf("unexpected names %q, %q", part.FileName(), part.FormName())
+	}
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil {
+		t.Fatalf("ParseMediaType: unexpected error %s", err)
+	}
+	if params["size"] != "8" {
+		t.Errorf("got size %q, want 8", params["size"])
+	}
+	if got, err := time.Parse(time.RFC1123Z, params["modification-date"]); err != nil || !got.Equal(modTime) {
+		t.Errorf("got modification-date %q (%v), want %s", params["modification-date"], err, modTime)
+	}
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(part))
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if !strings.Contains(buf.String(), "\r\n\r\na,b\n1,2\n\r\n") {
+		t.Errorf("file content is not written: %q", buf.String())
+	}
+
+	// file is opened only when the part is reached
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove: %s", err)
+	}
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	if _, err := src.WriteTo(io.Discard); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestPartWriteTo(t *testing.T) {
+	part := itermultipart.NewFieldPart("field", "hello, world").
+		SetHeaderValue("X-Custom", "custom").
+		SetTransferEncoding(itermultipart.TransferEncodingBase64)
+
+	var buf bytes.Buffer
+	n, err := part.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := "Content-Disposition: form-data; name=field\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"X-Custom: custom\r\n" +
+		"\r\n" +
+		"aGVsbG8sIHdvcmxk"
+	if buf.String() != want || n != int64(buf.Len()) {
+		t.Fatalf("wrote %d bytes\n got: %q\nwant: %q", n, buf.String(), want)
+	}
+
+	parsed, err := itermultipart.ParsePart(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !maps.EqualFunc(parsed.Header, part.Header, slices.Equal) || parsed.FormName() != "field" {
+		t.Errorf("got header %v, want %v", parsed.Header, part.Header)
+	}
+	if content, err := io.ReadAll(parsed.Content); err != nil || string(content) != "aGVsbG8sIHdvcmxk" {
+		t.Errorf("got content %q, error %v", content, err)
+	}
+
+	var closed int
+	part = itermultipart.NewPart().SetContentFactory(func() (io.ReadCloser, error) {
+		return closeRecorder{Reader: strings.NewReader("lazy"), closed: &closed}, nil
+	})
+	buf.Reset()
+	if _, err := part.WriteTo(&buf); err != nil || buf.String() != "\r\nlazy" || closed != 1 {
+		t.Errorf("factory content: wrote %q, closed %d times, error %v", buf.String(), closed, err)
+	}
+
+	if _, err := itermultipart.ParsePart(strings.NewReader("X-Long: "+strings.Repeat("x", 100)+"\r\n\r\n"), itermultipart.WithMaxHeaderBytes(50)); !errors.Is(err, itermultipart.ErrHeaderTooLarge) {
+		t.Errorf("got error %v, want %v", err, itermultipart.ErrHeaderTooLarge)
+	}
+}
+
+func TestPartSetContentFunc(t *testing.T) {
+	var calls int
+	errFailed := errors.New("failed")
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("lazy").SetContentFunc(func() (io.Reader, error) {
+			calls++
+			return strings.NewReader("lazy content"), nil
+		}),
+		itermultipart.NewPart().SetFormName("failing").SetContentFunc(func() (io.Reader, error) {
+			return nil, errFailed
+		}),
+	))
+	if calls != 0 {
+		t.Fatalf("content produced before reading")
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(src); !errors.Is(err, errFailed) {
+		t.Errorf("got error %v, want %v", err, errFailed)
+	}
+	if calls != 1 || !strings.HasSuffix(buf.String(), "\r\n\r\nlazy content") {
+		t.Errorf("content produced %d times, message %q", calls, buf.String())
+	}
+}
+
+func TestPartDisposition(t *testing.T) {
+	part := itermultipart.NewPart().SetFileName("report.pdf") // without SetFormName
+	if got := part.Header.Get("Content-Disposition"); got != "form-data; filename=report.pdf" {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+
+	part = itermultipart.NewPart().SetAttachment().SetFileName("report.pdf")
+	if got := part.Header.Get("Content-Disposition"); got != "attachment; filename=report.pdf" {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+	if part.Disposition() != "attachment" || part.FileName() != "report.pdf" || part.FormName() != "" {
+		t.Errorf("unexpected accessors %q, %q, %q", part.Disposition(), part.FileName(), part.FormName())
+	}
+
+	part.SetInline()
+	if got := part.Header.Get("Content-Disposition"); got != "inline; filename=report.pdf" {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+
+	// parsed parameters are not shared between parts
+	var withoutHeader itermultipart.Part
+	withoutHeader.Header = make(textproto.MIMEHeader)
+	if withoutHeader.FormName() != "" {
+		t.Fatal("unexpected form name")
+	}
+	withoutHeader.SetFormName("a")
+	if other := itermultipart.NewPart(); other.FormName() != "" || other.FileName() != "" {
+		t.Errorf("parameters leaked to another part: %q, %q", other.FormName(), other.FileName())
+	}
+}
+
+func TestPartDispositionEscaping(t *testing.T) {
+	names := []string{"simple", "with space", `with "quotes"`, `back\slash`, "semi;colon", "café", `mixed "é\`}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			var want bytes.Buffer
+			mw := multipart.NewWriter(&want)
+			fw, err := mw.CreateFormFile(name, name+".txt")
+			if err != nil {
+				t.Fatalf("CreateFormFile: unexpected error %s", err)
+			}
+			io.WriteString(fw, "file")
+			fw, err = mw.CreateFormField(name)
+			if err != nil {
+				t.Fatalf("CreateFormField: unexpected error %s", err)
+			}
+			io.WriteString(fw, "field")
+			mw.Close()
+
+			parts := itermultipart.PartSeq(
+				itermultipart.NewPart().SetFormName(name).SetFileName(name+".txt").SetContentString("file"),
+				itermultipart.NewFieldPart(name, "field"),
+			)
+
+			// stdlib compatible mode produces the same message
+			src := itermultipart.NewSource(parts, itermultipart.WithStdlibDisposition())
+			src.SetBoundary(mw.Boundary())
+			var got bytes.Buffer
+			if _, err := src.WriteTo(&got); err != nil {
+				t.Fatalf("WriteTo: unexpected error %s", err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("\n got: %q\nwant: %q", got.String(), want.String())
+			}
+
+			// default mode is understood by the stdlib reader
+			src = itermultipart.NewSource(parts)
+			got.Reset()
+			if _, err := src.WriteTo(&got); err != nil {
+				t.Fatalf("WriteTo: unexpected error %s", err)
+			}
+			for part, err := range itermultipart.PartsFromReader(multipart.NewReader(&got, src.Boundary()), false) {
+				if err != nil {
+					t.Fatalf("read: unexpected error %s", err)
+				}
+				if part.FormName() != name {
+					t.Errorf("got form name %q, want %q", part.FormName(), name)
+				}
+				if fileName := part.FileName(); fileName != "" && fileName != name+".txt" {
+					t.Errorf("got file name %q, want %q", fileName, name+".txt")
+				}
+			}
+		})
+	}
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(itermultipart.NewFieldPart("a\r\nX-Evil: 1", "v")), itermultipart.WithStdlibDisposition())
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if !strings.Contains(buf.String(), "\r\nContent-Disposition: form-data; name=\"a%0D%0AX-Evil: 1\"\r\n") {
+		t.Errorf("CR and LF are not encoded: %q", buf.String())
+	}
+}
@@ -0,0 +1,100 @@
+package itermultipart
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+)
+
+// MaxJSONContentSize limits the content size of parts marshaled by [Part.MarshalJSON],
+// larger parts should be stored elsewhere, i.e. with [SaveFiles], and referenced by metadata.
+const MaxJSONContentSize = 10 << 20
+
+// partJSON is the JSON representation of [Part].
+type partJSON struct {
+	Header           textproto.MIMEHeader `json:"header"`
+	Content          []byte               `json:"content"`                     // base64 encoded by encoding/json
+	Meta             map[string]any       `json:"meta,omitempty"`              // see Part.Meta
+	TransferEncoding string               `json:"transfer_encoding,omitempty"` // not applied yet, see Part.SetTransferEncoding
+	ContentEncoding  string               `json:"content_encoding,omitempty"`  // not applied yet, see Part.SetContentEncoding
+	SMTPCompat       bool                 `json:"smtp_compat,omitempty"`       // see Part.SetSMTPCompat
+}
+
+// MarshalJSON implements [json.Marshaler], so parts may be put on a message queue or stored in a job table
+// between processing stages. The header, metadata and base64 encoded content are written.
+// Transfer and content encodings set on the part are stored as pending, so they are applied after unmarshaling.
+// The content is read completely: seekable contents are sought back, content factories are opened and closed,
+// other contents are replaced by the read bytes so the part is still usable.
+// Contents larger than [MaxJSONContentSize] fail with [ErrPartTooLarge].
+func (p *Part) MarshalJSON() ([]byte, error) {
+	content, err := p.readContent()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(partJSON{
+		Header:           p.Header,
+		Content:          content,
+		Meta:             p.Meta,
+		TransferEncoding: p.transferEncoding,
+		ContentEncoding:  p.contentEncoding,
+		SMTPCompat:       p.smtpCompat,
+	})
+}
+
+// readContent reads the whole content keeping the part usable.
+func (p *Part) readContent() ([]byte, error) {
+	var r io.Reader
+	switch {
+	case p.contentFactory != nil:
+		rc, err := p.contentFactory()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		r = rc
+	case p.Content == nil:
+		return nil, nil
+	default:
+		r = p.Content
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, MaxJSONContentSize+1))
+	if err == nil && len(content) > MaxJSONContentSize {
+		err = fmt.Errorf("%w: content exceeds %d bytes", ErrPartTooLarge, MaxJSONContentSize)
+	}
+	if err != nil || p.contentFactory != nil {
+		return content, err
+	}
+
+	if seeker, ok := p.Content.(io.Seeker); ok {
+		_, err = seeker.Seek(-int64(len(content)), io.SeekCurrent)
+		return content, err
+	}
+	p.Content = bytes.NewReader(content)
+	return content, nil
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] for parts marshaled by [Part.MarshalJSON].
+// The part is reset first, the content is kept in memory.
+func (p *Part) UnmarshalJSON(data []byte) error {
+	var pj partJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return err
+	}
+
+	p.Reset()
+	if p.Header == nil {
+		p.Header = make(textproto.MIMEHeader, len(pj.Header))
+	}
+	for k, v := range pj.Header {
+		p.Header[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	p.Content = bytes.NewReader(pj.Content)
+	p.Meta = pj.Meta
+	p.transferEncoding = pj.TransferEncoding
+	p.contentEncoding = pj.ContentEncoding
+	p.smtpCompat = pj.SMTPCompat
+	return nil
+}
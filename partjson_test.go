@@ -0,0 +1,73 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestPartJSON(t *testing.T) {
+	part := itermultipart.NewPart().SetFormName("file").SetFileName("a.txt").
+		SetTransferEncoding(itermultipart.TransferEncodingBase64).
+		SetContent(iotest.HalfReader(strings.NewReader("hello, world")))
+	part.Meta = map[string]any{"lines": 1.0}
+
+	type job struct {
+		ID   int                 `json:"id"`
+		Part *itermultipart.Part `json:"part"`
+	}
+	data, err := json.Marshal(job{ID: 1, Part: part})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	// content is replaced by the read bytes
+	if content, _ := io.ReadAll(part.Content); string(content) != "hello, world" {
+		t.Errorf("content after marshaling %q", content)
+	}
+
+	var decoded job
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got := decoded.Part
+	if got.FormName() != "file" || got.FileName() != "a.txt" || got.Meta["lines"] != 1.0 {
+		t.Errorf("decoded %v with meta %v", got, got.Meta)
+	}
+
+	// pending transfer encoding is applied after unmarshaling
+	var want, encoded bytes.Buffer
+	part.Content = strings.NewReader("hello, world")
+	if _, err := part.WriteTo(&want); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, err := got.WriteTo(&encoded); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if encoded.String() != want.String() {
+		t.Errorf("got %q, want %q", encoded.String(), want.String())
+	}
+
+	// seekable content is sought back
+	seekable := strings.NewReader("seekable")
+	if _, err := json.Marshal(itermultipart.NewPart().SetContent(seekable)); err != nil || seekable.Len() != len("seekable") {
+		t.Errorf("seekable content is not sought back: %d bytes left, error %v", seekable.Len(), err)
+	}
+
+	large := itermultipart.NewPart().SetContent(io.LimitReader(zeroReader{}, itermultipart.MaxJSONContentSize+1))
+	if _, err := json.Marshal(large); !errors.Is(err, itermultipart.ErrPartTooLarge) {
+		t.Errorf("got error %v, want %v", err, itermultipart.ErrPartTooLarge)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,43 @@
+package itermultipart
+
+import "io"
+
+// WithProgress sets the callback reporting the progress of the message generation.
+// It's called every time a chunk of the message is generated with the part the chunk belongs to,
+// the number of content bytes of the part generated so far and the total number of message bytes generated so far.
+// Chunks with part headings report the part with unchanged partBytes, the closing delimiter is reported with nil part.
+// [Source.WriteTo] reports coalesced headings and small contents before they are written to the target.
+func WithProgress(progress func(part *Part, partBytes, totalBytes int64)) SourceOption {
+	return func(s *Source) {
+		s.progress = progress
+	}
+}
+
+// reportProgress accounts n bytes generated for the part. Content bytes also advance the part counter.
+func (s *Source) reportProgress(part *Part, n int, content bool) {
+	if n == 0 {
+		return
+	}
+	s.accountStats(int64(n), content)
+	if s.progress == nil {
+		return
+	}
+	s.totalBytes += int64(n)
+	if content {
+		s.partBytes += int64(n)
+	}
+	s.progress(part, s.partBytes, s.totalBytes)
+}
+
+// progressWriter reports content bytes of the part written to the target.
+type progressWriter struct {
+	s      *Source
+	part   *Part
+	target io.Writer
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.target.Write(p)
+	w.s.reportProgress(w.part, n, true)
+	return n, err
+}
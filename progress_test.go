@@ -0,0 +1,64 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestSourceWithProgress(t *testing.T) {
+	large := strings.Repeat("x", 100<<10)
+	parts := []*itermultipart.Part{
+		itermultipart.NewPart().SetFormName("small").SetContentString("small"),
+		itermultipart.NewPart().SetFormName("large").SetContent(iotest.HalfReader(strings.NewReader(large))),
+		itermultipart.NewPart().SetFormName("empty"),
+	}
+	wantPartBytes := map[string]int64{"small": 5, "large": int64(len(large)), "empty": 0}
+
+	consumers := map[string]func(*itermultipart.Source, io.Writer) error{
+		"Read": func(src *itermultipart.Source, w io.Writer) error {
+			_, err := io.Copy(w, struct{ io.Reader }{src})
+			return err
+		},
+		"WriteTo": func(src *itermultipart.Source, w io.Writer) error {
+			_, err := src.WriteTo(w)
+			return err
+		},
+	}
+	for name, consume := range consumers {
+		t.Run(name, func(t *testing.T) {
+			parts[0].SetContentString("small")
+			parts[1].SetContent(iotest.HalfReader(strings.NewReader(large)))
+
+			var lastTotal int64
+			gotPartBytes := make(map[string]int64)
+			src := itermultipart.NewSource(itermultipart.PartSeq(parts...),
+				itermultipart.WithProgress(func(part *itermultipart.Part, partBytes, totalBytes int64) {
+					if totalBytes <= lastTotal {
+						t.Errorf("total bytes don't grow: %d after %d", totalBytes, lastTotal)
+					}
+					lastTotal = totalBytes
+					if part != nil {
+						gotPartBytes[part.FormName()] = partBytes
+					}
+				}))
+
+			var buf bytes.Buffer
+			if err := consume(src, &buf); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if lastTotal != int64(buf.Len()) {
+				t.Errorf("reported total %d, message size %d", lastTotal, buf.Len())
+			}
+			for name, want := range wantPartBytes {
+				if got := gotPartBytes[name]; got != want {
+					t.Errorf("part %s: reported %d bytes, want %d", name, got, want)
+				}
+			}
+		})
+	}
+}
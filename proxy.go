@@ -0,0 +1,109 @@
+package itermultipart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ProxyOption configures [NewProxyRequest].
+type ProxyOption func(*proxyConfig)
+
+type proxyConfig struct {
+	transform     func(part *Part) (*Part, error)
+	parserOptions []ParserOption
+	sourceOptions []SourceOption
+}
+
+// WithProxyTransform sets the function modifying parts in flight, see [ProxyParts].
+func WithProxyTransform(transform func(part *Part) (*Part, error)) ProxyOption {
+	return func(c *proxyConfig) {
+		c.transform = transform
+	}
+}
+
+// WithProxyParserOptions configures parsing of the incoming message, i.e. limits.
+func WithProxyParserOptions(opts ...ParserOption) ProxyOption {
+	return func(c *proxyConfig) {
+		c.parserOptions = append(c.parserOptions, opts...)
+	}
+}
+
+// WithProxySourceOptions configures generation of the forwarded message.
+func WithProxySourceOptions(opts ...SourceOption) ProxyOption {
+	return func(c *proxyConfig) {
+		c.sourceOptions = append(c.sourceOptions, opts...)
+	}
+}
+
+// ProxyParts returns the sequence forwarding parts of a one-shot sequence, i.e. [PartsFromRequest] or [Parser.Parts],
+// to a [Source]. Every part is passed through the transform, if it's not nil, before it's written,
+// like by [MapParts]: the transform may modify the part or return another one, return nil to drop the part or an error to abort.
+// A forwarded part and its content stay valid until the [Source] writes the part completely and pulls the next one,
+// so contents are streamed without buffering.
+// The returned sequence may be iterated only once, further iterations yield an error, so the [Source] can't compute
+// [Source.ContentLength] or be rewound. That's why [NewRequest] must not be used with it, use [NewProxyRequest].
+func ProxyParts(parts iter.Seq2[*Part, error], transform func(part *Part) (*Part, error)) iter.Seq2[*Part, error] {
+	var iterated bool
+	return func(yield func(*Part, error) bool) {
+		if iterated {
+			yield(nil, errors.New("proxied parts can be iterated only once"))
+			return
+		}
+		iterated = true
+
+		if transform != nil {
+			parts = MapParts(parts, transform)
+		}
+		for part, err := range parts {
+			if !yield(part, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// NewProxyRequest creates a request forwarding the multipart body of the incoming request to url,
+// i.e. from an HTTP handler of a multipart-aware reverse proxy. The body is parsed with [Parser]
+// and streamed to the new request through [ProxyParts] part by part, so parts may be filtered
+// or modified with [WithProxyTransform]. The subtype and parameters of the incoming Content-Type are kept,
+// the boundary is new. Part headers are written in the order they were received, see [WithOrderedHeaders].
+// Content length of the forwarded message is unknown and the request can't be retried.
+func NewProxyRequest(ctx context.Context, method, url string, in *http.Request, opts ...ProxyOption) (*http.Request, error) {
+	var cfg proxyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(in.Header.Get(contentTypeHeader))
+	if err != nil {
+		return nil, fmt.Errorf("multipart: %w", err)
+	}
+	subtype, ok := strings.CutPrefix(mediaType, "multipart/")
+	if !ok {
+		return nil, fmt.Errorf("multipart: not a multipart media type %q", mediaType)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart: no boundary in Content-Type")
+	}
+	delete(params, "boundary")
+
+	parser := NewParser(in.Body, boundary, cfg.parserOptions...)
+	src := NewSource(ProxyParts(parser.Parts(), cfg.transform), append([]SourceOption{WithOrderedHeaders()}, cfg.sourceOptions...)...)
+	if src.boundaryErr != nil {
+		return nil, src.boundaryErr
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, src)
+	if err != nil {
+		return nil, err
+	}
+	src.ctx = ctx // for part conditions
+	req.Header.Set(contentTypeHeader, src.ContentType(subtype, params))
+	return req, nil
+}
@@ -0,0 +1,108 @@
+package itermultipart_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestNewProxyRequest(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		io.WriteString(w, r.FormValue("name")+"|"+r.FormValue("secret")+"|"+r.FormValue("token")+"|"+string(content))
+	}))
+	defer upstream.Close()
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := itermultipart.NewProxyRequest(r.Context(), http.MethodPost, upstream.URL, r,
+			itermultipart.WithProxyTransform(func(part *itermultipart.Part) (*itermultipart.Part, error) {
+				switch part.FormName() {
+				case "secret":
+					return nil, nil
+				case "token":
+					return itermultipart.NewFieldPart("token", "redacted"), nil
+				}
+				return part, nil
+			}))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	large := strings.Repeat("x", 1<<20)
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("name", "alice"),
+		itermultipart.NewFieldPart("secret", "hunter2"),
+		itermultipart.NewFieldPart("token", "abc"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("large.txt").SetContentString(large),
+	))
+	req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, proxy.URL, src)
+	if err != nil {
+		t.Fatalf("NewRequest: unexpected error %s", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: unexpected error %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d: %s", resp.StatusCode, body)
+	}
+	if want := "alice||redacted|" + large; string(body) != want {
+		t.Errorf("got %.40q..., want %.40q...", body, want)
+	}
+}
+
+func TestNewProxyRequestErrors(t *testing.T) {
+	for _, contentType := range []string{"", "text/plain", "multipart/form-data"} {
+		in := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(""))
+		in.Header.Set("Content-Type", contentType)
+		if _, err := itermultipart.NewProxyRequest(context.Background(), http.MethodPost, "http://example.com", in); err == nil {
+			t.Errorf("%q: expected error", contentType)
+		}
+	}
+}
+
+func TestProxyPartsOnce(t *testing.T) {
+	parts := itermultipart.ProxyParts(smallFields(2), nil)
+	var count int
+	for _, err := range parts {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("got %d parts, want 2", count)
+	}
+	for _, err := range parts {
+		if err == nil {
+			t.Error("expected error on the second iteration")
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package itermultipart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+const contentRangeHeader = "Content-Range"
+
+// ErrInvalidContentRange is returned by [JoinRangedParts] when ranges of the split part are malformed,
+// not contiguous or incomplete.
+var ErrInvalidContentRange = errors.New("invalid content range")
+
+// JoinRangedParts reassembles parts that were split into several parts with the same form name
+// and "Content-Range: bytes start-end/total" headers into one logical part.
+// Ranges must go in order starting from 0 and must be contiguous, the joined part ends when the total size is reached
+// or, if the total size is unknown ("*"), when the next part is not a continuation.
+// Joined part has headers of the first piece except Content-Range. Other parts are passed through as-is.
+// Note that [Part] becomes invalid on the next iteration so reference to it must not be held.
+func JoinRangedParts(parts iter.Seq2[*Part, error]) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		next, stop := iter.Pull2(parts)
+		defer stop()
+
+		joined := new(Part)
+		part, err, ok := next()
+		for ok {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			rng, hasRange, err := parseContentRange(part)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !hasRange {
+				if !yield(part, nil) {
+					return
+				}
+				part, err, ok = next()
+				continue
+			}
+			if rng.start != 0 {
+				yield(nil, fmt.Errorf("%w: first range of %q starts at %d", ErrInvalidContentRange, part.FormName(), rng.start))
+				return
+			}
+
+			joiner := &rangeJoiner{
+				next:    next,
+				name:    part.FormName(),
+				current: part.Content,
+				rng:     rng,
+			}
+			joined.Reset()
+			joined.Header = maps.Clone(part.Header)
+			joined.Header.Del(contentRangeHeader)
+			joined.Content = joiner
+			if !yield(joined, nil) {
+				return
+			}
+
+			// skip unread pieces to get to the following part
+			if _, err := io.Copy(io.Discard, joiner); err != nil {
+				yield(nil, err)
+				return
+			}
+			part, err, ok = joiner.following, joiner.followingErr, joiner.hasFollowing
+		}
+	}
+}
+
+type contentRange struct {
+	start, end, total int64 // total is -1 if unknown
+}
+
+func parseContentRange(part *Part) (contentRange, bool, error) {
+	v := part.Header.Get(contentRangeHeader)
+	if v == "" {
+		return contentRange{}, false, nil
+	}
+
+	invalid := func() (contentRange, bool, error) {
+		return contentRange{}, false, fmt.Errorf("%w: %q", ErrInvalidContentRange, v)
+	}
+
+	spec, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return invalid()
+	}
+	startEnd, total, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return invalid()
+	}
+	start, end, ok := strings.Cut(startEnd, "-")
+	if !ok {
+		return invalid()
+	}
+
+	var (
+		rng contentRange
+		err error
+	)
+	if rng.start, err = strconv.ParseInt(start, 10, 64); err != nil || rng.start < 0 {
+		return invalid()
+	}
+	if rng.end, err = strconv.ParseInt(end, 10, 64); err != nil || rng.end < rng.start {
+		return invalid()
+	}
+	if total == "*" {
+		rng.total = -1
+	} else if rng.total, err = strconv.ParseInt(total, 10, 64); err != nil || rng.total <= rng.end {
+		return invalid()
+	}
+	return rng, true, nil
+}
+
+// rangeJoiner reads contents of the split part pieces one by one.
+type rangeJoiner struct {
+	next    func() (*Part, error, bool)
+	name    string
+	current io.Reader
+	rng     contentRange
+	read    int64 // read from the current piece
+
+	done         bool
+	err          error
+	following    *Part // the first part after the joined one
+	followingErr error
+	hasFollowing bool
+}
+
+func (j *rangeJoiner) Read(p []byte) (int, error) {
+	for {
+		if j.err != nil {
+			return 0, j.err
+		}
+		if j.done {
+			return 0, io.EOF
+		}
+
+		n, err := j.current.Read(p)
+		j.read += int64(n)
+		if j.read > j.rng.end-j.rng.start+1 {
+			j.err = fmt.Errorf("%w: piece of %q is longer than its range", ErrInvalidContentRange, j.name)
+			return 0, j.err
+		}
+		switch {
+		case errors.Is(err, nil):
+			return n, nil
+		case errors.Is(err, io.EOF):
+			j.err = j.advance()
+			if n > 0 {
+				return n, nil
+			}
+		default:
+			j.err = err
+			return n, err
+		}
+	}
+}
+
+// advance switches to the next piece or finishes the joined part.
+func (j *rangeJoiner) advance() error {
+	if j.read != j.rng.end-j.rng.start+1 {
+		return fmt.Errorf("%w: piece of %q is shorter than its range", ErrInvalidContentRange, j.name)
+	}
+
+	complete := j.rng.total >= 0 && j.rng.end+1 == j.rng.total
+
+	part, err, ok := j.next()
+	if ok && err != nil {
+		return err
+	}
+	if !ok {
+		if !complete && j.rng.total >= 0 {
+			return fmt.Errorf("%w: %q is incomplete", ErrInvalidContentRange, j.name)
+		}
+		j.finish(nil, nil, false)
+		return nil
+	}
+
+	rng, hasRange, rngErr := parseContentRange(part)
+	if rngErr != nil {
+		return rngErr
+	}
+	continuation := hasRange && part.FormName() == j.name && !complete
+	if !continuation {
+		if !complete && j.rng.total >= 0 {
+			return fmt.Errorf("%w: %q is incomplete", ErrInvalidContentRange, j.name)
+		}
+		j.finish(part, nil, true)
+		return nil
+	}
+
+	if rng.start != j.rng.end+1 || rng.total != j.rng.total {
+		return fmt.Errorf("%w: range %d-%d of %q does not continue %d-%d",
+			ErrInvalidContentRange, rng.start, rng.end, j.name, j.rng.start, j.rng.end)
+	}
+
+	j.current = part.Content
+	j.rng = rng
+	j.read = 0
+	return nil
+}
+
+func (j *rangeJoiner) finish(part *Part, err error, ok bool) {
+	j.done = true
+	j.following, j.followingErr, j.hasFollowing = part, err, ok
+}
@@ -0,0 +1,146 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func rangedMessage(t *testing.T, parts ...*itermultipart.Part) *multipart.Reader {
+	t.Helper()
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(parts...))
+	var b strings.Builder
+	if _, err := src.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	return multipart.NewReader(strings.NewReader(b.String()), src.Boundary())
+}
+
+func rangedPart(name, contentRange, content string) *itermultipart.Part {
+	return itermultipart.NewPart().
+		SetFormName(name).
+		SetHeaderValue("Content-Range", contentRange).
+		SetContentString(content)
+}
+
+func TestJoinRangedParts(t *testing.T) {
+	t.Run("contiguous", func(t *testing.T) {
+		r := rangedMessage(t,
+			itermultipart.NewPart().SetFormName("before").SetContentString("1"),
+			rangedPart("file", "bytes 0-4/11", "Hello"),
+			rangedPart("file", "bytes 5-6/11", ", "),
+			rangedPart("file", "bytes 7-10/11", "Joe!"),
+			rangedPart("unknown", "bytes 0-1/*", "ab"),
+			rangedPart("unknown", "bytes 2-3/*", "cd"),
+			itermultipart.NewPart().SetFormName("after").SetContentString("2"),
+		)
+
+		var got []string
+		for part, err := range itermultipart.JoinRangedParts(itermultipart.PartsFromReader(r, false)) {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if part.Header.Get("Content-Range") != "" {
+				t.Errorf("%s: unexpected Content-Range header", part.FormName())
+			}
+			content, err := io.ReadAll(part.Content)
+			if err != nil {
+				t.Fatalf("%s: ReadAll: %s", part.FormName(), err)
+			}
+			got = append(got, part.FormName()+"="+string(content))
+		}
+
+		want := "before=1 file=Hello, Joe! unknown=abcd after=2"
+		if g := strings.Join(got, " "); g != want {
+			t.Errorf("got %q; want %q", g, want)
+		}
+	})
+
+	t.Run("unread pieces are skipped", func(t *testing.T) {
+		r := rangedMessage(t,
+			rangedPart("file", "bytes 0-1/4", "ab"),
+			rangedPart("file", "bytes 2-3/4", "cd"),
+			itermultipart.NewPart().SetFormName("after").SetContentString("2"),
+		)
+
+		var names []string
+		for part, err := range itermultipart.JoinRangedParts(itermultipart.PartsFromReader(r, false)) {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			names = append(names, part.FormName())
+		}
+		if g, e := strings.Join(names, ","), "file,after"; g != e {
+			t.Errorf("got parts %q; want %q", g, e)
+		}
+	})
+
+	errorTests := []struct {
+		name  string
+		parts []*itermultipart.Part
+	}{
+		{"gap", []*itermultipart.Part{rangedPart("file", "bytes 0-1/6", "ab"), rangedPart("file", "bytes 3-5/6", "def")}},
+		{"not from start", []*itermultipart.Part{rangedPart("file", "bytes 1-2/3", "bc")}},
+		{"incomplete", []*itermultipart.Part{rangedPart("file", "bytes 0-1/4", "ab")}},
+		{"interrupted", []*itermultipart.Part{rangedPart("file", "bytes 0-1/4", "ab"), rangedPart("other", "bytes 2-3/4", "cd")}},
+		{"short piece", []*itermultipart.Part{rangedPart("file", "bytes 0-2/3", "ab")}},
+		{"long piece", []*itermultipart.Part{rangedPart("file", "bytes 0-1/3", "abc")}},
+		{"malformed", []*itermultipart.Part{rangedPart("file", "0-1/2", "ab")}},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rangedMessage(t, tt.parts...)
+
+			var gotErr error
+			for part, err := range itermultipart.JoinRangedParts(itermultipart.PartsFromReader(r, false)) {
+				if err != nil {
+					gotErr = err
+					break
+				}
+				if _, err := io.ReadAll(part.Content); err != nil {
+					gotErr = err
+					break
+				}
+			}
+			if !errors.Is(gotErr, itermultipart.ErrInvalidContentRange) {
+				t.Errorf("expected ErrInvalidContentRange, got %v", gotErr)
+			}
+		})
+	}
+}
+
+func ExampleJoinRangedParts() {
+	message := `--boundary
+Content-Disposition: form-data; name="file"; filename="example.txt"
+Content-Range: bytes 0-5/13
+
+Hello,
+--boundary
+Content-Disposition: form-data; name="file"; filename="example.txt"
+Content-Range: bytes 6-12/13
+
+ World!
+--boundary--`
+	message = strings.ReplaceAll(message, "\n", "\r\n")
+	reader := multipart.NewReader(strings.NewReader(message), "boundary")
+
+	for part, err := range itermultipart.JoinRangedParts(itermultipart.PartsFromReader(reader, false)) {
+		if err != nil {
+			panic(err)
+		}
+
+		content, err := io.ReadAll(part.Content)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s: %s\n", part.FileName(), content)
+	}
+	// Output:
+	// example.txt: Hello, World!
+}
@@ -0,0 +1,100 @@
+package itermultipart
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate the message is generated with. [golang.org/x/time/rate.Limiter] implements it.
+type RateLimiter interface {
+	// WaitN blocks until n bytes may be generated. n never exceeds Burst.
+	WaitN(ctx context.Context, n int) error
+	// Burst returns the maximum number of bytes generated at once.
+	Burst() int
+}
+
+// WithRateLimiter makes the [Source] generate the message with the rate allowed by the limiter.
+// Both [Source.Read] and [Source.WriteTo] are throttled, the latter writes chunks not larger than the limiter's burst.
+// Context set by [WithContext] or [NewRequest] is passed to the limiter.
+func WithRateLimiter(limiter RateLimiter) SourceOption {
+	return func(s *Source) {
+		s.limiter = limiter
+	}
+}
+
+// WithRateLimit makes the [Source] generate at most bytesPerSecond bytes per second, see [WithRateLimiter].
+func WithRateLimit(bytesPerSecond int) SourceOption {
+	return WithRateLimiter(newBandwidthLimiter(bytesPerSecond))
+}
+
+// bandwidthLimiter is a token bucket refilled with the rate bytes per second.
+type bandwidthLimiter struct {
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// maxBurst limits the burst of the bandwidthLimiter to keep the rate smooth for high limits.
+const maxBurst = 32 << 10
+
+func newBandwidthLimiter(bytesPerSecond int) *bandwidthLimiter {
+	burst := max(1, min(bytesPerSecond, maxBurst))
+	return &bandwidthLimiter{rate: float64(bytesPerSecond), burst: burst, tokens: float64(burst)}
+}
+
+func (l *bandwidthLimiter) Burst() int {
+	return l.burst
+}
+
+func (l *bandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens = min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n) // reserve, the debt is paid by waiting
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedWriter writes chunks not larger than the limiter's burst waiting for the limiter before each of them.
+type rateLimitedWriter struct {
+	ctx     context.Context
+	limiter RateLimiter
+	target  io.Writer
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), w.limiter.Burst())]
+		if err := w.limiter.WaitN(w.ctx, len(chunk)); err != nil {
+			return n, err
+		}
+		written, err := w.target.Write(chunk)
+		n += written
+		if err != nil {
+			return n, err
+		}
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
@@ -0,0 +1,72 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/xakep666/itermultipart"
+)
+
+type recordingLimiter struct {
+	burst int
+	waits []int
+}
+
+func (l *recordingLimiter) WaitN(_ context.Context, n int) error {
+	l.waits = append(l.waits, n)
+	return nil
+}
+
+func (l *recordingLimiter) Burst() int {
+	return l.burst
+}
+
+func TestSourceWithRateLimiter(t *testing.T) {
+	consumers := map[string]func(*itermultipart.Source, io.Writer) error{
+		"Read": func(src *itermultipart.Source, w io.Writer) error {
+			_, err := io.Copy(w, struct{ io.Reader }{src})
+			return err
+		},
+		"WriteTo": func(src *itermultipart.Source, w io.Writer) error {
+			_, err := src.WriteTo(w)
+			return err
+		},
+	}
+	for name, consume := range consumers {
+		limiter := &recordingLimiter{burst: 100}
+		src := itermultipart.NewSource(smallFields(50), itermultipart.WithRateLimiter(limiter))
+		var buf bytes.Buffer
+		if err := consume(src, &buf); err != nil {
+			t.Fatalf("%s: unexpected error %s", name, err)
+		}
+
+		total := 0
+		for _, n := range limiter.waits {
+			if n > limiter.burst {
+				t.Errorf("%s: waited for %d bytes, burst is %d", name, n, limiter.burst)
+			}
+			total += n
+		}
+		if total != buf.Len() {
+			t.Errorf("%s: waited for %d bytes, message size is %d", name, total, buf.Len())
+		}
+	}
+}
+
+func TestSourceWithRateLimit(t *testing.T) {
+	const rate = 20 << 10
+	src := itermultipart.NewSource(smallFields(250), itermultipart.WithRateLimit(rate))
+	size, _ := src.ContentLength()
+
+	start := time.Now()
+	if _, err := src.WriteTo(io.Discard); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	// the first burst is sent immediately
+	if elapsed, want := time.Since(start), time.Duration(float64(size-rate)/rate*float64(time.Second)); elapsed < want*9/10 {
+		t.Errorf("%d bytes are sent in %s, expected at least %s", size, elapsed, want)
+	}
+}
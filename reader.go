@@ -2,10 +2,13 @@ package itermultipart
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"iter"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"strings"
 )
 
 // PartsFromReader reads each part from the provided [multipart.Reader] and yields it to the caller.
@@ -57,3 +60,39 @@ func PartsFromRequest(r *http.Request, raw bool) iter.Seq2[*Part, error] {
 	}
 	return PartsFromReader(reader, raw)
 }
+
+// PartsFromResponse reads each part from the body of the http response with any multipart Content-Type,
+// i.e. "multipart/mixed" batch responses or "multipart/byteranges" bodies, and yields it to the caller.
+// If raw is true, it reads the raw part using [multipart.Reader.NextRawPart].
+// The response body is not closed.
+// Note that [Part] becomes invalid on the next iteration so reference to it must not be held.
+func PartsFromResponse(resp *http.Response, raw bool) iter.Seq2[*Part, error] {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get(contentTypeHeader))
+	switch {
+	case err != nil:
+		err = fmt.Errorf("multipart: %w", err)
+	case !strings.HasPrefix(mediaType, "multipart/"):
+		err = fmt.Errorf("multipart: not a multipart media type %q", mediaType)
+	case params["boundary"] == "":
+		err = errors.New("multipart: no boundary in Content-Type")
+	}
+	if err != nil {
+		return func(yield func(*Part, error) bool) {
+			yield(nil, err)
+		}
+	}
+	return PartsFromReader(multipart.NewReader(resp.Body, params["boundary"]), raw)
+}
+
+// AsMultipartReader returns a [multipart.Reader] of the message generated by the [Source],
+// so libraries accepting only standard types can consume messages built from iterators.
+// The message is generated lazily while the reader is read, it's not materialized.
+func AsMultipartReader(src *Source) *multipart.Reader {
+	return multipart.NewReader(src, src.Boundary())
+}
+
+// MultipartReaderFromParts returns a [multipart.Reader] of the message generated from parts, see [AsMultipartReader].
+// Options configure the [Source] generating the message.
+func MultipartReaderFromParts(parts iter.Seq2[*Part, error], opts ...SourceOption) *multipart.Reader {
+	return AsMultipartReader(NewSource(parts, opts...))
+}
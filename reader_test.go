@@ -5,15 +5,18 @@ import (
 	"io"
 	"maps"
 	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"slices"
 	"strings"
+	"testing"
+	"time"
 
 	"github.com/xakep666/itermultipart"
 )
 
-func ExampleParts() {
+func ExamplePartsFromReader() {
 	message := `--boundary
 Content-Disposition: form-data; name="myfile"; filename="example.txt"
 
@@ -117,3 +120,67 @@ value for key
 	// ---content---
 	// value for key
 }
+
+func ExampleAsMultipartReader() {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("title", "greeting"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("hello.txt").SetContentString("Hello, world!"),
+	))
+
+	// i.e. for a library accepting only *multipart.Reader
+	form, err := itermultipart.AsMultipartReader(src).ReadForm(1 << 20)
+	if err != nil {
+		panic(err)
+	}
+	defer form.RemoveAll()
+
+	fmt.Println("title:", form.Value["title"][0])
+	file, err := form.File["file"][0].Open()
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	fmt.Print(form.File["file"][0].Filename, ": ")
+	io.Copy(os.Stdout, file)
+	fmt.Println()
+	// Output:
+	// title: greeting
+	// hello.txt: Hello, world!
+}
+
+func TestPartsFromResponse(t *testing.T) {
+	const content = "0123456789"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "digits.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Range", "bytes=0-1,5-6")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: unexpected error %s", err)
+	}
+	defer resp.Body.Close()
+
+	var got []string
+	for part, err := range itermultipart.PartsFromResponse(resp, false) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		data, _ := io.ReadAll(part.Content)
+		got = append(got, part.Header.Get("Content-Range")+" "+string(data))
+	}
+	if want := []string{"bytes 0-1/10 01", "bytes 5-6/10 56"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, contentType := range []string{"", "text/plain", "multipart/mixed"} {
+		resp := &http.Response{Header: http.Header{"Content-Type": {contentType}}, Body: http.NoBody}
+		for _, err := range itermultipart.PartsFromResponse(resp, false) {
+			if err == nil {
+				t.Errorf("%q: expected error", contentType)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package itermultipart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// NewReboundaryReader returns a reader of the multipart message from r with the boundary replaced by newBoundary.
+// Only delimiter lines are rewritten, the preamble, part headers, contents and the epilogue are passed byte-for-byte
+// without buffering parts, so it's suitable for proxies re-framing large bodies.
+// The new boundary must not occur in contents, random boundaries practically never do.
+func NewReboundaryReader(r io.Reader, oldBoundary, newBoundary string) (io.Reader, error) {
+	if oldBoundary == "" {
+		return nil, errors.New("multipart: empty boundary")
+	}
+	if err := validateBoundary(newBoundary); err != nil {
+		return nil, err
+	}
+	return &reboundaryReader{
+		br:        bufio.NewReader(r),
+		old:       []byte("--" + oldBoundary),
+		new:       []byte("--" + newBoundary),
+		lineStart: true,
+	}, nil
+}
+
+// Reboundary is like [NewReboundaryReader] but takes the Content-Type header value of the message
+// and generates the new boundary like [NewSource] does with the given options.
+// It returns the Content-Type header value with the new boundary.
+func Reboundary(r io.Reader, contentType string, opts ...SourceOption) (io.Reader, string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, "", fmt.Errorf("multipart: %w", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, "", fmt.Errorf("multipart: not a multipart media type %q", mediaType)
+	}
+
+	src := NewSource(nil, opts...)
+	if src.boundaryErr != nil {
+		return nil, "", src.boundaryErr
+	}
+	rr, err := NewReboundaryReader(r, params["boundary"], src.Boundary())
+	if err != nil {
+		return nil, "", err
+	}
+	params["boundary"] = src.Boundary()
+	return rr, mime.FormatMediaType(mediaType, params), nil
+}
+
+type reboundaryReader struct {
+	br        *bufio.Reader
+	old, new  []byte // "--" + boundary
+	lineStart bool   // the next chunk starts a line
+	done      bool   // the closing delimiter is passed, the epilogue is copied as is
+	pending   []byte // rest of the current chunk
+	scratch   []byte // rewritten delimiter line
+	err       error
+}
+
+func (rr *reboundaryReader) Read(p []byte) (int, error) {
+	for len(rr.pending) == 0 {
+		if rr.err != nil {
+			return 0, rr.err
+		}
+		rr.next()
+	}
+	n := copy(p, rr.pending)
+	rr.pending = rr.pending[n:]
+	return n, nil
+}
+
+// next reads the next line or its fragment if it doesn't fit the buffer, rewriting delimiter lines.
+func (rr *reboundaryReader) next() {
+	chunk, err := rr.br.ReadSlice('\n')
+	if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+		rr.err = err
+	}
+
+	if rr.lineStart && !rr.done {
+		if rest, ok := bytes.CutPrefix(chunk, rr.old); ok && isReboundaryLineEnd(rest, errors.Is(err, io.EOF)) {
+			rr.done = bytes.HasPrefix(rest, []byte("--"))
+			rr.scratch = append(append(rr.scratch[:0], rr.new...), rest...)
+			chunk = rr.scratch
+		}
+	}
+	rr.lineStart = err == nil
+	rr.pending = chunk
+}
+
+// isReboundaryLineEnd checks the rest of the delimiter line after the boundary:
+// optional "--" of the closing delimiter, transport padding and CRLF, which may be missing at the end of the message.
+func isReboundaryLineEnd(rest []byte, eof bool) bool {
+	rest = bytes.TrimPrefix(rest, []byte("--"))
+	if eof && len(bytes.TrimLeft(rest, " \t")) == 0 {
+		return true
+	}
+	return isDelimiterLineEnd(rest)
+}
@@ -0,0 +1,98 @@
+package itermultipart_test
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestNewReboundaryReader(t *testing.T) {
+	long := strings.Repeat("x", 4096) // the following "--old" doesn't start a line
+	message := "preamble\r\n" +
+		"--old\r\n" +
+		"content-disposition: form-data; name=a\r\n" +
+		"X-Custom:   spaced value\r\n" +
+		"\r\n" +
+		"--older\r\n" +
+		"text with --old inside\r\n" +
+		long + "--old\r\n" +
+		"--old  \r\n" +
+		"\r\n" +
+		"second\r\n" +
+		"--old--\r\n" +
+		"epilogue\r\n" +
+		"--old\r\n"
+	want := "preamble\r\n" +
+		"--new\r\n" +
+		"content-disposition: form-data; name=a\r\n" +
+		"X-Custom:   spaced value\r\n" +
+		"\r\n" +
+		"--older\r\n" +
+		"text with --old inside\r\n" +
+		long + "--old\r\n" +
+		"--new  \r\n" +
+		"\r\n" +
+		"second\r\n" +
+		"--new--\r\n" +
+		"epilogue\r\n" +
+		"--old\r\n"
+
+	r, err := itermultipart.NewReboundaryReader(strings.NewReader(message), "old", "new")
+	if err != nil {
+		t.Fatalf("NewReboundaryReader: unexpected error %s", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: unexpected error %s", err)
+	}
+	if string(got) != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+
+	// closing delimiter without CRLF at the end
+	r, _ = itermultipart.NewReboundaryReader(strings.NewReader("--old\r\n\r\nx\r\n--old--"), "old", "new")
+	if got, _ := io.ReadAll(r); string(got) != "--new\r\n\r\nx\r\n--new--" {
+		t.Errorf("got %q", got)
+	}
+
+	if _, err := itermultipart.NewReboundaryReader(strings.NewReader(message), "old", "bad\n"); err == nil {
+		t.Error("expected error for invalid boundary")
+	}
+}
+
+func TestReboundary(t *testing.T) {
+	src := itermultipart.NewSource(smallFields(3))
+	contentType := src.ContentType("mixed", map[string]string{"type": "text/plain"})
+
+	r, newContentType, err := itermultipart.Reboundary(src, contentType,
+		itermultipart.WithBoundaryFunc(func() (string, error) { return "fresh", nil }))
+	if err != nil {
+		t.Fatalf("Reboundary: unexpected error %s", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(newContentType)
+	if err != nil || mediaType != "multipart/mixed" || params["boundary"] != "fresh" || params["type"] != "text/plain" {
+		t.Fatalf("unexpected Content-Type %q", newContentType)
+	}
+
+	var count int
+	for part, err := range itermultipart.PartsFromReader(multipart.NewReader(r, "fresh"), false) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if _, err := io.Copy(io.Discard, part.Content); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("got %d parts, want 3", count)
+	}
+
+	if _, _, err := itermultipart.Reboundary(strings.NewReader(""), "text/plain"); err == nil {
+		t.Error("expected error for non-multipart Content-Type")
+	}
+}
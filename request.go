@@ -0,0 +1,80 @@
+package itermultipart
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// NewRequest wraps [http.NewRequestWithContext] using the [Source] as a request body.
+// It sets Content-Type header using [Source.FormDataContentType] and ContentLength if [Source.ContentLength] can compute it.
+// If the [Source] is created with [WithReplayableParts] and the contents of all parts are seekable
+// or set with [Part.SetContentFactory], GetBody is set so the request can be retried or redirected.
+// Seekable contents implementing [io.Closer] are closed after writing unless [WithContentsKeptOpen] is used, so they prevent retries.
+// Other sequences, i.e. parsed or proxied parts, are iterated only once while the body is sent,
+// so the request has unknown length and no GetBody.
+// Provided context is passed to the part conditions, see [ConditionalPart].
+func NewRequest(ctx context.Context, method, url string, src *Source) (*http.Request, error) {
+	if src.boundaryErr != nil {
+		return nil, src.boundaryErr
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, src)
+	if err != nil {
+		return nil, err
+	}
+
+	src.ctx = ctx // for part conditions
+	req.Header.Set(contentTypeHeader, src.FormDataContentType())
+	if length, ok := src.ContentLength(); ok {
+		req.ContentLength = length
+	}
+	if rewindable(src) {
+		req.GetBody = func() (io.ReadCloser, error) {
+			clone, err := src.Clone()
+			if err != nil {
+				return nil, err
+			}
+			return clone, nil
+		}
+	}
+
+	return req, nil
+}
+
+// rewindable reports whether contents of all parts can be rewound by [Source.Rewind].
+// Sequences not declared replayable are not iterated.
+func rewindable(src *Source) (ok bool) {
+	if !src.replayable {
+		return false
+	}
+	if src.recoverPanics {
+		defer func() {
+			if recover() != nil {
+				ok = false
+			}
+		}()
+	}
+
+	for part, err := range src.parts {
+		if err != nil {
+			return false
+		}
+		if part.Content == nil || part.contentFactory != nil {
+			continue
+		}
+		if nested, ok := part.Content.(*Source); ok {
+			if !rewindable(nested) {
+				return false
+			}
+			continue
+		}
+		if _, ok := part.Content.(io.Closer); ok && !src.keepOpen {
+			return false // closed after writing
+		}
+		if _, ok := part.Content.(io.Seeker); !ok {
+			return false
+		}
+	}
+	return true
+}
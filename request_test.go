@@ -0,0 +1,160 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestNewRequest(t *testing.T) {
+	t.Run("replayable", func(t *testing.T) {
+		src := itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("myfile").SetFileName("my-file.txt").SetContentString("my file contents"),
+			itermultipart.NewPart().SetFormName("key").SetContentString("val"),
+		), itermultipart.WithReplayableParts())
+
+		req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com/upload", src)
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error %s", err)
+		}
+		if g, e := req.Header.Get("Content-Type"), src.FormDataContentType(); g != e {
+			t.Errorf("Content-Type = %q; want %q", g, e)
+		}
+		if req.ContentLength <= 0 {
+			t.Errorf("ContentLength = %d; want positive", req.ContentLength)
+		}
+		if req.GetBody == nil {
+			t.Fatal("GetBody is not set")
+		}
+
+		first, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("ReadAll: unexpected error %s", err)
+		}
+		if g, e := int64(len(first)), req.ContentLength; g != e {
+			t.Errorf("body length = %d; want %d", g, e)
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			t.Fatalf("GetBody: unexpected error %s", err)
+		}
+		second, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("ReadAll: unexpected error %s", err)
+		}
+		if string(first) != string(second) {
+			t.Errorf("replayed body differs:\n got: %q\nwant: %q", second, first)
+		}
+	})
+
+	t.Run("one-shot", func(t *testing.T) {
+		src := itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("key").SetContent(io.MultiReader(strings.NewReader("val"))),
+		), itermultipart.WithReplayableParts())
+
+		req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com/upload", src)
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error %s", err)
+		}
+		if req.ContentLength != 0 {
+			t.Errorf("ContentLength = %d; want unknown", req.ContentLength)
+		}
+		if req.GetBody != nil {
+			t.Error("GetBody must not be set for one-shot contents")
+		}
+	})
+
+	t.Run("one-shot sequence", func(t *testing.T) {
+		var message bytes.Buffer
+		mw := multipart.NewWriter(&message)
+		mw.WriteField("a", "first")
+		mw.WriteField("b", "second")
+		mw.Close()
+
+		src := itermultipart.NewSource(itermultipart.PartsFromReader(multipart.NewReader(&message, mw.Boundary()), false))
+		req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com/upload", src)
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error %s", err)
+		}
+		if req.ContentLength != 0 || req.GetBody != nil {
+			t.Errorf("ContentLength = %d, GetBody set: %t; want unknown length and no GetBody", req.ContentLength, req.GetBody != nil)
+		}
+
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm: unexpected error %s", err)
+		}
+		if a, b := req.FormValue("a"), req.FormValue("b"); a != "first" || b != "second" {
+			t.Errorf("body lost parts: a=%q, b=%q", a, b)
+		}
+	})
+
+	t.Run("closable", func(t *testing.T) {
+		f, err := os.Create(filepath.Join(t.TempDir(), "file.txt"))
+		if err != nil {
+			t.Fatalf("Create: %s", err)
+		}
+		defer f.Close()
+
+		for _, keepOpen := range []bool{false, true} {
+			opts := []itermultipart.SourceOption{itermultipart.WithReplayableParts()}
+			if keepOpen {
+				opts = append(opts, itermultipart.WithContentsKeptOpen())
+			}
+			src := itermultipart.NewSource(itermultipart.PartSeq(
+				itermultipart.NewPart().SetFormName("file").SetContent(f),
+			), opts...)
+			req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com/upload", src)
+			if err != nil {
+				t.Fatalf("NewRequest: unexpected error %s", err)
+			}
+			if (req.GetBody != nil) != keepOpen {
+				t.Errorf("keep open %t: GetBody is set: %t", keepOpen, req.GetBody != nil)
+			}
+		}
+	})
+}
+
+func ExampleNewRequest() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for part, err := range itermultipart.PartsFromRequest(r, false) {
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(part.Content)
+			fmt.Fprintf(w, "%s: %s\n", part.FormName(), content)
+		}
+	}))
+	defer srv.Close()
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContentString("Hello, world!"),
+		itermultipart.NewPart().SetFormName("key").SetContentString("val"),
+	))
+	req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, srv.URL, src)
+	if err != nil {
+		panic(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	io.Copy(os.Stdout, resp.Body)
+	// Output:
+	// file: Hello, world!
+	// key: val
+}
@@ -0,0 +1,167 @@
+package itermultipart
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+)
+
+// MultipartResponseWriter writes a multipart message to the [http.ResponseWriter] part by part,
+// like [multipart.Writer] does, using the same boundary generation and heading formatting as [Source].
+// It's the server-side counterpart of [NewRequest]: i.e. for "multipart/mixed" batch responses
+// or "multipart/x-mixed-replace" streams.
+type MultipartResponseWriter struct {
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	src       *Source // boundary and headings
+	subtype   string
+	buf       bytes.Buffer
+	autoFlush bool
+	closed    bool
+}
+
+// NewMultipartResponseWriter creates a [MultipartResponseWriter] of the given subtype, i.e. "mixed".
+// Options configure the boundary and headings like for [NewSource].
+// Content-Type header of the response is set, so the status code may still be written with [http.ResponseWriter.WriteHeader].
+func NewMultipartResponseWriter(w http.ResponseWriter, subtype string, opts ...SourceOption) *MultipartResponseWriter {
+	mw := &MultipartResponseWriter{
+		w:       w,
+		rc:      http.NewResponseController(w),
+		src:     NewSource(nil, opts...),
+		subtype: subtype,
+	}
+	if mw.src.boundaryErr == nil {
+		w.Header().Set(contentTypeHeader, mw.ContentType())
+	}
+	return mw
+}
+
+// ContentType returns the Content-Type header value of the response.
+func (mw *MultipartResponseWriter) ContentType() string {
+	return mw.src.ContentType(mw.subtype, nil)
+}
+
+// Boundary returns the boundary of the message.
+func (mw *MultipartResponseWriter) Boundary() string {
+	return mw.src.Boundary()
+}
+
+// SetAutoFlush makes the writer flush the response before every part and on [MultipartResponseWriter.Close],
+// so the client gets each part as soon as it's written.
+func (mw *MultipartResponseWriter) SetAutoFlush(enabled bool) {
+	mw.autoFlush = enabled
+}
+
+// NextPart starts a new part with the given header and returns the writer of its content.
+// The content of the previous part must be written completely before calling it.
+func (mw *MultipartResponseWriter) NextPart(header textproto.MIMEHeader) (io.Writer, error) {
+	if err := mw.startPart(&Part{Header: header}); err != nil {
+		return nil, err
+	}
+	return mw.w, nil
+}
+
+// WritePart writes the part with its content, applying the transfer encoding set by [Part.SetTransferEncoding].
+// Content set with [Part.SetContentFactory] is opened and closed by the writer.
+func (mw *MultipartResponseWriter) WritePart(part *Part) error {
+	if err := mw.startPart(part); err != nil {
+		return err
+	}
+	return writePartContent(mw.w, part)
+}
+
+// writePartContent copies the content of the part to w opening it with the factory if needed.
+func writePartContent(w io.Writer, part *Part) error {
+	content := part.Content
+	if part.contentFactory != nil {
+		rc, err := part.contentFactory()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		content = rc
+	}
+	if content == nil {
+		return nil
+	}
+	_, err := io.Copy(w, part.encodedContent(content))
+	return err
+}
+
+func (mw *MultipartResponseWriter) startPart(part *Part) error {
+	if mw.closed {
+		return errors.New("multipart response writer is closed")
+	}
+	if mw.src.boundaryErr != nil {
+		return mw.src.boundaryErr
+	}
+	if mw.autoFlush && mw.src.firstHeadingWritten {
+		if err := mw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	mw.buf.Reset()
+	mw.src.writePartHeading(&mw.buf, part, !mw.src.firstHeadingWritten)
+	mw.src.firstHeadingWritten = true
+	_, err := mw.buf.WriteTo(mw.w)
+	return err
+}
+
+// Flush sends the data written so far to the client. It returns an error if the [http.ResponseWriter] doesn't support flushing.
+func (mw *MultipartResponseWriter) Flush() error {
+	return mw.rc.Flush()
+}
+
+// Close writes the closing delimiter. It doesn't close the underlying connection.
+func (mw *MultipartResponseWriter) Close() error {
+	if mw.closed {
+		return nil
+	}
+	if mw.src.boundaryErr != nil {
+		return mw.src.boundaryErr
+	}
+	mw.closed = true
+
+	mw.buf.Reset()
+	mw.src.writeEnding(&mw.buf)
+	if _, err := mw.buf.WriteTo(mw.w); err != nil {
+		return err
+	}
+	if mw.autoFlush {
+		return mw.Flush()
+	}
+	return nil
+}
+
+// ServeParts writes the message generated by src as the response with the status code.
+// It sets Content-Type of the subtype, i.e. "mixed", and Content-Length if [Source.ContentLength] can compute it.
+// The response is flushed after every part if the [http.ResponseWriter] supports flushing,
+// so the client gets each part as soon as it's generated.
+// Headers are already sent when an error of generation is returned, so it can only be logged.
+func ServeParts(w http.ResponseWriter, statusCode int, src *Source, subtype string) error {
+	if src.boundaryErr != nil {
+		return src.boundaryErr
+	}
+
+	w.Header().Set(contentTypeHeader, src.ContentType(subtype, nil))
+	if length, ok := src.ContentLength(); ok {
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+	w.WriteHeader(statusCode)
+
+	rc := http.NewResponseController(w)
+	src.afterPart = func() error {
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+	defer func() { src.afterPart = nil }()
+
+	_, err := src.WriteTo(w)
+	return err
+}
@@ -0,0 +1,118 @@
+package itermultipart_test
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestMultipartResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	mw := itermultipart.NewMultipartResponseWriter(rec, "mixed")
+	mw.SetAutoFlush(true)
+	rec.WriteHeader(http.StatusMultiStatus)
+
+	w, err := mw.NextPart(textproto.MIMEHeader{"Content-Type": {"text/plain"}})
+	if err != nil {
+		t.Fatalf("NextPart: unexpected error %s", err)
+	}
+	io.WriteString(w, "first")
+	if rec.Flushed {
+		t.Error("flushed before the second part")
+	}
+	err = mw.WritePart(itermultipart.NewPart().SetContentType("application/json").
+		SetTransferEncoding(itermultipart.TransferEncodingBase64).SetContentString(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("WritePart: unexpected error %s", err)
+	}
+	if !rec.Flushed {
+		t.Error("not flushed before the second part")
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %s", err)
+	}
+	if _, err := mw.NextPart(nil); err == nil {
+		t.Error("NextPart: expected error after Close")
+	}
+
+	if rec.Code != http.StatusMultiStatus {
+		t.Errorf("got status %d", rec.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" || params["boundary"] != mw.Boundary() {
+		t.Fatalf("unexpected Content-Type %q", rec.Header().Get("Content-Type"))
+	}
+
+	want := []struct{ contentType, content string }{
+		{"text/plain", "first"},
+		{"application/json", `{"a":1}`},
+	}
+	var i int
+	for part, err := range itermultipart.DecodeTransferEncoding(itermultipart.PartsFromReader(multipart.NewReader(rec.Body, params["boundary"]), true)) {
+		if err != nil {
+			t.Fatalf("read: unexpected error %s", err)
+		}
+		content, _ := io.ReadAll(part.Content)
+		if i >= len(want) || part.ContentType() != want[i].contentType || string(content) != want[i].content {
+			t.Errorf("unexpected part %d: %s %q", i, part.ContentType(), content)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("got %d parts, want %d", i, len(want))
+	}
+}
+
+// flushRecorder records the response size at every flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes = append(r.flushes, r.Body.Len())
+	r.ResponseRecorder.Flush()
+}
+
+func TestServeParts(t *testing.T) {
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "first"),
+		itermultipart.NewFieldPart("b", "second"),
+	), itermultipart.WithReplayableParts())
+	if err := itermultipart.ServeParts(rec, http.StatusMultiStatus, src, "mixed"); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if rec.Code != http.StatusMultiStatus {
+		t.Errorf("unexpected status %d", rec.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" || params["boundary"] != src.Boundary() {
+		t.Errorf("unexpected Content-Type %q", rec.Header().Get("Content-Type"))
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("Content-Length %s, body length %d", cl, rec.Body.Len())
+	}
+	if len(rec.flushes) != 2 || rec.flushes[0] == 0 || rec.flushes[0] >= rec.flushes[1] {
+		t.Errorf("response is not flushed after every part, flushes at %v", rec.flushes)
+	}
+
+	r := multipart.NewReader(rec.Body, src.Boundary())
+	for _, want := range []string{"first", "second"} {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if got, _ := io.ReadAll(part); string(got) != want {
+			t.Errorf("got content %q, want %q", got, want)
+		}
+	}
+}
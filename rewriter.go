@@ -0,0 +1,38 @@
+package itermultipart
+
+import (
+	"io"
+	"iter"
+)
+
+// Rewriter re-encodes a captured multipart message with modified parts, i.e. to maintain test fixtures
+// or to feed downstream services with mutated realistic payloads.
+// The preamble, the epilogue and Content-Type parameters are kept, part headers are written sorted like [Source] does.
+type Rewriter struct {
+	// Transform modifies the sequence of parsed parts: replaces contents, edits headers, drops or adds parts.
+	// Parts are yielded by [Parser], so they are valid only until the next iteration. Nil keeps parts as is.
+	Transform func(parts iter.Seq2[*Part, error]) iter.Seq2[*Part, error]
+	// FreshBoundary makes the Rewriter use a new random boundary instead of the original one.
+	FreshBoundary bool
+	// ParserOptions configure parsing of the captured message.
+	ParserOptions []ParserOption
+}
+
+// Rewrite parses the message with the given Content-Type header value from r and writes the rewritten one to w.
+// It returns the Content-Type header value of the rewritten message.
+func (rw *Rewriter) Rewrite(w io.Writer, r io.Reader, contentType string) (string, error) {
+	m, err := DecodeMessage(r, contentType, rw.ParserOptions...)
+	if err != nil {
+		return "", err
+	}
+	if rw.Transform != nil {
+		m.Parts = rw.Transform(m.Parts)
+	}
+	if rw.FreshBoundary {
+		m.Boundary = NewSource(m.Parts).Boundary()
+	}
+	if err := m.Encode(w); err != nil {
+		return "", err
+	}
+	return m.ContentType(), nil
+}
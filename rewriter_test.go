@@ -0,0 +1,87 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"iter"
+	"mime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestRewriter(t *testing.T) {
+	m := itermultipart.NewMessage("form-data", itermultipart.PartSeq(
+		itermultipart.NewFieldPart("user", "alice"),
+		itermultipart.NewFieldPart("token", "secret"),
+		itermultipart.NewFieldPart("drop", "me"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("a.txt").SetContentString("file content"),
+	))
+	m.Preamble, m.Epilogue = []byte("preamble"), []byte("epilogue")
+	var captured bytes.Buffer
+	if err := m.Encode(&captured); err != nil {
+		t.Fatalf("Encode: unexpected error %s", err)
+	}
+
+	t.Run("identity", func(t *testing.T) {
+		var out bytes.Buffer
+		contentType, err := new(itermultipart.Rewriter).Rewrite(&out, bytes.NewReader(captured.Bytes()), m.ContentType())
+		if err != nil {
+			t.Fatalf("Rewrite: unexpected error %s", err)
+		}
+		if contentType != m.ContentType() || out.String() != captured.String() {
+			t.Errorf("message is changed:\n got: %s %q\nwant: %s %q", contentType, out.String(), m.ContentType(), captured.String())
+		}
+	})
+
+	t.Run("modify", func(t *testing.T) {
+		rw := itermultipart.Rewriter{
+			FreshBoundary: true,
+			Transform: func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+				return func(yield func(*itermultipart.Part, error) bool) {
+					for part, err := range parts {
+						if err == nil {
+							switch part.FormName() {
+							case "drop":
+								continue
+							case "token":
+								part.SetContentString("REDACTED")
+							case "file":
+								part.SetHeaderValue("X-Scanned", "yes")
+							}
+						}
+						if !yield(part, err) {
+							return
+						}
+					}
+				}
+			},
+		}
+		var out bytes.Buffer
+		contentType, err := rw.Rewrite(&out, bytes.NewReader(captured.Bytes()), m.ContentType())
+		if err != nil {
+			t.Fatalf("Rewrite: unexpected error %s", err)
+		}
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil || params["boundary"] == m.Boundary {
+			t.Fatalf("boundary is not changed: %s, %v", contentType, err)
+		}
+		if !strings.HasPrefix(out.String(), "preamble\r\n") || !strings.HasSuffix(out.String(), "--\r\nepilogue") {
+			t.Errorf("preamble or epilogue is lost: %q", out.String())
+		}
+
+		got, err := collectParts(t, itermultipart.NewParser(&out, params["boundary"]).Parts())
+		if err != nil {
+			t.Fatalf("parse: unexpected error %s", err)
+		}
+		want := []parsedPart{
+			{header: "map[Content-Disposition:[form-data; name=user]]", content: "alice"},
+			{header: "map[Content-Disposition:[form-data; name=token]]", content: "REDACTED"},
+			{header: "map[Content-Disposition:[form-data; filename=a.txt; name=file] Content-Type:[application/octet-stream] X-Scanned:[yes]]", content: "file content"},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("\n got: %q\nwant: %q", got, want)
+		}
+	})
+}
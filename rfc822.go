@@ -0,0 +1,89 @@
+package itermultipart
+
+import (
+	"bytes"
+	"io"
+	"iter"
+	"maps"
+	"net/mail"
+	"net/textproto"
+	"slices"
+)
+
+const messageMediaType = "message/rfc822"
+
+// NewMessagePart returns the "message/rfc822" part with the embedded email message read from r,
+// i.e. to forward an email as an attachment.
+func NewMessagePart(r io.Reader) *Part {
+	return NewPart().SetContentType(messageMediaType).SetContent(r)
+}
+
+// NewMessagePartFromSource returns the "message/rfc822" part with the embedded email message
+// consisting of the header and the multipart body of the given subtype generated by src.
+// "MIME-Version" and "Content-Type" headers are added. The body is streamed lazily,
+// src is rewound if the outer message is generated again.
+func NewMessagePartFromSource(header textproto.MIMEHeader, src *Source, subtype string) *Part {
+	var heading bytes.Buffer
+	for _, k := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[k] {
+			heading.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	heading.WriteString("MIME-Version: 1.0\r\n")
+	heading.WriteString(contentTypeHeader + ": " + src.ContentType(subtype, nil) + "\r\n\r\n")
+
+	started := false
+	return NewPart().SetContentType(messageMediaType).SetContentFunc(func() (io.Reader, error) {
+		if started {
+			if err := src.Rewind(); err != nil {
+				return nil, err
+			}
+		}
+		started = true
+		return io.MultiReader(bytes.NewReader(heading.Bytes()), src), nil
+	})
+}
+
+// ParseMessagePart reads the header of the email message embedded into the "message/rfc822" part,
+// the body of the returned message reads the part content.
+func ParseMessagePart(part *Part) (*mail.Message, error) {
+	if part.Content == nil {
+		return mail.ReadMessage(bytes.NewReader(nil))
+	}
+	return mail.ReadMessage(part.Content)
+}
+
+// DigestContentType returns the Content-Type for a multipart/digest message (RFC 2046) with this [Source]'s Boundary.
+// Parts of the digest are email messages, see [NewMessagePart], the Content-Type may be omitted for them.
+func (s *Source) DigestContentType() string {
+	return s.ContentType("digest", nil)
+}
+
+// DigestParts returns the sequence setting the "message/rfc822" Content-Type, the default one in multipart/digest
+// messages, to parts without Content-Type, so they can be handled like parts of other messages.
+// Parts are not modified permanently: the header is restored after the part is yielded.
+func DigestParts(parts iter.Seq2[*Part, error]) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if _, ok := part.Header[contentTypeHeader]; ok {
+				if !yield(part, nil) {
+					return
+				}
+				continue
+			}
+
+			part.Header.Set(contentTypeHeader, messageMediaType)
+			next := yield(part, nil)
+			part.Header.Del(contentTypeHeader)
+			if !next {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestMessagePart(t *testing.T) {
+	body := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentType("text/plain").SetContentString("forwarded text"),
+	))
+	header := textproto.MIMEHeader{"Subject": {"forwarded"}, "From": {"alice@example.com"}}
+	digest := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewMessagePartFromSource(header, body, "mixed"),
+		itermultipart.NewMessagePart(strings.NewReader("Subject: plain\r\n\r\nplain text")),
+	))
+	_, params, err := mime.ParseMediaType(digest.DigestContentType())
+	if err != nil {
+		t.Fatalf("invalid Content-Type: %s", err)
+	}
+
+	type embedded struct {
+		subject, contentType, body string
+	}
+	want := []embedded{
+		{"forwarded", body.MixedContentType(), "--" + body.Boundary() + "\r\nContent-Type: text/plain\r\n\r\nforwarded text\r\n--" + body.Boundary() + "--\r\n"},
+		{"plain", "", "plain text"},
+	}
+
+	for i := range 2 { // the second message checks rewinding
+		if i > 0 {
+			if err := digest.Rewind(); err != nil {
+				t.Fatalf("Rewind: unexpected error %s", err)
+			}
+		}
+		var message bytes.Buffer
+		if _, err := digest.WriteTo(&message); err != nil {
+			t.Fatalf("%d: WriteTo: unexpected error %s", i, err)
+		}
+		// parts of digests may omit Content-Type
+		raw := strings.ReplaceAll(message.String(), "Content-Type: message/rfc822\r\n", "")
+
+		var got []embedded
+		for part, err := range itermultipart.DigestParts(itermultipart.NewParser(strings.NewReader(raw), params["boundary"]).Parts()) {
+			if err != nil {
+				t.Fatalf("%d: unexpected error %s", i, err)
+			}
+			if ct := part.ContentType(); ct != "message/rfc822" {
+				t.Errorf("%d: got Content-Type %q", i, ct)
+			}
+			msg, err := itermultipart.ParseMessagePart(part)
+			if err != nil {
+				t.Fatalf("%d: ParseMessagePart: unexpected error %s", i, err)
+			}
+			content, err := io.ReadAll(msg.Body)
+			if err != nil {
+				t.Fatalf("%d: reading body: unexpected error %s", i, err)
+			}
+			got = append(got, embedded{msg.Header.Get("Subject"), msg.Header.Get("Content-Type"), string(content)})
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%d: got messages %q, want %q", i, got, want)
+		}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Errorf("%d: message %d: got %q, want %q", i, j, got[j], want[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package itermultipart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrFileTooLarge is returned by [SaveFiles] if the file exceeds the limit set by [WithMaxFileSize].
+var ErrFileTooLarge = errors.New("file is too large")
+
+// CollisionPolicy defines what [SaveFiles] does if the file with the same name already exists.
+type CollisionPolicy int
+
+const (
+	// CollisionFail makes [SaveFiles] fail with an error satisfying errors.Is(err, fs.ErrExist). It's the default.
+	CollisionFail CollisionPolicy = iota
+	// CollisionOverwrite makes [SaveFiles] replace the existing file.
+	CollisionOverwrite
+	// CollisionRename makes [SaveFiles] add a numeric suffix to the name, i.e. "file-1.txt".
+	CollisionRename
+)
+
+// maxRenameAttempts limits the number of suffixes tried by [CollisionRename].
+const maxRenameAttempts = 10000
+
+// SavedFile describes the file part saved by [SaveFiles].
+type SavedFile struct {
+	FormName string
+	FileName string // sanitized file name from the part
+	Path     string // path of the saved file
+	Size     int64
+	Header   textproto.MIMEHeader
+}
+
+type saveOptions struct {
+	maxFileSize int64
+	collision   CollisionPolicy
+}
+
+// SaveOption configures [SaveFiles].
+type SaveOption func(*saveOptions)
+
+// WithMaxFileSize limits the size of every saved file. Default is unlimited.
+func WithMaxFileSize(n int64) SaveOption {
+	return func(o *saveOptions) {
+		o.maxFileSize = n
+	}
+}
+
+// WithCollisionPolicy sets the policy for files with the same name. Default is [CollisionFail].
+func WithCollisionPolicy(policy CollisionPolicy) SaveOption {
+	return func(o *saveOptions) {
+		o.collision = policy
+	}
+}
+
+// SaveFiles streams contents of file parts, ones having a file name, into files of the directory dir.
+// Other parts are skipped. Only the base name of the file name is used, so parts can't write outside of dir.
+// On error the partially written file is removed and files saved before are returned along with the error.
+func SaveFiles(parts iter.Seq2[*Part, error], dir string, opts ...SaveOption) ([]SavedFile, error) {
+	var options saveOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var saved []SavedFile
+	for part, err := range parts {
+		if err != nil {
+			return saved, err
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		file, err := saveFile(part, dir, &options)
+		if err != nil {
+			return saved, fmt.Errorf("save %q: %w", part.FileName(), err)
+		}
+		saved = append(saved, file)
+	}
+	return saved, nil
+}
+
+func saveFile(part *Part, dir string, options *saveOptions) (SavedFile, error) {
+	name, err := sanitizeFileName(part.FileName())
+	if err != nil {
+		return SavedFile{}, err
+	}
+
+	f, path, err := createFile(dir, name, options.collision)
+	if err != nil {
+		return SavedFile{}, err
+	}
+
+	content := part.Content
+	if content == nil {
+		content = strings.NewReader("")
+	}
+	if options.maxFileSize > 0 {
+		content = io.LimitReader(content, options.maxFileSize+1)
+	}
+	size, err := io.Copy(f, content)
+	if err == nil && options.maxFileSize > 0 && size > options.maxFileSize {
+		err = ErrFileTooLarge
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return SavedFile{}, err
+	}
+
+	return SavedFile{
+		FormName: part.FormName(),
+		FileName: name,
+		Path:     path,
+		Size:     size,
+		Header:   part.Header,
+	}, nil
+}
+
+// sanitizeFileName returns the base name of the file name without directories of any OS.
+func sanitizeFileName(name string) (string, error) {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	if name == "" || name == "." || name == ".." || strings.ContainsFunc(name, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return name, nil
+}
+
+func createFile(dir, name string, policy CollisionPolicy) (*os.File, string, error) {
+	path := filepath.Join(dir, name)
+	switch policy {
+	case CollisionOverwrite:
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+		return f, path, err
+	case CollisionRename:
+		ext := filepath.Ext(name)
+		for i := 1; i <= maxRenameAttempts; i++ {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+			if !errors.Is(err, fs.ErrExist) {
+				return f, path, err
+			}
+			path = filepath.Join(dir, strings.TrimSuffix(name, ext)+"-"+strconv.Itoa(i)+ext)
+		}
+		return nil, "", fmt.Errorf("no free name after %d attempts: %w", maxRenameAttempts, fs.ErrExist)
+	default:
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+		return f, path, err
+	}
+}
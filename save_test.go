@@ -0,0 +1,111 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestSaveFiles(t *testing.T) {
+	filePart := func(name, content string) *itermultipart.Part {
+		return itermultipart.NewPart().SetFormName("file").SetFileName(name).SetContentString(content)
+	}
+	readFile := func(t *testing.T, path string) string {
+		t.Helper()
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile: unexpected error %s", err)
+		}
+		return string(content)
+	}
+
+	t.Run("save", func(t *testing.T) {
+		dir := t.TempDir()
+		saved, err := itermultipart.SaveFiles(itermultipart.PartSeq(
+			filePart("a.txt", "a"),
+			itermultipart.NewFieldPart("field", "not a file"),
+			filePart("../../escape.txt", "b"),
+			filePart(`..\windows\path.txt`, "c"),
+		), dir)
+		if err != nil {
+			t.Fatalf("SaveFiles: unexpected error %s", err)
+		}
+
+		want := map[string]string{"a.txt": "a", "escape.txt": "b", "path.txt": "c"}
+		if len(saved) != len(want) {
+			t.Fatalf("got %d saved files, want %d", len(saved), len(want))
+		}
+		for _, file := range saved {
+			if file.Path != filepath.Join(dir, file.FileName) || file.FormName != "file" {
+				t.Errorf("unexpected saved file %+v", file)
+			}
+			if got := readFile(t, file.Path); got != want[file.FileName] || file.Size != int64(len(got)) {
+				t.Errorf("%s: got content %q, size %d", file.FileName, got, file.Size)
+			}
+		}
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		_, err := itermultipart.SaveFiles(itermultipart.PartSeq(filePart("..", "a")), t.TempDir())
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		dir := t.TempDir()
+		saved, err := itermultipart.SaveFiles(itermultipart.PartSeq(
+			filePart("small.txt", "1234"),
+			filePart("large.txt", "12345"),
+		), dir, itermultipart.WithMaxFileSize(4))
+		if !errors.Is(err, itermultipart.ErrFileTooLarge) {
+			t.Errorf("got error %v, want %v", err, itermultipart.ErrFileTooLarge)
+		}
+		if len(saved) != 1 || saved[0].FileName != "small.txt" {
+			t.Errorf("unexpected saved files %+v", saved)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "large.txt")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("partial file is not removed: %v", err)
+		}
+	})
+
+	collisions := []struct {
+		name     string
+		policy   itermultipart.CollisionPolicy
+		wantErr  error
+		contents map[string]string
+	}{
+		{"fail", itermultipart.CollisionFail, fs.ErrExist, map[string]string{"a.txt": "old"}},
+		{"overwrite", itermultipart.CollisionOverwrite, nil, map[string]string{"a.txt": "new2"}},
+		{"rename", itermultipart.CollisionRename, nil, map[string]string{"a.txt": "old", "a-1.txt": "new1", "a-2.txt": "new2"}},
+	}
+	for _, c := range collisions {
+		t.Run("collision "+c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("old"), 0o666); err != nil {
+				t.Fatalf("WriteFile: unexpected error %s", err)
+			}
+			_, err := itermultipart.SaveFiles(itermultipart.PartSeq(
+				filePart("a.txt", "new1"),
+				filePart("a.txt", "new2"),
+			), dir, itermultipart.WithCollisionPolicy(c.policy))
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("got error %v, want %v", err, c.wantErr)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(c.contents) {
+				t.Errorf("got %d files, want %d", len(entries), len(c.contents))
+			}
+			for name, want := range c.contents {
+				if got := readFile(t, filepath.Join(dir, name)); got != want {
+					t.Errorf("%s: got %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
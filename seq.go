@@ -0,0 +1,82 @@
+package itermultipart
+
+import "iter"
+
+// LimitParts returns the sequence stopping after n parts, i.e. to inspect only the first parts of a huge upload.
+// The underlying sequence is stopped as well, so its producer releases resources, see the package documentation.
+// Errors are passed through and don't count as parts.
+func LimitParts(parts iter.Seq2[*Part, error], n int) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		if n <= 0 {
+			return
+		}
+		count := 0
+		for part, err := range parts {
+			if !yield(part, err) {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			if count++; count >= n {
+				return
+			}
+		}
+	}
+}
+
+// FilterParts returns the sequence of parts the keep function returns true for.
+// Errors are passed through.
+func FilterParts(parts iter.Seq2[*Part, error], keep func(part *Part) bool) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err == nil && !keep(part) {
+				continue
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// MapParts returns the sequence of parts replaced by f: it may modify the part and return it, return another part,
+// or return nil to drop the part. An error returned by f is yielded and stops the sequence.
+// Errors of the underlying sequence are passed through.
+func MapParts(parts iter.Seq2[*Part, error], f func(part *Part) (*Part, error)) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			part, err = f(part)
+			switch {
+			case err != nil:
+				yield(nil, err)
+				return
+			case part == nil:
+				continue
+			}
+			if !yield(part, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ConcatParts returns the sequence of parts of all sequences one after another.
+func ConcatParts(seqs ...iter.Seq2[*Part, error]) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for _, parts := range seqs {
+			for part, err := range parts {
+				if !yield(part, err) {
+					return
+				}
+			}
+		}
+	}
+}
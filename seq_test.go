@@ -0,0 +1,274 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"mime/multipart"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// stoppable yields n parts and records whether the producer was stopped.
+func stoppable(n int, stopped *bool, yielded *int) iter.Seq2[*itermultipart.Part, error] {
+	return func(yield func(*itermultipart.Part, error) bool) {
+		for i := range n {
+			*yielded = i + 1
+			if !yield(itermultipart.NewPart().SetFormName("f").SetFileName("f.txt").SetContentString("content"), nil) {
+				*stopped = true
+				return
+			}
+		}
+	}
+}
+
+func TestLimitParts(t *testing.T) {
+	var stopped bool
+	var yielded int
+	var got int
+	for _, err := range itermultipart.LimitParts(stoppable(10, &stopped, &yielded), 2) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		got++
+	}
+	if got != 2 || yielded != 2 {
+		t.Errorf("got %d parts, producer yielded %d, want 2", got, yielded)
+	}
+	if !stopped {
+		t.Error("producer is not stopped")
+	}
+
+	errPart := errors.New("bad part")
+	parts := func(yield func(*itermultipart.Part, error) bool) {
+		_ = yield(nil, errPart) && yield(itermultipart.NewPart(), nil) && yield(itermultipart.NewPart(), nil)
+	}
+	var errs, count int
+	for part, err := range itermultipart.LimitParts(parts, 1) {
+		if err != nil {
+			errs++
+			continue
+		}
+		if part != nil {
+			count++
+		}
+	}
+	if errs != 1 || count != 1 {
+		t.Errorf("got %d errors and %d parts, want 1 and 1", errs, count)
+	}
+
+	for range itermultipart.LimitParts(stoppable(10, &stopped, &yielded), 0) {
+		t.Error("no parts expected")
+	}
+}
+
+func TestFilterMapConcatParts(t *testing.T) {
+	errPart := errors.New("bad part")
+	first := itermultipart.PartSeq(itermultipart.NewFieldPart("a", "1"), itermultipart.NewFieldPart("b", "2"))
+	second := func(yield func(*itermultipart.Part, error) bool) {
+		_ = yield(nil, errPart) && yield(itermultipart.NewFieldPart("c", "3"), nil)
+	}
+
+	parts := itermultipart.ConcatParts(first, second)
+	parts = itermultipart.FilterParts(parts, func(part *itermultipart.Part) bool { return part.FormName() != "b" })
+	parts = itermultipart.MapParts(parts, func(part *itermultipart.Part) (*itermultipart.Part, error) {
+		return part.SetHeaderValue("X-Mapped", "yes"), nil
+	})
+
+	var names []string
+	var errs int
+	for part, err := range parts {
+		if err != nil {
+			if !errors.Is(err, errPart) {
+				t.Errorf("unexpected error %s", err)
+			}
+			errs++
+			continue
+		}
+		if part.Header.Get("X-Mapped") != "yes" {
+			t.Errorf("part %s is not mapped", part.FormName())
+		}
+		names = append(names, part.FormName())
+	}
+	if want := []string{"a", "c"}; !slices.Equal(names, want) || errs != 1 {
+		t.Errorf("got parts %v and %d errors, want %v and 1", names, errs, want)
+	}
+
+	errMap := errors.New("map failed")
+	names = names[:0]
+	mapped := itermultipart.MapParts(smallFields(3), func(part *itermultipart.Part) (*itermultipart.Part, error) {
+		if len(names) == 1 {
+			return nil, errMap
+		}
+		return part, nil
+	})
+	var gotErr error
+	for part, err := range mapped {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		names = append(names, part.FormName())
+	}
+	if len(names) != 1 || !errors.Is(gotErr, errMap) {
+		t.Errorf("got %d parts and error %v, want 1 and %s", len(names), gotErr, errMap)
+	}
+
+	var count int
+	for range itermultipart.MapParts(smallFields(3), func(*itermultipart.Part) (*itermultipart.Part, error) { return nil, nil }) {
+		count++
+	}
+	if count != 0 {
+		t.Errorf("got %d parts, want all dropped", count)
+	}
+}
+
+// TestSequencesStop checks that sequences of the package stop when the consumer breaks the loop.
+// The runtime panics if a producer calls yield after it returned false.
+func TestSequencesStop(t *testing.T) {
+	newMessage := func() (*bytes.Buffer, string) {
+		src := itermultipart.NewSource(smallFields(3))
+		var buf bytes.Buffer
+		if _, err := src.WriteTo(&buf); err != nil {
+			t.Fatalf("WriteTo: unexpected error %s", err)
+		}
+		return &buf, src.Boundary()
+	}
+	wrappers := map[string]func(iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error]{
+		"DecodeTransferEncoding": itermultipart.DecodeTransferEncoding,
+		"DecodeCharset": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.DecodeCharset(parts, nil)
+		},
+		"DeclareCharset": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.DeclareCharset(parts, "utf-8", nil)
+		},
+		"ExtractMetadata": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.ExtractMetadata(parts, itermultipart.PeekExtractor(1, func(*itermultipart.Part, []byte) {}))
+		},
+		"ConditionalSeq": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.ConditionalSeq(func(context.Context) bool { return true }, parts)
+		},
+		"JoinRangedParts": itermultipart.JoinRangedParts,
+		"ProxyParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.ProxyParts(parts, nil)
+		},
+		"FilterParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.FilterParts(parts, func(*itermultipart.Part) bool { return true })
+		},
+		"MapParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.MapParts(parts, func(part *itermultipart.Part) (*itermultipart.Part, error) { return part, nil })
+		},
+		"ConcatParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.ConcatParts(parts, smallFields(1))
+		},
+		"LimitParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.LimitParts(parts, 5)
+		},
+		"StrictFormParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.StrictFormParts(parts, itermultipart.DuplicatesRejected)
+		},
+		"DecodeEncodedWords": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.DecodeEncodedWords(parts, nil)
+		},
+		"WalkParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.WalkParts(parts, 1)
+		},
+		"DigestParts":           itermultipart.DigestParts,
+		"DecodeContentEncoding": itermultipart.DecodeContentEncoding,
+		"LimitedParts": func(parts iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.LimitedParts(parts, itermultipart.Limits{MaxParts: 5, MaxPartSize: 100})
+		},
+	}
+
+	producers := map[string]func() iter.Seq2[*itermultipart.Part, error]{
+		"PartSeq": func() iter.Seq2[*itermultipart.Part, error] { return smallFields(3) },
+		"PartSeqFromValues": func() iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.PartSeqFromValues(url.Values{"a": {"1", "2", "3"}})
+		},
+		"ByteRangeParts": func() iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.ByteRangeParts(strings.NewReader("abc"), 3, "", []itermultipart.ByteRange{{0, 1}, {1, 1}, {2, 1}})
+		},
+		"PartsFromChannel": func() iter.Seq2[*itermultipart.Part, error] {
+			ch := make(chan *itermultipart.Part, 3)
+			for range 3 {
+				ch <- itermultipart.NewPart()
+			}
+			close(ch)
+			return itermultipart.PartsFromChannel(ch, nil)
+		},
+		"PartsFromProducer": func() iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.PartsFromProducer(context.Background(), func(ctx context.Context, send func(*itermultipart.Part) error) error {
+				for {
+					if err := send(itermultipart.NewPart()); err != nil {
+						return err
+					}
+				}
+			})
+		},
+		"PartsFromWriterFunc": func() iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.PartsFromWriterFunc(func(w *multipart.Writer) error {
+				for {
+					if err := w.WriteField("a", "1"); err != nil {
+						return err
+					}
+				}
+			})
+		},
+		"PartsFromFS": func() iter.Seq2[*itermultipart.Part, error] {
+			return itermultipart.PartsFromFS(fstest.MapFS{"a": {}, "b": {}, "c": {}}, ".")
+		},
+		"PartsFromReader": func() iter.Seq2[*itermultipart.Part, error] {
+			buf, boundary := newMessage()
+			return itermultipart.PartsFromReader(multipart.NewReader(buf, boundary), false)
+		},
+		"Parser": func() iter.Seq2[*itermultipart.Part, error] {
+			buf, boundary := newMessage()
+			return itermultipart.NewParser(buf, boundary).Parts()
+		},
+		"DecodeMessage": func() iter.Seq2[*itermultipart.Part, error] {
+			buf, boundary := newMessage()
+			m, err := itermultipart.DecodeMessage(buf, "multipart/form-data; boundary="+boundary)
+			if err != nil {
+				t.Fatalf("DecodeMessage: unexpected error %s", err)
+			}
+			return m.Parts
+		},
+		"Marshal": func() iter.Seq2[*itermultipart.Part, error] {
+			parts, err := itermultipart.Marshal(struct {
+				A []string `multipart:"a"`
+			}{A: []string{"1", "2", "3"}})
+			if err != nil {
+				t.Fatalf("Marshal: unexpected error %s", err)
+			}
+			return parts
+		},
+	}
+	for name, producer := range producers {
+		wrappers[name] = func(iter.Seq2[*itermultipart.Part, error]) iter.Seq2[*itermultipart.Part, error] {
+			return producer()
+		}
+	}
+
+	for name, wrap := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			for range wrap(smallFields(3)) {
+				break
+			}
+			var stopped bool
+			var yielded int
+			for range wrap(stoppable(3, &stopped, &yielded)) {
+				break
+			}
+			// DeclareCharset yields its own part first, so the underlying sequence may not be started
+			if _, ok := producers[name]; !ok && (yielded > 0 && !stopped || yielded > 1) {
+				t.Errorf("underlying sequence is not stopped: stopped %t after %d parts", stopped, yielded)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package itermultipart
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// skipBufferSize is the size of the buffer used to discard bytes that can't be skipped by seeking.
+const skipBufferSize = 32 << 10
+
+// SkipBytes fast-forwards the message by n bytes as if they were read, i.e. to resume an interrupted upload
+// from the offset acknowledged by the server using Content-Range. Headings are generated and dropped,
+// seekable contents without transfer or content encoding are skipped with Seek, other contents are read and discarded.
+// Skipped bytes are accounted in [Source.Stats] and reported to the progress callback,
+// but they are not mirrored by [Source.TeeTo] and not hashed, so body digests, [Source.ETag]
+// and [Source.StreamChecksum] are not available for the message after skipping.
+// It returns the number of skipped bytes, [io.EOF] is returned if the message is shorter than n.
+func (s *Source) SkipBytes(n int64) (int64, error) {
+	if err := s.applySeek(); err != nil {
+		return 0, err
+	}
+
+	var (
+		skipped int64
+		buf     []byte
+	)
+	for skipped < n {
+		if s.buffered.Len() == 0 {
+			if k, ok := s.seekContent(n - skipped); ok {
+				skipped += k
+				s.skipped = true
+				continue
+			}
+		}
+
+		if buf == nil {
+			buf = make([]byte, min(n-skipped, skipBufferSize))
+		}
+		size := min(n-skipped, int64(len(buf)))
+		switch {
+		case s.buffered.Len() > 0:
+			size = min(size, int64(s.buffered.Len())) // stop before the content to seek it
+		case s.lastPart == nil:
+			size = 1 // pull the next part and generate its heading
+		}
+		m, err := s.read(buf[:size])
+		skipped += int64(m)
+		s.skipped = s.skipped || m > 0
+		if err != nil {
+			s.finish(err)
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+// seekContent skips up to n bytes of the current part content by seeking if it's possible.
+// It reports false if the content can't be skipped by seeking or there is nothing to skip in it.
+func (s *Source) seekContent(n int64) (int64, bool) {
+	part := s.lastPart
+	if part == nil || s.lastContent == nil || s.partHashers != nil || part.compresses() ||
+		part.transferEncoding == TransferEncodingBase64 || part.transferEncoding == TransferEncodingQuotedPrintable {
+		return 0, false // the content is wrapped
+	}
+	seeker, ok := part.Content.(io.Seeker)
+	if !ok {
+		return 0, false
+	}
+	remaining, ok := contentSize(part.Content) // opened by a factory too
+	if !ok || remaining == 0 {
+		return 0, false
+	}
+
+	k := min(n, remaining)
+	if _, err := seeker.Seek(k, io.SeekCurrent); err != nil {
+		return 0, false
+	}
+	s.reportProgress(part, int(k), true)
+	return k, true
+}
+
+// Seek implements [io.Seeker], so the [Source] may be used where [io.ReadSeeker] is required,
+// i.e. by [net/http.ServeContent] serving ranges of the message or by SDKs retrying uploads.
+// Seeking forward works like [Source.SkipBytes], seeking backward rewinds the message with [Source.Rewind] first,
+// so rewinding requirements apply. Once Seek is called, seekable contents implementing [io.Closer], i.e. [os.File]s,
+// reached by the [Source] are kept open to seek back and closed by [Source.Close], so call Seek before reading
+// or create the [Source] with [WithContentsKeptOpen]. Set the Content-Type header for [net/http.ServeContent],
+// otherwise it reads the message to sniff the type before seeking. Seeking from the end generates the rest of the message
+// to find out its length unless [Source.ContentLength] knows it, then nothing is generated until the next read.
+// Seeking beyond the end of the message stops at the end.
+func (s *Source) Seek(offset int64, whence int) (int64, error) {
+	s.seeking = true
+	pos := s.stats.HeaderBytes + s.stats.ContentBytes
+	current := pos
+	if s.seekPending {
+		current = s.seekTo
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += current
+	case io.SeekEnd:
+		length, ok := s.ContentLength()
+		if ok {
+			if offset+length < 0 {
+				return current, errors.New("seek: negative position")
+			}
+			// the position is reached on the next read, so seeking to the end to learn the length is free
+			s.seekTo, s.seekPending = min(offset+length, length), true
+			return s.seekTo, nil
+		}
+		// the length is known before generation only, otherwise skip to the end to find it out
+		s.seekPending = false
+		skipped, err := s.SkipBytes(math.MaxInt64)
+		if !errors.Is(err, io.EOF) {
+			return pos + skipped, err
+		}
+		pos += skipped
+		offset += pos
+	default:
+		return current, errors.New("seek: invalid whence")
+	}
+	if offset < 0 {
+		return current, errors.New("seek: negative position")
+	}
+	s.seekPending = false
+
+	if offset < pos {
+		if err := s.Rewind(); err != nil {
+			return pos, err
+		}
+		pos = 0
+	}
+	skipped, err := s.SkipBytes(offset - pos)
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return pos + skipped, err
+}
+
+// applySeek reaches the position set by [Source.Seek] if it's deferred until generation.
+func (s *Source) applySeek() error {
+	if !s.seekPending {
+		return nil
+	}
+	s.seekPending = false
+	_, err := s.Seek(s.seekTo, io.SeekStart)
+	return err
+}
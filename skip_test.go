@@ -0,0 +1,231 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// countingReadSeeker counts bytes read from the underlying reader.
+type countingReadSeeker struct {
+	*strings.Reader
+	read int
+}
+
+func (r *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestSourceSkipBytes(t *testing.T) {
+	large := strings.Repeat("0123456789", 10000)
+	var seekable *countingReadSeeker
+	newSource := func() *itermultipart.Source {
+		seekable = &countingReadSeeker{Reader: strings.NewReader(large)}
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("a", "first"),
+			itermultipart.NewPart().SetFormName("seekable").SetContent(seekable),
+			itermultipart.NewPart().SetFormName("stream").SetContent(iotest.HalfReader(strings.NewReader(large))),
+			itermultipart.NewPart().SetFormName("encoded").SetTransferEncoding(itermultipart.TransferEncodingBase64).SetContentString(large),
+		), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")))
+	}
+
+	var full bytes.Buffer
+	if _, err := newSource().WriteTo(&full); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for _, n := range []int64{0, 1, 10, 60, 1000, 100050, 150000, 250000, int64(full.Len()) - 1, int64(full.Len())} {
+		src := newSource()
+		skipped, err := src.SkipBytes(n)
+		if err != nil || skipped != n {
+			t.Fatalf("skip %d: skipped %d, error %v", n, skipped, err)
+		}
+		if got := src.Stats().TotalBytes; got != n {
+			t.Errorf("skip %d: stats report %d bytes", n, got)
+		}
+		rest, err := io.ReadAll(src)
+		if err != nil {
+			t.Fatalf("skip %d: unexpected error %s", n, err)
+		}
+		if !bytes.Equal(rest, full.Bytes()[n:]) {
+			t.Errorf("skip %d: rest of the message differs", n)
+		}
+	}
+
+	src := newSource()
+	if _, err := src.SkipBytes(150000); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if seekable.read != 0 {
+		t.Errorf("seekable content is read instead of seeking, %d bytes", seekable.read)
+	}
+
+	skipped, err := newSource().SkipBytes(int64(full.Len()) + 1)
+	if !errors.Is(err, io.EOF) || skipped != int64(full.Len()) {
+		t.Errorf("skipping beyond the end: skipped %d, error %v", skipped, err)
+	}
+}
+
+func TestSourceSkipBytesDigests(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "first"),
+		itermultipart.NewPart().SetFormName("b").SetContent(strings.NewReader(strings.Repeat("x", 1000))),
+	), itermultipart.WithBodyDigests(itermultipart.DigestSHA256))
+	if err := src.EnableStreamChecksum(crc32.NewIEEE()); err != nil {
+		t.Fatalf("EnableStreamChecksum: unexpected error %s", err)
+	}
+
+	if _, err := src.SkipBytes(500); err != nil {
+		t.Fatalf("SkipBytes: unexpected error %s", err)
+	}
+	if _, err := io.ReadAll(src); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if etag, ok := src.ETag(); ok {
+		t.Errorf("ETag %s is available after skipping", etag)
+	}
+	if _, ok := src.StreamChecksum(); ok {
+		t.Error("stream checksum is available after skipping")
+	}
+	if digests := src.Stats().BodyDigests; digests != nil {
+		t.Errorf("body digests %v are available after skipping", digests)
+	}
+
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	if _, err := io.ReadAll(src); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, ok := src.ETag(); !ok {
+		t.Error("ETag is not available for the complete message")
+	}
+	if _, ok := src.StreamChecksum(); !ok {
+		t.Error("stream checksum is not available for the complete message")
+	}
+}
+
+func TestSourceSeek(t *testing.T) {
+	large := strings.Repeat("0123456789", 10000)
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "first"),
+		itermultipart.NewPart().SetFormName("b").SetContent(strings.NewReader(large)),
+	))
+	var _ io.ReadSeeker = src
+
+	full, err := io.ReadAll(src)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	seeks := []struct {
+		offset int64
+		whence int
+		want   int64
+	}{
+		{0, io.SeekCurrent, int64(len(full))},
+		{10, io.SeekStart, 10},
+		{50000, io.SeekCurrent, 50030}, // 20 bytes are read after every seek
+		{-100, io.SeekEnd, int64(len(full)) - 100},
+		{100, io.SeekStart, 100},
+		{10, io.SeekEnd, int64(len(full))},
+	}
+	for _, s := range seeks {
+		pos, err := src.Seek(s.offset, s.whence)
+		if err != nil || pos != s.want {
+			t.Fatalf("Seek(%d, %d) = %d, %v; want %d", s.offset, s.whence, pos, err, s.want)
+		}
+		chunk := make([]byte, 20)
+		n, _ := io.ReadFull(src, chunk)
+		if !bytes.Equal(chunk[:n], full[pos:min(pos+20, int64(len(full)))]) {
+			t.Errorf("Seek(%d, %d): read %q, want %q", s.offset, s.whence, chunk[:n], full[pos:min(pos+20, int64(len(full)))])
+		}
+	}
+	if _, err := src.Seek(-1, io.SeekStart); err == nil {
+		t.Error("error expected for negative position")
+	}
+
+	src.Rewind()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=100-199")
+	rec := httptest.NewRecorder()
+	http.ServeContent(rec, req, "", time.Time{}, src)
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), full[100:200]) {
+		t.Errorf("ServeContent: status %d, body %q", rec.Code, rec.Body.Bytes())
+	}
+}
+
+func TestSourceSeekFile(t *testing.T) {
+	large := strings.Repeat("0123456789", 10000)
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(large), 0o600); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+
+	for _, replayable := range []bool{false, true} {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open: %s", err)
+		}
+		var opts []itermultipart.SourceOption
+		if replayable {
+			opts = append(opts, itermultipart.WithReplayableParts())
+		}
+		src := itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("a", "first"),
+			itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContent(f),
+		), opts...)
+		src.SetBoundary("boundary")
+
+		if replayable {
+			length, _ := src.ContentLength()
+			if pos, err := src.Seek(0, io.SeekEnd); err != nil || pos != length {
+				t.Errorf("Seek(0, io.SeekEnd) = %d, %v; want %d", pos, err, length)
+			}
+			if got := src.Stats().TotalBytes; got != 0 {
+				t.Errorf("seeking to the known end generated %d bytes", got)
+			}
+		}
+
+		// files are closed after they are written by default, ServeContent seeks back after seeking to the end
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Range", "bytes=100-199")
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", src.MixedContentType())
+		http.ServeContent(rec, req, "", time.Time{}, src)
+
+		wantFile, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open: %s", err)
+		}
+		want := itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("a", "first"),
+			itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContent(wantFile),
+		))
+		want.SetBoundary("boundary")
+		full, _ := io.ReadAll(want)
+		if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), full[100:200]) {
+			t.Errorf("replayable %t: ServeContent: status %d, body %q", replayable, rec.Code, rec.Body.Bytes())
+		}
+
+		if err := src.Close(); err != nil {
+			t.Errorf("Close: unexpected error %s", err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("replayable %t: file is not closed with the source: %v", replayable, err)
+		}
+	}
+}
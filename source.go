@@ -2,49 +2,289 @@ package itermultipart
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"iter"
+	"log/slog"
 	"maps"
+	"math/big"
+	"math/bits"
 	"mime"
+	"net/textproto"
+	"net/url"
 	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // Source is a generator of multipart message as you read from it.
 type Source struct {
-	randBoundary [30]byte                // used only on bootstraps
-	boundary     string                  // used in the message
-	parts        iter.Seq2[*Part, error] // for WriteTo
+	boundary    string                  // used in the message
+	boundaryErr error                   // error generating the random boundary
+	parts       iter.Seq2[*Part, error] // for WriteTo
 
+	rand                io.Reader // source of random boundaries
+	boundaryEntropy     int       // number of random bytes in the boundary
+	boundaryEncoder     BoundaryEncoder
+	boundaryFunc        func() (string, error) // replaces random boundaries if set
+	recoverPanics       bool
+	keepBoundaryOnReset bool
+	strict              bool  // validate parts before writing
+	asciiHeaders        bool  // require ASCII header values while validating
+	stdlibDisposition   bool  // write Content-Disposition like multipart.Writer
+	checkCollisions     bool  // scan contents for the boundary before writing them
+	orderedHeaders      bool  // write headers in the order they were added instead of sorting them
+	readBufferSize      int   // Read fills up to this number of bytes
+	panicErr            error // recovered panic, returned until rewinding
+	minCopyBuffer       int   // bounds of the buffer WriteTo copies contents with
+	maxCopyBuffer       int
+	avgContentSize      int64 // moving average of contents copied with the buffer
+
+	defaultHeaders      textproto.MIMEHeader
 	pull                func() (*Part, error, bool)
 	stop                func()
 	buffered            *bytes.Buffer // accumulates boundary+headers
 	firstHeadingWritten bool
 	lastPart            *Part
+	lastContent         io.Reader // content of the lastPart as written to the message
 	finalizing          bool
 	closed              bool
+
+	ctx       context.Context // passed to part conditions
+	cancelCtx context.Context // stops generation when done, set by WithContext and WriteToContext
+
+	limiter    RateLimiter
+	progress   func(part *Part, partBytes, totalBytes int64)
+	partBytes  int64 // content bytes of the current part generated so far
+	totalBytes int64 // message bytes generated so far
+
+	partIndex     int         // index of the current part in the sequence
+	offsets       []int64     // initial content offsets of reached parts, see offsetNotSeekable and others
+	openedContent io.Closer   // content opened with a factory or closable content, closed after the part is written
+	keepOpen      bool        // don't close contents implementing io.Closer
+	seeking       bool        // Seek was called, seekable closable contents are kept open until Close
+	seekClosers   []io.Closer // contents kept open for seeking
+	seekTo        int64       // position to reach before generation if seekPending, see Seek
+	seekPending   bool
+	replayable    bool // the part sequence may be iterated ahead of generation, see WithReplayableParts
+
+	checksum         hash.Hash         // hashes the generated message if enabled
+	checksumSum      []byte            // checksum of the completely generated message
+	digestAlgorithms []DigestAlgorithm // computed for every part if set
+	partHashers      []hash.Hash       // hash contents of the current part
+	bodyAlgorithms   []DigestAlgorithm // computed for the whole message if set
+	bodyHashers      []hash.Hash       // hash the current message
+	skipped          bool              // a part of the current message is skipped, so it's not hashed completely
+	logger           *slog.Logger
+	metrics          MetricsHook
+	partActive       bool         // the heading of the current part is generated, the part is not finished yet
+	afterPart        func() error // called by WriteTo after every part is written to the target
+	tee              *teeWriter
+	onFinish         func(err error)
+	finished         bool // the current message is finished, onFinish was called
+	stats            Stats
+	started          time.Time // first byte of the current message is generated
+	finishedAt       time.Time
+}
+
+// ErrClosed is returned when reading from the closed [Source].
+var ErrClosed = errors.New("source is closed")
+
+const (
+	offsetNotSeekable = -1
+	offsetFactory     = -2
+	offsetSkipped     = -3 // excluded by the part condition
+	offsetNested      = -4 // nested Source rewound on its own
+)
+
+// SourceOption configures [Source].
+type SourceOption func(*Source)
+
+// BoundaryEncoder converts random bytes to the boundary.
+// It must produce only characters allowed by [Source.SetBoundary].
+type BoundaryEncoder func(random []byte) string
+
+// HexBoundary encodes random bytes of the boundary as lowercase hex. It's the default [BoundaryEncoder].
+func HexBoundary(random []byte) string {
+	return hex.EncodeToString(random)
+}
+
+// Base36Boundary encodes random bytes of the boundary in base 36 making it shorter than [HexBoundary].
+func Base36Boundary(random []byte) string {
+	return new(big.Int).SetBytes(random).Text(36)
+}
+
+// WithRand sets the source of randomness for boundaries. Default is [crypto/rand.Reader].
+func WithRand(r io.Reader) SourceOption {
+	return func(s *Source) {
+		s.rand = r
+	}
+}
+
+// WithBoundaryEntropy sets the number of random bytes boundaries are generated from. Default is 30.
+// Encoded boundary must fit 70 characters, i.e. up to 35 bytes for [HexBoundary].
+func WithBoundaryEntropy(n int) SourceOption {
+	return func(s *Source) {
+		s.boundaryEntropy = n
+	}
+}
+
+// WithBoundaryEncoder sets the encoder of random boundaries. Default is [HexBoundary].
+func WithBoundaryEncoder(enc BoundaryEncoder) SourceOption {
+	return func(s *Source) {
+		s.boundaryEncoder = enc
+	}
+}
+
+// WithBoundaryFunc makes [Source] take boundaries from f instead of generating random ones,
+// on creation and on [Source.Reset]. Returned boundaries are validated like by [Source.SetBoundary].
+// Together with [SequentialBoundaries] it's the deterministic mode for golden-file tests
+// and reproducible builds of generated messages.
+func WithBoundaryFunc(f func() (string, error)) SourceOption {
+	return func(s *Source) {
+		s.boundaryFunc = f
+	}
+}
+
+// SequentialBoundaries returns a boundary function for [WithBoundaryFunc] producing "prefix1", "prefix2" and so on.
+// It's safe for concurrent use, so it may be shared by sources created by different goroutines,
+// but the order of boundaries is deterministic only if sources are created in a deterministic order.
+func SequentialBoundaries(prefix string) func() (string, error) {
+	var counter atomic.Uint64
+	return func() (string, error) {
+		return prefix + strconv.FormatUint(counter.Add(1), 10), nil
+	}
+}
+
+// WithBoundaryKeptOnReset makes [Source.Reset] keep the current boundary instead of generating a new one.
+// It's useful for pooled sources with a boundary set by [Source.SetBoundary].
+func WithBoundaryKeptOnReset() SourceOption {
+	return func(s *Source) {
+		s.keepBoundaryOnReset = true
+	}
+}
+
+// WithContentsKeptOpen disables closing of part contents implementing [io.Closer].
+// By default such contents, i.e. [os.File]s, are closed after the part is written or when the [Source] is closed,
+// so they can't be rewound by [Source.Rewind]. Contents set with [Part.SetContentFactory] and nested [Source]s
+// are not affected. Keep contents open to rewind them or to close them on your own.
+func WithContentsKeptOpen() SourceOption {
+	return func(s *Source) {
+		s.keepOpen = true
+	}
+}
+
+// WithReplayableParts declares that the part sequence may be iterated multiple times and yields the same parts
+// every time, like sequences returned by [PartSeq], [PartSeqFromValues] and [SpooledParts.Parts] do.
+// Only then [Source.ContentLength] iterates it to compute the length and [NewRequest] sets GetBody.
+// Without it the sequence is iterated once while generating, so one-shot sequences like [PartsFromReader],
+// [Parser.Parts] or [PartsFromChannel] are not drained before the message is sent.
+func WithReplayableParts() SourceOption {
+	return func(s *Source) {
+		s.replayable = true
+	}
+}
+
+// WithStdlibDisposition makes [Source] write Content-Disposition headers exactly like [multipart.Writer] does:
+// all parameters are quoted with quotes and backslashes escaped, non-ASCII names are written as is
+// instead of RFC 2231 encoding. Some servers understand only this form. Unlike [multipart.Writer],
+// CR and LF are percent-encoded like browsers do, so names can't inject headers.
+func WithStdlibDisposition() SourceOption {
+	return func(s *Source) {
+		s.stdlibDisposition = true
+	}
+}
+
+// WithOrderedHeaders makes [Source] write part headers in the order they were added by [Part] setters
+// or read by [Parser] instead of sorting them, i.e. Content-Disposition before Content-Type.
+// The order may be overridden with [Part.SetHeaderOrder]. Keys set directly in the [Part.Header] map
+// and default headers are written after the ordered ones in sorted order.
+func WithOrderedHeaders() SourceOption {
+	return func(s *Source) {
+		s.orderedHeaders = true
+	}
+}
+
+const (
+	defaultMinCopyBuffer = 4 << 10
+	defaultMaxCopyBuffer = 32 << 10 // default value from io.CopyBuffer
+)
+
+// WithCopyBuffer sets bounds of the buffer [Source.WriteTo] copies contents not implementing [io.WriterTo]
+// with, when the target doesn't implement [io.ReaderFrom]. The buffer adapts to the average size of recent contents,
+// so streams of small contents don't waste memory and large files are copied with fewer writes.
+// Defaults are 4 KiB and 32 KiB.
+func WithCopyBuffer(minSize, maxSize int) SourceOption {
+	return func(s *Source) {
+		s.minCopyBuffer, s.maxCopyBuffer = max(minSize, 1), max(minSize, maxSize, 1)
+	}
+}
+
+// WithReadBuffer makes [Source.Read] fill the buffer up to the size instead of returning
+// headings and content fragments separately, so the message is consumed in fewer larger chunks,
+// i.e. when it's an HTTP request body. Note that Read waits for slow contents to fill the buffer.
+// [Source.WriteTo] coalesces small writes on its own.
+func WithReadBuffer(size int) SourceOption {
+	return func(s *Source) {
+		s.readBufferSize = size
+	}
 }
 
 // NewSource returns a new [Source] that generates a multipart message from provided part sequence.
 // Part sequence must be finite.
 // [Source] holds reference for [Part] only until it's fully read.
-func NewSource(parts iter.Seq2[*Part, error]) *Source {
+// If the random boundary can't be generated, reading fails with the error unless the boundary is set with [Source.SetBoundary].
+func NewSource(parts iter.Seq2[*Part, error], opts ...SourceOption) *Source {
 	src := &Source{
-		parts:    parts,
-		buffered: new(bytes.Buffer),
+		parts:           parts,
+		buffered:        new(bytes.Buffer),
+		rand:            rand.Reader,
+		boundaryEntropy: 30,
+		boundaryEncoder: HexBoundary,
+		minCopyBuffer:   defaultMinCopyBuffer,
+		maxCopyBuffer:   defaultMaxCopyBuffer,
+	}
+	for _, opt := range opts {
+		opt(src)
 	}
 	src.populateRandomBoundary()
 	return src
 }
 
 func (s *Source) populateRandomBoundary() {
-	_, err := io.ReadFull(rand.Reader, s.randBoundary[:])
-	if err != nil {
-		panic(err)
+	if s.boundaryFunc != nil {
+		boundary, err := s.boundaryFunc()
+		if err == nil {
+			err = validateBoundary(boundary)
+		}
+		if err != nil {
+			s.boundary, s.boundaryErr = "", fmt.Errorf("generate boundary: %w", err)
+			return
+		}
+		s.boundary, s.boundaryErr = boundary, nil
+		return
+	}
+
+	random := make([]byte, s.boundaryEntropy)
+	if _, err := io.ReadFull(s.rand, random); err != nil {
+		s.boundary, s.boundaryErr = "", fmt.Errorf("generate boundary: %w", err)
+		return
+	}
+
+	boundary := s.boundaryEncoder(random)
+	if err := validateBoundary(boundary); err != nil {
+		s.boundary, s.boundaryErr = "", fmt.Errorf("generate boundary: %w", err)
+		return
 	}
-	s.boundary = fmt.Sprintf("%x", s.randBoundary)
+	s.boundary, s.boundaryErr = boundary, nil
 }
 
 // PartSeq returns a sequence of parts from the provided list.
@@ -58,10 +298,88 @@ func PartSeq(parts ...*Part) iter.Seq2[*Part, error] {
 	}
 }
 
+// PartSeqFromValues returns a sequence of form field parts from the values, i.e. the urlencoded form.
+// Every value of the multi-value field becomes a separate part. Fields are sorted by name.
+// Parts are created on every iteration, so the sequence can be iterated multiple times.
+func PartSeqFromValues(values url.Values) iter.Seq2[*Part, error] {
+	return PartSeqFromMap(map[string][]string(values))
+}
+
+// PartSeqFromMap returns a sequence of form field parts from the map of field names to values.
+// It works like [PartSeqFromValues].
+func PartSeqFromMap[V string | []string](fields map[string]V) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			var values []string
+			switch v := any(fields[name]).(type) {
+			case string:
+				values = []string{v}
+			case []string:
+				values = v
+			}
+			for _, value := range values {
+				if !yield(NewFieldPart(name, value), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
 // Read implements [io.Reader].
 func (s *Source) Read(p []byte) (n int, err error) {
+	defer func() {
+		if err != nil {
+			s.finish(err)
+		}
+	}()
+	if s.recoverPanics {
+		defer s.recoverPanic(&err)
+	}
+
+	if err := s.applySeek(); err != nil {
+		return 0, err
+	}
+	if s.limiter != nil {
+		p = p[:min(len(p), s.limiter.Burst())]
+	}
+	n, err = s.read(p)
+	for target := min(len(p), s.readBufferSize); err == nil && n < target; {
+		var m int
+		m, err = s.read(p[n:])
+		n += m
+	}
+	if s.limiter != nil && n > 0 {
+		if waitErr := s.limiter.WaitN(s.context(), n); waitErr != nil {
+			return 0, waitErr
+		}
+	}
+	if s.tee != nil {
+		if _, teeErr := s.tee.Write(p[:n]); teeErr != nil {
+			return 0, teeErr
+		}
+	}
+	if w := s.bodyWriter(); w != nil {
+		w.Write(p[:n])
+		if errors.Is(err, io.EOF) && s.finalizing {
+			s.finishBodyDigests()
+		}
+	}
+	return n, err
+}
+
+func (s *Source) read(p []byte) (n int, err error) {
 	if s.closed {
-		return 0, fmt.Errorf("source is closed")
+		return 0, ErrClosed
+	}
+	if s.boundaryErr != nil {
+		return 0, s.boundaryErr
+	}
+	if s.panicErr != nil {
+		return 0, s.panicErr
+	}
+	if err := s.cancelErr(); err != nil {
+		return 0, err
 	}
 
 	if s.pull == nil {
@@ -70,22 +388,26 @@ func (s *Source) Read(p []byte) (n int, err error) {
 
 	// pull the next part if necessary
 	if s.lastPart == nil && !s.finalizing {
-		part, err, ok := s.pull()
+		part, err, ok := s.pullPart()
 		if !ok {
 			// finalize
 			s.finalizing = true
-			return s.populateEnding().Read(p)
+			n, err := s.populateEnding().Read(p)
+			s.reportProgress(nil, n, false)
+			return n, err
 		}
 		if err != nil {
 			return 0, err
 		}
 		s.lastPart = part
+		s.lastContent = s.partContent(part)
 		s.populatePartHeading(part)
 	}
 
 	if s.buffered.Len() > 0 {
 		// we have some buffered data, read it first
 		bufRead, bufReadErr := s.buffered.Read(p)
+		s.reportProgress(s.lastPart, bufRead, false) // lastPart is nil for the closing delimiter
 		switch {
 		case errors.Is(bufReadErr, nil):
 			n += bufRead
@@ -106,92 +428,477 @@ func (s *Source) Read(p []byte) (n int, err error) {
 	}
 
 	// read the content of the last part
-	readSize, readErr := s.lastPart.Content.Read(p)
+	if s.lastContent == nil {
+		s.lastPart = nil // prepare for the next part
+		return n, s.finishPart()
+	}
+	readSize, readErr := s.lastContent.Read(p)
 	n += readSize
+	s.reportProgress(s.lastPart, readSize, true)
 	if errors.Is(readErr, io.EOF) {
-		s.lastPart = nil // prepare for the next part
-		return n, nil
+		s.lastPart, s.lastContent = nil, nil // prepare for the next part
+		return n, s.finishPart()
 	}
 
 	return n, readErr
 }
 
+// pullPart pulls the next part to be included into the message and prepares it for reading.
+func (s *Source) pullPart() (*Part, error, bool) {
+	for {
+		part, err, ok := s.pull()
+		if !ok || err != nil {
+			return part, err, ok
+		}
+
+		include, err := s.startPart(part)
+		if err == nil && include {
+			err = s.checkCollision(part)
+		}
+		if err != nil {
+			return nil, err, true
+		}
+		if include {
+			return part, nil, true
+		}
+	}
+}
+
+// startPart checks the part condition and prepares the part content for reading:
+// opens it using a factory or rewinds it to the offset observed by the first reading.
+// It reports whether the part must be included into the message.
+func (s *Source) startPart(part *Part) (bool, error) {
+	i := s.partIndex
+	s.partIndex++
+	s.partBytes = 0
+
+	if !part.included(s.context()) {
+		s.setOffset(i, offsetSkipped)
+		return false, nil
+	}
+
+	if s.strict {
+		if err := s.validatePart(i, part); err != nil {
+			return false, err
+		}
+	}
+
+	if part.contentFactory != nil {
+		content, err := part.contentFactory()
+		if err != nil {
+			return false, err
+		}
+		part.Content = content
+		s.openedContent = content
+		s.setOffset(i, offsetFactory)
+		return true, nil
+	}
+
+	if nested, ok := part.Content.(*Source); ok {
+		if i < len(s.offsets) && s.offsets[i] == offsetNested {
+			if err := nested.Rewind(); err != nil {
+				return false, err
+			}
+		}
+		s.setOffset(i, offsetNested)
+		return true, nil
+	}
+
+	if closer, ok := part.Content.(io.Closer); ok && !s.keepOpen {
+		if _, seekable := part.Content.(io.Seeker); !seekable || !s.seeking {
+			// closed content can't be rewound
+			s.openedContent = closer
+			s.setOffset(i, offsetNotSeekable)
+			return true, nil
+		}
+		if i >= len(s.offsets) || s.offsets[i] == offsetSkipped {
+			s.seekClosers = append(s.seekClosers, closer) // to seek back, closed with the Source
+		}
+	}
+
+	seeker, seekable := part.Content.(io.Seeker)
+	if i < len(s.offsets) && s.offsets[i] != offsetSkipped {
+		// part was already reached before rewinding
+		switch {
+		case s.offsets[i] >= 0 && seekable:
+			_, err := seeker.Seek(s.offsets[i], io.SeekStart)
+			return true, err
+		case part.Content == nil:
+			return true, nil
+		default:
+			return false, fmt.Errorf("content of part %d can't be rewound", i)
+		}
+	}
+
+	offset := int64(offsetNotSeekable)
+	switch {
+	case part.Content == nil:
+		offset = 0
+	case seekable:
+		var err error
+		offset, err = seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return false, err
+		}
+	}
+	s.setOffset(i, offset)
+	return true, nil
+}
+
+func (s *Source) validatePart(i int, part *Part) error {
+	if !s.firstHeadingWritten {
+		if err := validateHeader(s.defaultHeaders, s.asciiHeaders); err != nil {
+			return fmt.Errorf("default part headers: %w", err)
+		}
+	}
+	err := part.validate(s.asciiHeaders)
+	if headerErr, ok := err.(*HeaderError); ok {
+		headerErr.Part = i
+		return headerErr
+	}
+	if err != nil {
+		return fmt.Errorf("part %d: %w", i, err)
+	}
+	return nil
+}
+
+// setOffset records the initial content offset of the i-th part unless it was already reached.
+func (s *Source) setOffset(i int, offset int64) {
+	switch {
+	case i == len(s.offsets):
+		s.offsets = append(s.offsets, offset)
+	case i < len(s.offsets) && s.offsets[i] == offsetSkipped:
+		s.offsets[i] = offset
+	}
+}
+
+// WithContext makes the [Source] stop generating the message between chunks once the context is done,
+// reads return the context error then. The context is also passed to part conditions, see [ConditionalPart].
+// Note that cancellable contents are copied by chunks, so [Source.WriteTo] can't pass them to the target as is.
+func WithContext(ctx context.Context) SourceOption {
+	return func(s *Source) {
+		s.ctx, s.cancelCtx = ctx, ctx
+	}
+}
+
+// WriteToContext is like [Source.WriteTo] but stops between chunks once the context is done returning the context error.
+// The context is also passed to part conditions instead of the one set by [WithContext] or [NewRequest].
+func (s *Source) WriteToContext(ctx context.Context, target io.Writer) (int64, error) {
+	prevCtx, prevCancelCtx := s.ctx, s.cancelCtx
+	s.ctx, s.cancelCtx = ctx, ctx
+	defer func() {
+		s.ctx, s.cancelCtx = prevCtx, prevCancelCtx
+	}()
+	return s.WriteTo(target)
+}
+
+// AppendTo appends the whole message to b and returns the extended slice, like [Source.WriteTo] into memory.
+// If [Source.ContentLength] can compute the size, b is grown once to fit the message exactly.
+// On error b is returned unchanged.
+func (s *Source) AppendTo(b []byte) ([]byte, error) {
+	if length, ok := s.ContentLength(); ok {
+		b = slices.Grow(b, int(length))
+	}
+	w := &appendWriter{b: b}
+	if _, err := s.WriteTo(w); err != nil {
+		return b, err
+	}
+	return w.b, nil
+}
+
+// Bytes returns the whole message, i.e. for small messages in tests or webhook payloads.
+// It's a shortcut for [Source.AppendTo] with a nil slice. [Source.String] describes the state instead of returning the message.
+func (s *Source) Bytes() ([]byte, error) {
+	return s.AppendTo(nil)
+}
+
+// appendWriter appends to the slice. Unlike [bytes.Buffer] it doesn't implement [io.ReaderFrom],
+// which would grow the slice beyond the exact size.
+type appendWriter struct {
+	b []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+func (s *Source) cancelErr() error {
+	if s.cancelCtx == nil {
+		return nil
+	}
+	return s.cancelCtx.Err()
+}
+
+// contextReader stops reading once the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+func (s *Source) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// finishPart closes the part content if it was opened by the [Source] or implements [io.Closer].
+func (s *Source) finishPart() error {
+	s.finishDigests()
+	s.partFinished()
+	if s.openedContent == nil {
+		return nil
+	}
+	err := s.openedContent.Close()
+	s.openedContent = nil
+	return err
+}
+
 // WriteTo implements the [io.WriterTo] interface allowing some source-target optimizations to be used.
-func (s *Source) WriteTo(target io.Writer) (int64, error) {
+func (s *Source) WriteTo(target io.Writer) (n int64, err error) {
+	defer func() { s.finish(err) }()
 	if s.closed {
-		return 0, fmt.Errorf("source is closed")
+		return 0, ErrClosed
+	}
+	if s.boundaryErr != nil {
+		return 0, s.boundaryErr
+	}
+	if s.panicErr != nil {
+		return 0, s.panicErr
+	}
+	if s.recoverPanics {
+		defer s.recoverPanic(&err)
+	}
+	if err := s.applySeek(); err != nil {
+		return 0, err
+	}
+
+	if s.pull != nil || s.finalizing {
+		// reading has already started, continue from the current state
+		return io.Copy(target, struct{ io.Reader }{s})
+	}
+
+	if s.tee != nil {
+		target = io.MultiWriter(s.tee, target)
+	}
+	if w := s.bodyWriter(); w != nil {
+		target = io.MultiWriter(target, w)
+	}
+	if s.limiter != nil {
+		target = &rateLimitedWriter{ctx: s.context(), limiter: s.limiter, target: target}
+	}
+
+	// headings and small contents are accumulated in the buffer to be written at once
+	s.buffered.Reset()
+	flush := func() error {
+		written, err := s.buffered.WriteTo(target)
+		n += written
+		return err
 	}
 
-	var n int64
 	for part, err := range s.parts {
 		if err != nil {
 			return n, err
 		}
+		if err := s.cancelErr(); err != nil {
+			return n, err
+		}
 
-		// write part heading
-		partHeadingSize, err := s.populatePartHeading(part).WriteTo(target)
-		n += partHeadingSize
+		include, err := s.startPart(part)
+		if err == nil && include {
+			err = s.checkCollision(part)
+		}
 		if err != nil {
 			return n, err
 		}
+		if !include {
+			continue
+		}
 
-		contentSize, err := s.writePartContent(part, target)
-		n += contentSize
-		if err != nil {
+		s.stats.PartSizes = append(s.stats.PartSizes, 0)
+		s.partStarted(part)
+		headingStart := s.buffered.Len()
+		s.writePartHeading(s.buffered, part, !s.firstHeadingWritten)
+		s.firstHeadingWritten = true
+		s.reportProgress(part, s.buffered.Len()-headingStart, false)
+
+		content := s.partContent(part)
+		if isSmallContent(content) {
+			// in-memory contents never fail
+			contentSize, _ := content.(io.WriterTo).WriteTo(s.buffered)
+			s.reportProgress(part, int(contentSize), true)
+		} else {
+			if err := flush(); err != nil {
+				s.finishPart()
+				return n, err
+			}
+
+			if s.cancelCtx != nil {
+				content = &contextReader{ctx: s.cancelCtx, r: content}
+			}
+			contentTarget := target
+			if s.progress != nil {
+				contentTarget = &progressWriter{s: s, part: part, target: target}
+			}
+			contentSize, err := s.writePartContent(content, contentTarget)
+			n += contentSize
+			if s.progress == nil {
+				s.accountStats(contentSize, true) // otherwise accounted by progressWriter
+			}
+			if err != nil {
+				s.finishPart()
+				return n, err
+			}
+		}
+
+		if err := s.finishPart(); err != nil {
 			return n, err
 		}
+		if s.buffered.Len() >= coalesceSize || s.afterPart != nil {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+		if s.afterPart != nil {
+			if err := s.afterPart(); err != nil {
+				return n, err
+			}
+		}
 	}
 
 	// it's last part, so we must finalize
-	endSize, err := s.populateEnding().WriteTo(target)
-	n += endSize
-	return n, err
+	s.finalizing = true
+	endingStart := s.buffered.Len()
+	s.writeEnding(s.buffered)
+	s.reportProgress(nil, s.buffered.Len()-endingStart, false)
+	if err := flush(); err != nil {
+		return n, err
+	}
+	s.finishBodyDigests()
+	return n, nil
 }
 
-func (s *Source) writePartContent(part *Part, target io.Writer) (int64, error) {
+// coalesceSize is the maximum size of the buffer with headings and small contents [Source.WriteTo] writes at once.
+const coalesceSize = 4 << 10
+
+// isSmallContent reports whether the content is in memory and small enough to be coalesced with headings.
+func isSmallContent(content io.Reader) bool {
+	switch c := content.(type) {
+	case *bytes.Reader:
+		return c.Len() <= coalesceSize
+	case *strings.Reader:
+		return c.Len() <= coalesceSize
+	case *bytes.Buffer:
+		return c.Len() <= coalesceSize
+	default:
+		return false
+	}
+}
+
+func (s *Source) writePartContent(content io.Reader, target io.Writer) (int64, error) {
+	if content == nil {
+		return 0, nil
+	}
+
 	// if ReaderFrom or WriterTo is implemented, use it. Checking order matches io.Copy.
-	if wt, ok := part.Content.(io.WriterTo); ok {
+	if wt, ok := content.(io.WriterTo); ok {
 		return wt.WriteTo(target)
 	}
 	if rf, ok := target.(io.ReaderFrom); ok {
-		return rf.ReadFrom(part.Content)
+		return rf.ReadFrom(content)
 	}
 
 	// allocate or reuse buffer for copying
-	bufferSize := 32 * 1024 // default value from io.CopyBuffer
-	if l, ok := part.Content.(*io.LimitedReader); ok && int64(bufferSize) > l.N {
+	bufferSize := s.copyBufferSize()
+	if l, ok := content.(*io.LimitedReader); ok && int64(bufferSize) > l.N {
 		if l.N < 1 {
 			bufferSize = 1
 		} else {
 			bufferSize = int(l.N)
 		}
 	}
+	if s.buffered.Cap() > max(4*bufferSize, 2*coalesceSize) {
+		s.buffered = new(bytes.Buffer) // release memory grown for large contents
+	}
 	s.buffered.Reset()
 	s.buffered.Grow(bufferSize)
 
 	// copy content
-	return io.CopyBuffer(target, part.Content, s.buffered.Bytes())
+	n, err := io.CopyBuffer(target, content, s.buffered.AvailableBuffer()[:bufferSize])
+	s.avgContentSize += (n - s.avgContentSize) / 4
+	return n, err
+}
+
+// copyBufferSize returns the power of two closest to the average size of copied contents
+// within bounds set by [WithCopyBuffer].
+func (s *Source) copyBufferSize() int {
+	if s.avgContentSize <= 0 {
+		return s.maxCopyBuffer
+	}
+	size := 1 << bits.Len64(uint64(s.avgContentSize-1))
+	return min(max(size, s.minCopyBuffer), s.maxCopyBuffer)
 }
 
 func (s *Source) populatePartHeading(part *Part) *bytes.Buffer {
+	s.stats.PartSizes = append(s.stats.PartSizes, 0)
+	s.partStarted(part)
 	s.buffered.Reset()
-	if !s.firstHeadingWritten {
-		s.firstHeadingWritten = true
-		s.buffered.WriteString("--")
+	s.writePartHeading(s.buffered, part, !s.firstHeadingWritten)
+	s.firstHeadingWritten = true
+	return s.buffered
+}
+
+func (s *Source) writePartHeading(buf *bytes.Buffer, part *Part, first bool) {
+	if first {
+		buf.WriteString("--")
 	} else {
-		s.buffered.WriteString("\r\n--")
+		buf.WriteString("\r\n--")
 	}
-	s.buffered.WriteString(s.boundary)
-	for _, k := range slices.Sorted(maps.Keys(part.Header)) {
-		for _, v := range part.Header[k] {
-			s.buffered.WriteString("\r\n")
-			s.buffered.WriteString(k)
-			s.buffered.WriteString(": ")
-			s.buffered.WriteString(v)
+	buf.WriteString(s.boundary)
+	keys := slices.Collect(maps.Keys(part.Header))
+	for k := range s.defaultHeaders {
+		if _, ok := part.Header[k]; !ok {
+			keys = append(keys, k)
 		}
 	}
-	s.buffered.WriteString("\r\n\r\n")
-	return s.buffered
+	slices.Sort(keys)
+	if s.orderedHeaders && len(part.headerOrder) > 0 {
+		slices.SortStableFunc(keys, func(a, b string) int {
+			return cmp.Compare(headerRank(part.headerOrder, a), headerRank(part.headerOrder, b))
+		})
+	}
+	for _, k := range keys {
+		values, ok := part.Header[k]
+		if !ok {
+			values = s.defaultHeaders[k]
+		}
+		for _, v := range values {
+			if s.stdlibDisposition && k == contentDispositionHeader {
+				v = formatStdlibDisposition(v)
+			}
+			buf.WriteString("\r\n")
+			buf.WriteString(k)
+			buf.WriteString(": ")
+			buf.WriteString(v)
+		}
+	}
+	buf.WriteString("\r\n\r\n")
+}
+
+// headerRank returns the position of the key in the order, keys not in it go last.
+func headerRank(order []string, key string) int {
+	if i := slices.Index(order, key); i >= 0 {
+		return i
+	}
+	return len(order)
 }
 
 func (s *Source) populatePartEnding() *bytes.Buffer {
@@ -202,12 +909,16 @@ func (s *Source) populatePartEnding() *bytes.Buffer {
 
 func (s *Source) populateEnding() *bytes.Buffer {
 	s.buffered.Reset()
-	s.buffered.WriteString("\r\n--")
-	s.buffered.WriteString(s.boundary)
-	s.buffered.WriteString("--\r\n")
+	s.writeEnding(s.buffered)
 	return s.buffered
 }
 
+func (s *Source) writeEnding(buf *bytes.Buffer) {
+	buf.WriteString("\r\n--")
+	buf.WriteString(s.boundary)
+	buf.WriteString("--\r\n")
+}
+
 // SetBoundary overrides the [Source]'s default randomly-generated
 // boundary separator with an explicit value.
 //
@@ -218,6 +929,14 @@ func (s *Source) SetBoundary(boundary string) error {
 	if s.lastPart != nil {
 		return errors.New("SetBoundary called after read")
 	}
+	if err := validateBoundary(boundary); err != nil {
+		return err
+	}
+	s.boundary, s.boundaryErr = boundary, nil
+	return nil
+}
+
+func validateBoundary(boundary string) error {
 	// rfc2046#section-5.1.1
 	if len(boundary) < 1 || len(boundary) > 70 {
 		return errors.New("invalid boundary length")
@@ -237,14 +956,137 @@ func (s *Source) SetBoundary(boundary string) error {
 		}
 		return errors.New("invalid boundary character")
 	}
-	s.boundary = boundary
 	return nil
 }
 
+// SetDefaultPartHeaders sets headers added to every part at encode time.
+// If the part has its own values for a header key, they are used instead of the default ones.
+// Default headers are kept across [Source.Reset].
+func (s *Source) SetDefaultPartHeaders(h textproto.MIMEHeader) {
+	s.defaultHeaders = make(textproto.MIMEHeader, len(h))
+	for k, v := range h {
+		s.defaultHeaders[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+}
+
+// EnableStreamChecksum makes the [Source] feed every generated byte of the message to the hasher,
+// i.e. [hash/crc32.NewIEEE], so the message can be verified without teeing it.
+// The hasher is fed along with body digests enabled by [WithBodyDigests].
+// The digest is available with [Source.StreamChecksum] once the whole message is generated without skipping bytes.
+// The hasher is reset by [Source.Rewind] and [Source.Reset], clones made with [Source.Clone] don't hash.
+// Passing nil disables hashing. EnableStreamChecksum must be called before reading.
+func (s *Source) EnableStreamChecksum(hasher hash.Hash) error {
+	if s.pull != nil || s.finalizing {
+		return errors.New("EnableStreamChecksum called after read")
+	}
+	if hasher != nil {
+		hasher.Reset()
+	}
+	s.checksum = hasher
+	s.checksumSum = nil
+	return nil
+}
+
+// OnFinish sets the callback called exactly once when the message is generated completely or generation fails:
+// [Source.Read] returns [io.EOF] or another error, or [Source.WriteTo] returns. Successful completion is reported with nil error.
+// If the [Source] is closed before that, the callback gets [ErrClosed].
+// The callback is armed again by [Source.Rewind] and [Source.Reset], clones made with [Source.Clone] don't call it.
+func (s *Source) OnFinish(f func(err error)) {
+	s.onFinish = f
+}
+
+// StreamChecksum returns the digest of the message enabled with [Source.EnableStreamChecksum].
+// It returns false if hashing is not enabled, the message is not generated completely yet
+// or bytes of it were skipped with [Source.SkipBytes].
+// The digest is kept after [Source.Close].
+func (s *Source) StreamChecksum() ([]byte, bool) {
+	return s.checksumSum, s.checksumSum != nil
+}
+
 // FormDataContentType returns the Content-Type for an HTTP
 // multipart/form-data with this [Source]'s Boundary.
 func (s *Source) FormDataContentType() string {
-	return mime.FormatMediaType("multipart/form-data", map[string]string{"boundary": s.boundary})
+	return s.ContentType("form-data", nil)
+}
+
+// MixedContentType returns the Content-Type for a multipart/mixed message with this [Source]'s Boundary.
+func (s *Source) MixedContentType() string {
+	return s.ContentType("mixed", nil)
+}
+
+// AlternativeContentType returns the Content-Type for a multipart/alternative message with this [Source]'s Boundary.
+func (s *Source) AlternativeContentType() string {
+	return s.ContentType("alternative", nil)
+}
+
+// RelatedContentType returns the Content-Type for a multipart/related message (RFC 2387) with this [Source]'s Boundary.
+// typeParam is the media type of the root part and startCID is the Content-ID of the root part,
+// angle brackets are added to it if missing. Empty parameters are omitted.
+func (s *Source) RelatedContentType(typeParam, startCID string) string {
+	params := make(map[string]string, 2)
+	if typeParam != "" {
+		params["type"] = typeParam
+	}
+	if startCID != "" {
+		if !strings.HasPrefix(startCID, "<") {
+			startCID = "<" + startCID + ">"
+		}
+		params["start"] = startCID
+	}
+	return s.ContentType("related", params)
+}
+
+// ContentType returns the Content-Type for a multipart message of the given subtype (i.e. "mixed")
+// with provided parameters and this [Source]'s Boundary.
+func (s *Source) ContentType(subtype string, params map[string]string) string {
+	withBoundary := make(map[string]string, len(params)+1)
+	maps.Copy(withBoundary, params)
+	withBoundary["boundary"] = s.boundary
+	return mime.FormatMediaType("multipart/"+subtype, withBoundary)
+}
+
+// ContentLength returns the exact size of the message [Source] generates if it can be computed up front,
+// i.e. when [Part.Size] of every part reports a known size and no part is conditional, see [ConditionalPart].
+// The part sequence is iterated to compute the length, so the length is unknown unless the [Source]
+// is created with [WithReplayableParts]. It also must be called before reading from the [Source].
+func (s *Source) ContentLength() (length int64, ok bool) {
+	if !s.replayable || s.closed || s.boundaryErr != nil || s.pull != nil || s.firstHeadingWritten {
+		return 0, false
+	}
+	if s.recoverPanics {
+		defer func() {
+			if recover() != nil {
+				length, ok = 0, false
+			}
+		}()
+	}
+
+	var (
+		n       int64
+		heading bytes.Buffer
+		first   = true
+	)
+	for part, err := range s.parts {
+		if err != nil {
+			return 0, false
+		}
+		if part.condition != nil {
+			return 0, false // conditions are evaluated again while streaming and may change their results
+		}
+
+		size, ok := part.encodedSize()
+		if !ok {
+			return 0, false
+		}
+
+		heading.Reset()
+		s.writePartHeading(&heading, part, first)
+		first = false
+		n += int64(heading.Len()) + size
+	}
+
+	// closing delimiter: "\r\n--" + boundary + "--\r\n"
+	return n + int64(len(s.boundary)) + 8, true
 }
 
 // Boundary returns the [Source]'s boundary.
@@ -252,30 +1094,202 @@ func (s *Source) Boundary() string {
 	return s.boundary
 }
 
+// String returns a short description of the [Source] state for diagnostics.
+// It doesn't iterate the part sequence, so only the number of parts already reached is reported.
+func (s *Source) String() string {
+	return fmt.Sprintf("Source{boundary: %q, parts reached: %d, state: %s}", s.boundary, s.partIndex, s.state())
+}
+
+// GoString implements [fmt.GoStringer] with the same guarantees as [Source.String].
+func (s *Source) GoString() string {
+	return fmt.Sprintf("&itermultipart.Source{boundary: %q, partsReached: %d, state: %q}", s.boundary, s.partIndex, s.state())
+}
+
+func (s *Source) state() string {
+	switch {
+	case s.closed:
+		return "closed"
+	case s.finalizing:
+		return "finished"
+	case s.pull != nil || s.firstHeadingWritten:
+		return "streaming"
+	default:
+		return "pending"
+	}
+}
+
 // Close closes the [Source], preventing further reads.
+// Boundary is kept so the [Source] still can be cloned using [Source.Clone].
+// Contents kept open for [Source.Seek] are closed.
 func (s *Source) Close() error {
-	if s.stop != nil {
-		s.stop()
-	}
-	s.boundary = ""
-	s.buffered.Reset()
-	s.firstHeadingWritten = false
-	s.finalizing = false
-	s.lastPart = nil
+	err := errors.Join(s.resetState(), s.closeSeekClosers())
 	s.closed = true
-	return nil
+	s.finish(ErrClosed)
+	return err
+}
+
+// closeSeekClosers closes contents kept open for [Source.Seek].
+func (s *Source) closeSeekClosers() error {
+	var errs []error
+	for _, c := range s.seekClosers {
+		errs = append(errs, c.Close())
+	}
+	s.seekClosers = nil
+	return errors.Join(errs...)
 }
 
 // Reset resets the [Source] to use the provided part sequence.
+// A new random boundary is generated, even if it was set with [Source.SetBoundary],
+// unless the [Source] is created with [WithBoundaryKeptOnReset].
+// Use [Source.ResetKeepBoundary] or [Source.ResetWithBoundary] to control the boundary explicitly.
 func (s *Source) Reset(parts iter.Seq2[*Part, error]) {
+	s.reset(parts)
+	if !s.keepBoundaryOnReset {
+		s.populateRandomBoundary()
+	}
+}
+
+// ResetKeepBoundary resets the [Source] to use the provided part sequence keeping the current boundary.
+func (s *Source) ResetKeepBoundary(parts iter.Seq2[*Part, error]) {
+	s.reset(parts)
+}
+
+// ResetWithBoundary resets the [Source] to use the provided part sequence and the boundary.
+// If the boundary is invalid, the [Source] is not changed.
+func (s *Source) ResetWithBoundary(parts iter.Seq2[*Part, error], boundary string) error {
+	if err := validateBoundary(boundary); err != nil {
+		return err
+	}
+	s.reset(parts)
+	s.boundary, s.boundaryErr = boundary, nil
+	return nil
+}
+
+func (s *Source) reset(parts iter.Seq2[*Part, error]) {
+	s.resetState()
+	s.closeSeekClosers()
+	s.seeking = false
+	s.resetResults()
+	s.parts = parts
+	s.offsets = s.offsets[:0]
+	s.closed = false
+}
+
+// Rewind resets the [Source] to the beginning of the message keeping the boundary and the part sequence,
+// so the same message can be generated again, i.e. for an HTTP request retry.
+// The part sequence must support multiple iterations, like sequences returned by [PartSeq] do.
+// Contents already reached by the [Source] must be seekable or set with [Part.SetContentFactory],
+// otherwise an error is returned.
+func (s *Source) Rewind() error {
+	if err := s.checkRewindable(); err != nil {
+		return err
+	}
+	err := s.resetState()
+	s.resetResults()
+	s.closed = false
+	return err
+}
+
+// Clone returns a new [Source] generating the same message: it has the same boundary, default headers and part sequence.
+// Clone may be called even after [Source.Close], so it's suitable for [net/http.Request.GetBody].
+// Contents are shared between the [Source] and its clone and rewound when the clone reaches them,
+// so only one of them may be read at a time. Rewinding requirements are the same as for [Source.Rewind].
+func (s *Source) Clone() (*Source, error) {
+	if err := s.checkRewindable(); err != nil {
+		return nil, err
+	}
+	return &Source{
+		boundary:            s.boundary,
+		boundaryErr:         s.boundaryErr,
+		rand:                s.rand,
+		boundaryEntropy:     s.boundaryEntropy,
+		boundaryEncoder:     s.boundaryEncoder,
+		boundaryFunc:        s.boundaryFunc,
+		recoverPanics:       s.recoverPanics,
+		keepBoundaryOnReset: s.keepBoundaryOnReset,
+		strict:              s.strict,
+		asciiHeaders:        s.asciiHeaders,
+		stdlibDisposition:   s.stdlibDisposition,
+		checkCollisions:     s.checkCollisions,
+		orderedHeaders:      s.orderedHeaders,
+		digestAlgorithms:    s.digestAlgorithms,
+		bodyAlgorithms:      s.bodyAlgorithms,
+		logger:              s.logger,
+		metrics:             s.metrics,
+		readBufferSize:      s.readBufferSize,
+		minCopyBuffer:       s.minCopyBuffer,
+		maxCopyBuffer:       s.maxCopyBuffer,
+		keepOpen:            s.keepOpen,
+		replayable:          s.replayable,
+		progress:            s.progress,
+		limiter:             s.limiter,
+		parts:               s.parts,
+		defaultHeaders:      s.defaultHeaders,
+		ctx:                 s.ctx,
+		cancelCtx:           s.cancelCtx,
+		buffered:            new(bytes.Buffer),
+		offsets:             slices.Clone(s.offsets),
+	}, nil
+}
+
+func (s *Source) checkRewindable() error {
+	for i, offset := range s.offsets {
+		if offset == offsetNotSeekable {
+			return fmt.Errorf("content of part %d can't be rewound", i)
+		}
+	}
+	return nil
+}
+
+// resetResults resets results of the generated message. It's separate from resetState
+// because results must survive [Source.Close].
+func (s *Source) resetResults() {
+	if s.checksum != nil {
+		s.checksum.Reset()
+	}
+	s.checksumSum = nil
+	s.bodyHashers = nil
+	s.skipped = false
+	if s.tee != nil {
+		s.tee.err = nil
+	}
+	s.finished = false
+	s.stats = Stats{PartSizes: s.stats.PartSizes[:0]}
+	s.started, s.finishedAt = time.Time{}, time.Time{}
+}
+
+// finish marks the current message finished and calls the OnFinish callback once per message.
+func (s *Source) finish(err error) {
+	if s.finished {
+		return
+	}
+	s.finished = true
+	s.finishedAt = time.Now()
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.stats.Err = err
+	}
+	s.messageFinished(err)
+	if s.onFinish == nil {
+		return
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	s.onFinish(err)
+}
+
+func (s *Source) resetState() error {
 	if s.stop != nil {
 		s.stop()
 	}
-	s.populateRandomBoundary()
-	s.parts = parts
+	s.pull, s.stop = nil, nil
 	s.buffered.Reset()
 	s.firstHeadingWritten = false
 	s.finalizing = false
-	s.lastPart = nil
-	s.closed = false
+	s.lastPart, s.lastContent = nil, nil
+	s.partIndex = 0
+	s.panicErr = nil
+	s.partBytes, s.totalBytes = 0, 0
+	s.seekPending = false
+	return s.finishPart()
 }
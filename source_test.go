@@ -2,12 +2,22 @@ package itermultipart_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"iter"
 	"mime"
 	"mime/multipart"
+	"net/http"
 	"net/textproto"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/xakep666/itermultipart"
 )
@@ -221,3 +231,865 @@ func TestSortedHeader(t *testing.T) {
 		t.Fatalf("\n got: %q\nwant: %q\n", buf.String(), want)
 	}
 }
+
+func TestSourceContentLength(t *testing.T) {
+	t.Run("known", func(t *testing.T) {
+		src := itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("myfile").SetFileName("my-file.txt").SetContentBytes([]byte("my file contents")),
+			itermultipart.NewPart().SetFormName("key").SetContentString("val"),
+		), itermultipart.WithReplayableParts())
+
+		length, ok := src.ContentLength()
+		if !ok {
+			t.Fatal("ContentLength: expected known length")
+		}
+
+		var b bytes.Buffer
+		if _, err := b.ReadFrom(src); err != nil {
+			t.Fatalf("ReadFrom: unexpected error %s", err)
+		}
+		if g, e := int64(b.Len()), length; g != e {
+			t.Errorf("ContentLength = %d; actual length %d", e, g)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		src := itermultipart.NewSource(itermultipart.PartSeq(), itermultipart.WithReplayableParts())
+
+		length, ok := src.ContentLength()
+		if !ok {
+			t.Fatal("ContentLength: expected known length")
+		}
+
+		var b bytes.Buffer
+		if _, err := b.ReadFrom(src); err != nil {
+			t.Fatalf("ReadFrom: unexpected error %s", err)
+		}
+		if g, e := int64(b.Len()), length; g != e {
+			t.Errorf("ContentLength = %d; actual length %d", e, g)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		src := itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("key").SetContent(io.MultiReader(strings.NewReader("val"))),
+		), itermultipart.WithReplayableParts())
+
+		if _, ok := src.ContentLength(); ok {
+			t.Error("ContentLength: expected unknown length")
+		}
+	})
+}
+
+func TestSourceWriteToAfterRead(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("key").SetContentString("val"),
+	))
+	if err := src.SetBoundary("MIMEBOUNDARY"); err != nil {
+		t.Fatalf("Error setting mime boundary: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, src, 10); err != nil {
+		t.Fatalf("CopyN: unexpected error %s", err)
+	}
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if n, err := src.WriteTo(&buf); n != 0 || err != nil {
+		t.Fatalf("WriteTo after end = %d, %v; want 0, nil", n, err)
+	}
+
+	want := "--MIMEBOUNDARY\r\nContent-Disposition: form-data; name=key\r\n\r\nval\r\n--MIMEBOUNDARY--\r\n"
+	if want != buf.String() {
+		t.Fatalf("\n got: %q\nwant: %q\n", buf.String(), want)
+	}
+}
+
+func TestSourceDefaultPartHeaders(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("first").SetContentString("1"),
+		itermultipart.NewPart().SetFormName("second").SetHeaderValue("X-Request-Id", "own").SetContentString("2"),
+	), itermultipart.WithReplayableParts())
+	if err := src.SetBoundary("MIMEBOUNDARY"); err != nil {
+		t.Fatalf("Error setting mime boundary: %v", err)
+	}
+	src.SetDefaultPartHeaders(textproto.MIMEHeader{
+		"x-request-id": {"default"},
+		"X-Trace":      {"a", "b"},
+	})
+
+	length, ok := src.ContentLength()
+	if !ok {
+		t.Fatal("ContentLength: expected known length")
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(src); err != nil {
+		t.Fatalf("ReadFrom: unexpected error %s", err)
+	}
+
+	want := "--MIMEBOUNDARY\r\nContent-Disposition: form-data; name=first\r\nX-Request-Id: default\r\nX-Trace: a\r\nX-Trace: b\r\n\r\n1" +
+		"\r\n--MIMEBOUNDARY\r\nContent-Disposition: form-data; name=second\r\nX-Request-Id: own\r\nX-Trace: a\r\nX-Trace: b\r\n\r\n2" +
+		"\r\n--MIMEBOUNDARY--\r\n"
+	if want != buf.String() {
+		t.Fatalf("\n got: %q\nwant: %q\n", buf.String(), want)
+	}
+	if g, e := int64(buf.Len()), length; g != e {
+		t.Errorf("ContentLength = %d; actual length %d", e, g)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed *int
+}
+
+func (c closeRecorder) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestSourceRewind(t *testing.T) {
+	var opened, closed int
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("seekable").SetContentString("seekable contents"),
+		itermultipart.NewPart().SetFormName("factory").SetContentFactory(func() (io.ReadCloser, error) {
+			opened++
+			return closeRecorder{Reader: strings.NewReader("factory contents"), closed: &closed}, nil
+		}),
+	))
+
+	var first bytes.Buffer
+	if _, err := first.ReadFrom(src); err != nil {
+		t.Fatalf("ReadFrom: unexpected error %s", err)
+	}
+	if opened != 1 || closed != 1 {
+		t.Errorf("factory content opened %d and closed %d times; want 1 and 1", opened, closed)
+	}
+
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	var second bytes.Buffer
+	if _, err := src.WriteTo(&second); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("rewound message differs:\n got: %q\nwant: %q", second.String(), first.String())
+	}
+
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close: unexpected error %s", err)
+	}
+	clone, err := src.Clone()
+	if err != nil {
+		t.Fatalf("Clone: unexpected error %s", err)
+	}
+	var third bytes.Buffer
+	if _, err := third.ReadFrom(clone); err != nil {
+		t.Fatalf("ReadFrom: unexpected error %s", err)
+	}
+	if first.String() != third.String() {
+		t.Errorf("cloned message differs:\n got: %q\nwant: %q", third.String(), first.String())
+	}
+	if opened != 3 || closed != 3 {
+		t.Errorf("factory content opened %d and closed %d times; want 3 and 3", opened, closed)
+	}
+}
+
+func TestSourceRewindOneShot(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("key").SetContent(io.MultiReader(strings.NewReader("val"))),
+	))
+	if _, err := io.Copy(io.Discard, src); err != nil {
+		t.Fatalf("Copy: unexpected error %s", err)
+	}
+
+	if err := src.Rewind(); err == nil {
+		t.Error("Rewind: expected error for one-shot content")
+	}
+	if _, err := src.Clone(); err == nil {
+		t.Error("Clone: expected error for one-shot content")
+	}
+}
+
+func TestSourceString(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("key").SetContentString("val"),
+	))
+	if err := src.SetBoundary("MIMEBOUNDARY"); err != nil {
+		t.Fatalf("Error setting mime boundary: %v", err)
+	}
+
+	if g, e := src.String(), `Source{boundary: "MIMEBOUNDARY", parts reached: 0, state: pending}`; g != e {
+		t.Errorf("String() = %s; want %s", g, e)
+	}
+	if _, err := io.ReadAll(src); err != nil {
+		t.Fatalf("ReadAll: unexpected error %s", err)
+	}
+	if g, e := src.String(), `Source{boundary: "MIMEBOUNDARY", parts reached: 1, state: finished}`; g != e {
+		t.Errorf("String() = %s; want %s", g, e)
+	}
+	src.Close()
+	if g, e := fmt.Sprintf("%#v", src), `&itermultipart.Source{boundary: "MIMEBOUNDARY", partsReached: 0, state: "closed"}`; g != e {
+		t.Errorf("GoString() = %s; want %s", g, e)
+	}
+}
+
+func TestSourceContentTypes(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq())
+	if err := src.SetBoundary("MIMEBOUNDARY"); err != nil {
+		t.Fatalf("Error setting mime boundary: %v", err)
+	}
+
+	tests := []struct {
+		got, want string
+	}{
+		{src.FormDataContentType(), "multipart/form-data; boundary=MIMEBOUNDARY"},
+		{src.MixedContentType(), "multipart/mixed; boundary=MIMEBOUNDARY"},
+		{src.AlternativeContentType(), "multipart/alternative; boundary=MIMEBOUNDARY"},
+		{src.RelatedContentType("", ""), "multipart/related; boundary=MIMEBOUNDARY"},
+		{src.RelatedContentType("application/xop+xml", "root@example.com"), `multipart/related; boundary=MIMEBOUNDARY; start="<root@example.com>"; type="application/xop+xml"`},
+		{src.RelatedContentType("text/html", "<root>"), `multipart/related; boundary=MIMEBOUNDARY; start="<root>"; type="text/html"`},
+		{src.ContentType("byteranges", map[string]string{"foo": "bar"}), "multipart/byteranges; boundary=MIMEBOUNDARY; foo=bar"},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%d: got %q; want %q", i, tt.got, tt.want)
+		}
+	}
+}
+
+type countingWriter struct {
+	writes int
+	n      int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func smallFields(n int) iter.Seq2[*itermultipart.Part, error] {
+	parts := make([]*itermultipart.Part, n)
+	for i := range parts {
+		parts[i] = itermultipart.NewPart().SetFormName(fmt.Sprintf("field%d", i)).SetContentString("0123456789")
+	}
+	return itermultipart.PartSeq(parts...)
+}
+
+func TestSourceWriteToCoalescing(t *testing.T) {
+	src := itermultipart.NewSource(smallFields(10))
+	var w countingWriter
+	if _, err := src.WriteTo(&w); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected a single write, got %d", w.writes)
+	}
+
+	src.Reset(smallFields(10))
+	var viaWriteTo, viaRead bytes.Buffer
+	if _, err := src.WriteTo(&viaWriteTo); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	if _, err := io.Copy(&viaRead, struct{ io.Reader }{src}); err != nil {
+		t.Fatalf("Copy: unexpected error %s", err)
+	}
+	if viaWriteTo.String() != viaRead.String() {
+		t.Errorf("WriteTo and Read results differ:\nWriteTo: %q\n   Read: %q", viaWriteTo.String(), viaRead.String())
+	}
+}
+
+func TestSourceBoundaryFunc(t *testing.T) {
+	generate := func(boundaries func() (string, error)) []string {
+		var messages []string
+		for range 2 {
+			src := itermultipart.NewSource(smallFields(2), itermultipart.WithBoundaryFunc(boundaries))
+			var sb strings.Builder
+			if _, err := src.WriteTo(&sb); err != nil {
+				t.Fatalf("WriteTo: unexpected error %s", err)
+			}
+			messages = append(messages, sb.String())
+		}
+		return messages
+	}
+
+	first, second := generate(itermultipart.SequentialBoundaries("golden")), generate(itermultipart.SequentialBoundaries("golden"))
+	if !slices.Equal(first, second) {
+		t.Errorf("messages differ:\n%q\n%q", first, second)
+	}
+	for i, message := range first {
+		if want := "--golden" + strconv.Itoa(i+1) + "\r\n"; !strings.HasPrefix(message, want) {
+			t.Errorf("message %d doesn't start with %q: %q", i, want, message)
+		}
+	}
+
+	src := itermultipart.NewSource(smallFields(1), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")))
+	src.Reset(smallFields(1))
+	if got := src.Boundary(); got != "b2" {
+		t.Errorf("got boundary %q after Reset, want b2", got)
+	}
+}
+
+func TestSourceBoundaryOptions(t *testing.T) {
+	random := []byte{1, 2, 3, 4}
+	tests := []struct {
+		name string
+		opts []itermultipart.SourceOption
+		want string
+	}{
+		{"hex", nil, "01020304"},
+		{"base36", []itermultipart.SourceOption{itermultipart.WithBoundaryEncoder(itermultipart.Base36Boundary)}, strconv.FormatInt(0x01020304, 36)},
+	}
+	for _, tt := range tests {
+		opts := append([]itermultipart.SourceOption{
+			itermultipart.WithRand(bytes.NewReader(random)),
+			itermultipart.WithBoundaryEntropy(len(random)),
+		}, tt.opts...)
+		if got := itermultipart.NewSource(smallFields(1), opts...).Boundary(); got != tt.want {
+			t.Errorf("%s: boundary %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	randErr := errors.New("no entropy")
+	failing := []struct {
+		name string
+		opts []itermultipart.SourceOption
+	}{
+		{"rand failure", []itermultipart.SourceOption{itermultipart.WithRand(iotest.ErrReader(randErr))}},
+		{"too long", []itermultipart.SourceOption{itermultipart.WithBoundaryEntropy(36)}},
+		{"func failure", []itermultipart.SourceOption{itermultipart.WithBoundaryFunc(func() (string, error) { return "", randErr })}},
+		{"func invalid", []itermultipart.SourceOption{itermultipart.WithBoundaryFunc(func() (string, error) { return "bad\n", nil })}},
+	}
+	for _, tt := range failing {
+		src := itermultipart.NewSource(smallFields(1), tt.opts...)
+		if _, err := src.Read(make([]byte, 10)); err == nil {
+			t.Errorf("%s: Read: expected error", tt.name)
+		}
+		if _, err := src.WriteTo(io.Discard); err == nil {
+			t.Errorf("%s: WriteTo: expected error", tt.name)
+		}
+		if _, ok := src.ContentLength(); ok {
+			t.Errorf("%s: content length must be unknown", tt.name)
+		}
+		if _, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com", src); err == nil {
+			t.Errorf("%s: NewRequest: expected error", tt.name)
+		}
+
+		// explicit boundary doesn't need randomness
+		if err := src.SetBoundary("explicit"); err != nil {
+			t.Fatalf("%s: SetBoundary: unexpected error %s", tt.name, err)
+		}
+		if _, err := src.WriteTo(io.Discard); err != nil {
+			t.Errorf("%s: WriteTo after SetBoundary: unexpected error %s", tt.name, err)
+		}
+	}
+}
+
+func TestSourceResetBoundary(t *testing.T) {
+	src := itermultipart.NewSource(smallFields(1))
+	if err := src.SetBoundary("explicit"); err != nil {
+		t.Fatalf("SetBoundary: unexpected error %s", err)
+	}
+
+	src.ResetKeepBoundary(smallFields(2))
+	if src.Boundary() != "explicit" {
+		t.Errorf("ResetKeepBoundary changed the boundary to %q", src.Boundary())
+	}
+
+	if err := src.ResetWithBoundary(smallFields(2), "invalid\n"); err == nil {
+		t.Error("ResetWithBoundary: expected error for invalid boundary")
+	}
+	if err := src.ResetWithBoundary(smallFields(2), "other"); err != nil {
+		t.Fatalf("ResetWithBoundary: unexpected error %s", err)
+	}
+	var buf strings.Builder
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if !strings.HasPrefix(buf.String(), "--other\r\n") {
+		t.Errorf("unexpected message %q", buf.String())
+	}
+
+	src.Reset(smallFields(1))
+	if src.Boundary() == "other" {
+		t.Error("Reset must generate a new boundary")
+	}
+
+	kept := itermultipart.NewSource(smallFields(1), itermultipart.WithBoundaryKeptOnReset())
+	boundary := kept.Boundary()
+	kept.Reset(smallFields(1))
+	if kept.Boundary() != boundary {
+		t.Errorf("Reset with WithBoundaryKeptOnReset changed the boundary from %q to %q", boundary, kept.Boundary())
+	}
+}
+
+func TestSourceWithReadBuffer(t *testing.T) {
+	var plain, buffered countingWriter
+	var plainBuf, bufferedBuf bytes.Buffer
+	src := itermultipart.NewSource(smallFields(100))
+	if _, err := io.Copy(io.MultiWriter(&plain, &plainBuf), struct{ io.Reader }{src}); err != nil {
+		t.Fatalf("Copy: unexpected error %s", err)
+	}
+
+	bufferedSrc := itermultipart.NewSource(smallFields(100), itermultipart.WithReadBuffer(64<<10))
+	bufferedSrc.SetBoundary(src.Boundary())
+	if _, err := io.Copy(io.MultiWriter(&buffered, &bufferedBuf), struct{ io.Reader }{bufferedSrc}); err != nil {
+		t.Fatalf("Copy: unexpected error %s", err)
+	}
+
+	if plainBuf.String() != bufferedBuf.String() {
+		t.Errorf("messages differ:\n   plain: %q\nbuffered: %q", plainBuf.String(), bufferedBuf.String())
+	}
+	if buffered.writes != 1 {
+		t.Errorf("expected a single write, got %d (%d without buffer)", buffered.writes, plain.writes)
+	}
+}
+
+func TestSourceOnFinish(t *testing.T) {
+	var calls []error
+	src := itermultipart.NewSource(smallFields(10))
+	src.OnFinish(func(err error) { calls = append(calls, err) })
+
+	check := func(name string, want ...error) {
+		t.Helper()
+		if len(calls) != len(want) {
+			t.Fatalf("%s: %d calls (%v), want %d", name, len(calls), calls, len(want))
+		}
+		for i := range want {
+			if !errors.Is(calls[i], want[i]) {
+				t.Errorf("%s: call %d got %v, want %v", name, i, calls[i], want[i])
+			}
+		}
+	}
+
+	if _, err := io.Copy(io.Discard, struct{ io.Reader }{src}); err != nil {
+		t.Fatalf("Copy: unexpected error %s", err)
+	}
+	src.Read(make([]byte, 10))
+	src.Close()
+	check("Read", nil)
+
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	if _, err := src.WriteTo(io.Discard); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	src.WriteTo(io.Discard)
+	check("WriteTo", nil, nil)
+
+	src.Rewind()
+	src.Read(make([]byte, 10))
+	src.Close()
+	src.Close()
+	check("Close", nil, nil, itermultipart.ErrClosed)
+
+	errSeq := errors.New("sequence error")
+	src.Reset(func(yield func(*itermultipart.Part, error) bool) { yield(nil, errSeq) })
+	src.WriteTo(io.Discard)
+	check("error", nil, nil, itermultipart.ErrClosed, errSeq)
+}
+
+// cancelingReader cancels the context after the first read.
+type cancelingReader struct {
+	cancel context.CancelFunc
+}
+
+func (r cancelingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return copy(p, bytes.Repeat([]byte{'a'}, len(p))), nil // endless
+}
+
+func TestSourceContext(t *testing.T) {
+	parts := func(cancel context.CancelFunc) iter.Seq2[*itermultipart.Part, error] {
+		return itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("first").SetContentString("first"),
+			itermultipart.NewPart().SetFormName("endless").SetContent(cancelingReader{cancel}),
+		)
+	}
+
+	t.Run("WithContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		src := itermultipart.NewSource(parts(cancel), itermultipart.WithContext(ctx))
+		if _, err := io.Copy(io.Discard, struct{ io.Reader }{src}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Read: got error %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("WriteToContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		src := itermultipart.NewSource(parts(cancel))
+		if _, err := src.WriteToContext(ctx, io.Discard); !errors.Is(err, context.Canceled) {
+			t.Errorf("WriteToContext: got error %v, want %v", err, context.Canceled)
+		}
+
+		// context is not kept after the call
+		src.Reset(smallFields(1))
+		if _, err := src.WriteTo(io.Discard); err != nil {
+			t.Errorf("WriteTo: unexpected error %s", err)
+		}
+	})
+}
+
+func TestSourceStreamChecksum(t *testing.T) {
+	src := itermultipart.NewSource(smallFields(10))
+	if err := src.EnableStreamChecksum(crc32.NewIEEE()); err != nil {
+		t.Fatalf("EnableStreamChecksum: unexpected error %s", err)
+	}
+	if _, ok := src.StreamChecksum(); ok {
+		t.Error("checksum is available before the message is generated")
+	}
+
+	consumers := []struct {
+		name string
+		read func(io.Writer) error
+	}{
+		{"WriteTo", func(w io.Writer) error { _, err := src.WriteTo(w); return err }},
+		{"Read", func(w io.Writer) error { _, err := io.Copy(w, struct{ io.Reader }{src}); return err }},
+		{"partial Read", func(w io.Writer) error {
+			if _, err := io.CopyN(w, src, 10); err != nil {
+				return err
+			}
+			_, err := src.WriteTo(w)
+			return err
+		}},
+	}
+	for _, c := range consumers {
+		if err := src.Rewind(); err != nil {
+			t.Fatalf("%s: Rewind: unexpected error %s", c.name, err)
+		}
+		var buf bytes.Buffer
+		if err := c.read(&buf); err != nil {
+			t.Fatalf("%s: unexpected error %s", c.name, err)
+		}
+		src.Close()
+
+		sum, ok := src.StreamChecksum()
+		if !ok {
+			t.Fatalf("%s: checksum is not available", c.name)
+		}
+		want := crc32.NewIEEE()
+		want.Write(buf.Bytes())
+		if !bytes.Equal(sum, want.Sum(nil)) {
+			t.Errorf("%s: checksum %x, want %x", c.name, sum, want.Sum(nil))
+		}
+	}
+
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	if _, err := io.CopyN(io.Discard, src, 10); err != nil {
+		t.Fatalf("CopyN: unexpected error %s", err)
+	}
+	if err := src.EnableStreamChecksum(nil); err == nil {
+		t.Error("expected error enabling checksum after read")
+	}
+}
+
+func BenchmarkSourceSmallFields(b *testing.B) {
+	src := itermultipart.NewSource(smallFields(500))
+
+	b.Run("WriteTo", func(b *testing.B) {
+		var w countingWriter
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := src.Rewind(); err != nil {
+				b.Fatalf("Rewind: unexpected error %s", err)
+			}
+			if _, err := src.WriteTo(&w); err != nil {
+				b.Fatalf("WriteTo: unexpected error %s", err)
+			}
+		}
+		b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+	})
+
+	b.Run("Read", func(b *testing.B) {
+		var w countingWriter
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := src.Rewind(); err != nil {
+				b.Fatalf("Rewind: unexpected error %s", err)
+			}
+			if _, err := io.Copy(&w, struct{ io.Reader }{src}); err != nil {
+				b.Fatalf("Copy: unexpected error %s", err)
+			}
+		}
+		b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+	})
+}
+
+func TestPartSeqFromValues(t *testing.T) {
+	parseForm := func(t *testing.T, parts iter.Seq2[*itermultipart.Part, error]) url.Values {
+		t.Helper()
+		req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com", itermultipart.NewSource(parts))
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error %s", err)
+		}
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm: unexpected error %s", err)
+		}
+		return url.Values(req.MultipartForm.Value)
+	}
+
+	values := url.Values{"a": {"1", "2"}, "b": {"3"}, "empty": {}}
+	seq := itermultipart.PartSeqFromValues(values)
+	for range 2 { // sequence can be iterated multiple times
+		got := parseForm(t, seq)
+		if got.Encode() != values.Encode() {
+			t.Errorf("got %s, want %s", got.Encode(), values.Encode())
+		}
+	}
+
+	var names []string
+	for part, err := range seq {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		names = append(names, part.FormName())
+	}
+	if want := []string{"a", "a", "b"}; !slices.Equal(names, want) {
+		t.Errorf("got parts %v, want %v", names, want)
+	}
+
+	got := parseForm(t, itermultipart.PartSeqFromMap(map[string]string{"x": "1", "y": "2"}))
+	if want := (url.Values{"x": {"1"}, "y": {"2"}}); got.Encode() != want.Encode() {
+		t.Errorf("got %s, want %s", got.Encode(), want.Encode())
+	}
+}
+
+func TestSourceAppendTo(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("key", "val"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContent(strings.NewReader(strings.Repeat("x", 100<<10))),
+	), itermultipart.WithReplayableParts())
+	length, ok := src.ContentLength()
+	if !ok {
+		t.Fatal("ContentLength: expected known length")
+	}
+
+	prefix := []byte("prefix")
+	b, err := src.AppendTo(prefix)
+	if err != nil {
+		t.Fatalf("AppendTo: unexpected error %s", err)
+	}
+	if want := int64(len(prefix)) + length; int64(len(b)) != want {
+		t.Errorf("got len %d, want %d", len(b), want)
+	}
+	if want := cap(slices.Grow(prefix, int(length))); cap(b) != want {
+		t.Errorf("slice is grown more than once: cap %d, want %d", cap(b), want)
+	}
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if !bytes.Equal(b[len(prefix):], buf.Bytes()) || string(b[:len(prefix)]) != "prefix" {
+		t.Errorf("got %q, want %q", b, buf.Bytes())
+	}
+
+	if err := src.Rewind(); err != nil {
+		t.Fatalf("Rewind: unexpected error %s", err)
+	}
+	if msg, err := src.Bytes(); err != nil || !bytes.Equal(msg, buf.Bytes()) || cap(msg) != cap(slices.Grow([]byte(nil), int(length))) {
+		t.Errorf("Bytes: got len %d, cap %d, error %v", len(msg), cap(msg), err)
+	}
+
+	src = itermultipart.NewSource(func(yield func(*itermultipart.Part, error) bool) {
+		yield(nil, io.ErrUnexpectedEOF)
+	})
+	if b, err := src.AppendTo(prefix); !errors.Is(err, io.ErrUnexpectedEOF) || string(b) != "prefix" {
+		t.Errorf("got %q, %v", b, err)
+	}
+}
+
+func TestSourceCloseContents(t *testing.T) {
+	var closed int
+	newSource := func(opts ...itermultipart.SourceOption) *itermultipart.Source {
+		closed = 0
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("a").SetContent(closeRecorder{Reader: strings.NewReader("aaaa"), closed: &closed}),
+			itermultipart.NewPart().SetFormName("b").SetContent(closeRecorder{Reader: strings.NewReader("bbbb"), closed: &closed}),
+		), opts...)
+	}
+
+	src := newSource()
+	if _, err := src.WriteTo(io.Discard); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if closed != 2 {
+		t.Errorf("WriteTo: %d contents closed, want 2", closed)
+	}
+	if err := src.Rewind(); err == nil {
+		t.Error("Rewind: expected error for closed contents")
+	}
+
+	src = newSource()
+	if _, err := io.Copy(io.Discard, struct{ io.Reader }{src}); err != nil {
+		t.Fatalf("Read: unexpected error %s", err)
+	}
+	if closed != 2 {
+		t.Errorf("Read: %d contents closed, want 2", closed)
+	}
+
+	src = newSource()
+	if _, err := io.CopyN(io.Discard, src, 100); err != nil { // stops in the first content
+		t.Fatalf("CopyN: unexpected error %s", err)
+	}
+	src.Close()
+	if closed != 1 {
+		t.Errorf("Close: %d contents closed, want 1", closed)
+	}
+
+	src = newSource(itermultipart.WithContentsKeptOpen())
+	if _, err := src.WriteTo(io.Discard); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	src.Close()
+	if closed != 0 {
+		t.Errorf("WithContentsKeptOpen: %d contents closed, want 0", closed)
+	}
+}
+
+// maxWriteRecorder records the size of the largest write.
+type maxWriteRecorder struct {
+	max int
+}
+
+func (w *maxWriteRecorder) Write(p []byte) (int, error) {
+	w.max = max(w.max, len(p))
+	return len(p), nil
+}
+
+func plainReaderParts(sizes ...int) iter.Seq2[*itermultipart.Part, error] {
+	return func(yield func(*itermultipart.Part, error) bool) {
+		for i, size := range sizes {
+			// hide WriterTo to copy with the buffer
+			content := struct{ io.Reader }{bytes.NewReader(make([]byte, size))}
+			if !yield(itermultipart.NewPart().SetFormName(fmt.Sprintf("part%d", i)).SetContent(content), nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestSourceWithCopyBuffer(t *testing.T) {
+	const minSize, maxSize = 4 << 10, 256 << 10
+
+	large := itermultipart.NewSource(plainReaderParts(1<<20, 1<<20), itermultipart.WithCopyBuffer(minSize, maxSize))
+	var w maxWriteRecorder
+	if _, err := large.WriteTo(&w); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if w.max != maxSize {
+		t.Errorf("large contents: largest write is %d, want %d", w.max, maxSize)
+	}
+
+	sizes := make([]int, 30)
+	for i := range sizes {
+		sizes[i] = 5000
+	}
+	sizes = append(sizes, 1<<20) // copied with the buffer adapted to small contents
+	small := itermultipart.NewSource(plainReaderParts(sizes...), itermultipart.WithCopyBuffer(minSize, maxSize))
+	w = maxWriteRecorder{}
+	if _, err := small.WriteTo(&w); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	if w.max > 8<<10 {
+		t.Errorf("small contents: largest write after adaptation is %d", w.max)
+	}
+}
+
+func BenchmarkSourceCopyBuffer(b *testing.B) {
+	cases := []struct {
+		name  string
+		sizes []int
+	}{
+		{"small", slices.Repeat([]int{5000}, 200)},
+		{"large", slices.Repeat([]int{4 << 20}, 4)},
+	}
+	bounds := []struct {
+		name     string
+		min, max int
+	}{
+		{"fixed 32KiB", 32 << 10, 32 << 10},
+		{"default", 4 << 10, 32 << 10},
+		{"up to 256KiB", 4 << 10, 256 << 10},
+	}
+	for _, c := range cases {
+		for _, bb := range bounds {
+			b.Run(c.name+"/"+bb.name, func(b *testing.B) {
+				src := itermultipart.NewSource(plainReaderParts(c.sizes...), itermultipart.WithCopyBuffer(bb.min, bb.max))
+				var w countingWriter
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					src.Reset(plainReaderParts(c.sizes...))
+					if _, err := src.WriteTo(&w); err != nil {
+						b.Fatalf("WriteTo: unexpected error %s", err)
+					}
+				}
+				b.SetBytes(w.n / int64(b.N))
+				b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+			})
+		}
+	}
+}
+
+func TestSourceOrderedHeaders(t *testing.T) {
+	headerKeys := func(t *testing.T, part *itermultipart.Part, opts ...itermultipart.SourceOption) []string {
+		t.Helper()
+		var sb strings.Builder
+		if _, err := itermultipart.NewSource(itermultipart.PartSeq(part), opts...).WriteTo(&sb); err != nil {
+			t.Fatalf("WriteTo: unexpected error %s", err)
+		}
+		heading, _, _ := strings.Cut(sb.String(), "\r\n\r\n")
+		var keys []string
+		for _, line := range strings.Split(heading, "\r\n")[1:] {
+			key, _, _ := strings.Cut(line, ":")
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	newPart := func() *itermultipart.Part {
+		part := itermultipart.NewPart().
+			SetContentType("text/plain").
+			SetFormName("field").
+			SetHeaderValue("x-b", "b").
+			SetContentString("value")
+		part.Header["X-A"] = []string{"a"}
+		return part
+	}
+
+	if got, want := headerKeys(t, newPart()), []string{"Content-Disposition", "Content-Type", "X-A", "X-B"}; !slices.Equal(got, want) {
+		t.Errorf("sorted: got %v, want %v", got, want)
+	}
+	if got, want := headerKeys(t, newPart(), itermultipart.WithOrderedHeaders()), []string{"Content-Type", "Content-Disposition", "X-B", "X-A"}; !slices.Equal(got, want) {
+		t.Errorf("ordered: got %v, want %v", got, want)
+	}
+	if got, want := headerKeys(t, newPart().SetHeaderOrder("X-B"), itermultipart.WithOrderedHeaders()), []string{"X-B", "Content-Disposition", "Content-Type", "X-A"}; !slices.Equal(got, want) {
+		t.Errorf("overridden: got %v, want %v", got, want)
+	}
+
+	t.Run("parsed", func(t *testing.T) {
+		const message = "--b\r\nX-Z: z\r\nContent-Type: text/plain\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nvalue\r\n--b--\r\n"
+		src := itermultipart.NewSource(itermultipart.NewParser(strings.NewReader(message), "b").Parts(), itermultipart.WithOrderedHeaders())
+		if err := src.SetBoundary("b"); err != nil {
+			t.Fatalf("SetBoundary: unexpected error %s", err)
+		}
+		var sb strings.Builder
+		if _, err := src.WriteTo(&sb); err != nil {
+			t.Fatalf("WriteTo: unexpected error %s", err)
+		}
+		if sb.String() != message {
+			t.Errorf("got %q, want %q", sb.String(), message)
+		}
+	})
+}
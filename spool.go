@@ -0,0 +1,150 @@
+package itermultipart
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"iter"
+	"os"
+)
+
+// SpooledBody is the message generated by [Source] and stored in memory or in a temporary file by [SpooledSource].
+// It implements [io.ReadSeeker] and [io.ReaderAt], so it may be sent to servers refusing chunked requests
+// with an exact Content-Length and replayed on retries.
+// Pass [SpooledBody.NewReader] as a request body because [net/http.Client] closes bodies implementing [io.Closer].
+type SpooledBody struct {
+	*io.SectionReader
+	file   *os.File
+	closed bool
+}
+
+// SpooledSource generates the whole message of src keeping up to maxMemory bytes in memory
+// and moving it to a temporary file when the message is larger.
+// The temporary file is removed by [SpooledBody.Close], it's removed immediately if generation fails.
+func SpooledSource(src *Source, maxMemory int64) (*SpooledBody, error) {
+	sw := &spillWriter{memoryLimit: maxMemory}
+	size, err := src.WriteTo(sw)
+	if err != nil {
+		if sw.file != nil {
+			sw.file.Close()
+			os.Remove(sw.file.Name())
+		}
+		return nil, err
+	}
+
+	if sw.file == nil {
+		return &SpooledBody{SectionReader: io.NewSectionReader(bytes.NewReader(sw.buf.Bytes()), 0, size)}, nil
+	}
+	return &SpooledBody{SectionReader: io.NewSectionReader(sw.file, 0, size), file: sw.file}, nil
+}
+
+// InMemory reports whether the message is kept in memory.
+func (b *SpooledBody) InMemory() bool {
+	return b.file == nil
+}
+
+// NewReader returns an independent reader of the whole message, i.e. for [net/http.Request.GetBody].
+func (b *SpooledBody) NewReader() *io.SectionReader {
+	ra, _, size := b.Outer()
+	return io.NewSectionReader(ra, 0, size)
+}
+
+// Close removes the temporary file. Readers of the message must not be used after that.
+func (b *SpooledBody) Close() error {
+	if b.file == nil || b.closed {
+		return nil
+	}
+	b.closed = true
+	err := b.file.Close()
+	if removeErr := os.Remove(b.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// SpooledParts is the part sequence stored in a temporary file by [SpoolParts].
+type SpooledParts struct {
+	file   *os.File
+	ends   []int64 // end offsets of parts written by Part.WriteTo
+	closed bool
+}
+
+// SpoolParts writes parts to a temporary file in dir, or in the default directory for temporary files if it's empty,
+// so a slow producer is decoupled from a slow consumer and the whole submission may be retried.
+// Every part is stored with [Part.WriteTo], so transfer and content encodings are applied and [Part.Meta]
+// and conditions are not stored. The temporary file is removed by [SpooledParts.Close],
+// it's removed immediately if the sequence yields an error.
+func SpoolParts(parts iter.Seq2[*Part, error], dir string) (*SpooledParts, error) {
+	file, err := os.CreateTemp(dir, "multipart-parts-")
+	if err != nil {
+		return nil, err
+	}
+	sp := &SpooledParts{file: file}
+
+	var (
+		bw     = bufio.NewWriter(file)
+		offset int64
+	)
+	for part, err := range parts {
+		if err == nil {
+			var n int64
+			n, err = part.WriteTo(bw)
+			offset += n
+		}
+		if err != nil {
+			sp.Close()
+			return nil, err
+		}
+		sp.ends = append(sp.ends, offset)
+	}
+	if err := bw.Flush(); err != nil {
+		sp.Close()
+		return nil, err
+	}
+	return sp, nil
+}
+
+// Len returns the number of stored parts.
+func (sp *SpooledParts) Len() int {
+	return len(sp.ends)
+}
+
+// Parts returns the sequence of stored parts, it may be iterated multiple times and concurrently.
+// Contents are seekable sections of the temporary file, so the [Source] built from the sequence can be rewound
+// and [NewRequest] sets Content-Length and GetBody if [WithReplayableParts] is used.
+func (sp *SpooledParts) Parts() iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		var start int64
+		for _, end := range sp.ends {
+			part, err := sp.part(start, end)
+			if !yield(part, err) || err != nil {
+				return
+			}
+			start = end
+		}
+	}
+}
+
+func (sp *SpooledParts) part(start, end int64) (*Part, error) {
+	p := NewParser(io.NewSectionReader(sp.file, start, end-start), "")
+	part := NewPart()
+	if err := p.readHeader(part); err != nil {
+		return nil, err
+	}
+	contentStart := start + p.offset()
+	part.Content = io.NewSectionReader(sp.file, contentStart, end-contentStart)
+	return part, nil
+}
+
+// Close removes the temporary file. Parts must not be used after that.
+func (sp *SpooledParts) Close() error {
+	if sp.closed {
+		return nil
+	}
+	sp.closed = true
+	err := sp.file.Close()
+	if removeErr := os.Remove(sp.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
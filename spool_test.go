@@ -0,0 +1,147 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestSpooledSource(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	newSource := func() *itermultipart.Source {
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("a", "first"),
+			// unknown size, so the length can't be computed without spooling
+			itermultipart.NewPart().SetFormName("b").SetContent(io.MultiReader(strings.NewReader(strings.Repeat("0123456789", 1000)))),
+		), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")))
+	}
+	var full bytes.Buffer
+	if _, err := newSource().WriteTo(&full); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for _, maxMemory := range []int64{int64(full.Len()), 1000} {
+		body, err := itermultipart.SpooledSource(newSource(), maxMemory)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if inMemory := maxMemory >= int64(full.Len()); body.InMemory() != inMemory {
+			t.Errorf("max memory %d: in memory %t", maxMemory, body.InMemory())
+		}
+		if body.Size() != int64(full.Len()) {
+			t.Errorf("max memory %d: size %d, want %d", maxMemory, body.Size(), full.Len())
+		}
+
+		var received []byte
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength != int64(full.Len()) || len(r.TransferEncoding) > 0 {
+				t.Errorf("max memory %d: Content-Length %d, Transfer-Encoding %v", maxMemory, r.ContentLength, r.TransferEncoding)
+			}
+			received, _ = io.ReadAll(r.Body)
+		}))
+		req, err := http.NewRequest(http.MethodPost, srv.URL, body.NewReader())
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		req.ContentLength = body.Size()
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(body.NewReader()), nil }
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		resp.Body.Close()
+		srv.Close()
+		if !bytes.Equal(received, full.Bytes()) {
+			t.Errorf("max memory %d: sent body differs", maxMemory)
+		}
+
+		for _, r := range []io.Reader{body, body.NewReader()} {
+			if b, err := io.ReadAll(r); err != nil || !bytes.Equal(b, full.Bytes()) {
+				t.Errorf("max memory %d: replayed body differs, error %v", maxMemory, err)
+			}
+		}
+
+		if err := body.Close(); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if files, _ := filepath.Glob(filepath.Join(os.Getenv("TMPDIR"), "*")); len(files) > 0 {
+			t.Errorf("max memory %d: temporary files are not removed: %v", maxMemory, files)
+		}
+	}
+}
+
+func TestSpoolParts(t *testing.T) {
+	dir := t.TempDir()
+	consumed := false
+	parts := func(yield func(*itermultipart.Part, error) bool) {
+		if consumed {
+			t.Error("producer sequence is iterated twice")
+			return
+		}
+		consumed = true
+		_ = yield(itermultipart.NewFieldPart("a", "first"), nil) &&
+			yield(itermultipart.NewPart().SetFormName("b").SetFileName("b.txt").SetContent(io.MultiReader(strings.NewReader(strings.Repeat("0123456789", 1000)))), nil) &&
+			yield(itermultipart.NewPart().SetFormName("empty"), nil)
+	}
+	var want bytes.Buffer
+	consumed = false
+	if _, err := itermultipart.NewSource(parts, itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b"))).WriteTo(&want); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	consumed = false
+	spooled, err := itermultipart.SpoolParts(parts, dir)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if spooled.Len() != 3 {
+		t.Errorf("spooled %d parts, want 3", spooled.Len())
+	}
+
+	src := itermultipart.NewSource(spooled.Parts(), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")), itermultipart.WithReplayableParts())
+	req, err := itermultipart.NewRequest(context.Background(), http.MethodPost, "http://example.com", src)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if req.ContentLength != int64(want.Len()) || req.GetBody == nil {
+		t.Errorf("Content-Length %d, want %d, GetBody set: %t", req.ContentLength, want.Len(), req.GetBody != nil)
+	}
+	for range 2 {
+		got, err := io.ReadAll(src)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("replayed message differs\n got: %q\nwant: %q", got, want.Bytes())
+		}
+		if err := src.Rewind(); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+	}
+
+	if err := spooled.Close(); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) > 0 {
+		t.Errorf("temporary files are not removed: %v", files)
+	}
+
+	errFailed := errors.New("failed")
+	if _, err := itermultipart.SpoolParts(func(yield func(*itermultipart.Part, error) bool) {
+		_ = yield(itermultipart.NewFieldPart("a", "first"), nil) && yield(nil, errFailed)
+	}, dir); !errors.Is(err, errFailed) {
+		t.Errorf("got error %v, want %v", err, errFailed)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) > 0 {
+		t.Errorf("temporary files are not removed on error: %v", files)
+	}
+}
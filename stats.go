@@ -0,0 +1,59 @@
+package itermultipart
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// Stats describes the message generated by [Source] so far.
+type Stats struct {
+	Parts        int                          `json:"parts"`                  // number of parts started
+	HeaderBytes  int64                        `json:"header_bytes"`           // bytes of delimiters and part headers
+	ContentBytes int64                        `json:"content_bytes"`          // bytes of part contents
+	TotalBytes   int64                        `json:"total_bytes"`            // bytes of the whole message
+	PartSizes    []int64                      `json:"part_sizes"`             // content bytes of every started part
+	PartDigests  []map[DigestAlgorithm][]byte `json:"part_digests,omitempty"` // content digests of every started part, see WithPartDigests
+	BodyDigests  map[DigestAlgorithm][]byte   `json:"body_digests,omitempty"` // digests of the finished message, see WithBodyDigests
+	Duration     time.Duration                `json:"duration"`               // time since the first generated byte until the message is finished
+	Finished     bool                         `json:"finished"`               // the message is completely generated, failed or the Source is closed
+	Err          error                        `json:"-"`                      // error the generation failed with, ErrClosed if the Source is closed before finishing
+	Error        string                       `json:"error,omitempty"`        // message of Err for exported statistics
+}
+
+// Stats returns statistics of the message generated so far. It may be called during generation, i.e. from
+// [WithProgress] callback, or after it, i.e. from [Source.OnFinish] callback or after [Source.Close].
+// Statistics are reset by [Source.Rewind] and [Source.Reset].
+func (s *Source) Stats() Stats {
+	stats := s.stats
+	stats.Parts = len(s.stats.PartSizes)
+	stats.PartSizes = append([]int64(nil), s.stats.PartSizes...)
+	stats.PartDigests = slices.Clone(s.stats.PartDigests)
+	stats.BodyDigests = maps.Clone(s.stats.BodyDigests)
+	stats.TotalBytes = stats.HeaderBytes + stats.ContentBytes
+	stats.Finished = s.finished
+	if stats.Err != nil {
+		stats.Error = stats.Err.Error()
+	}
+	switch {
+	case s.started.IsZero():
+	case s.finished:
+		stats.Duration = s.finishedAt.Sub(s.started)
+	default:
+		stats.Duration = time.Since(s.started)
+	}
+	return stats
+}
+
+// accountStats accounts n bytes of the message, content bytes belong to the last started part.
+func (s *Source) accountStats(n int64, content bool) {
+	if s.started.IsZero() {
+		s.started = time.Now()
+	}
+	if content && len(s.stats.PartSizes) > 0 {
+		s.stats.ContentBytes += n
+		s.stats.PartSizes[len(s.stats.PartSizes)-1] += n
+	} else {
+		s.stats.HeaderBytes += n
+	}
+}
@@ -0,0 +1,121 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestSourceStats(t *testing.T) {
+	large := strings.Repeat("x", 100<<10)
+	newParts := func() []*itermultipart.Part {
+		return []*itermultipart.Part{
+			itermultipart.NewPart().SetFormName("small").SetContentString("small"),
+			itermultipart.NewPart().SetFormName("large").SetContent(iotest.HalfReader(strings.NewReader(large))),
+			itermultipart.NewPart().SetFormName("empty"),
+		}
+	}
+	wantSizes := []int64{5, int64(len(large)), 0}
+
+	consumers := map[string]func(*itermultipart.Source, io.Writer) error{
+		"Read": func(src *itermultipart.Source, w io.Writer) error {
+			_, err := io.Copy(w, struct{ io.Reader }{src})
+			return err
+		},
+		"WriteTo": func(src *itermultipart.Source, w io.Writer) error {
+			_, err := src.WriteTo(w)
+			return err
+		},
+	}
+	for name, consume := range consumers {
+		t.Run(name, func(t *testing.T) {
+			src := itermultipart.NewSource(itermultipart.PartSeq(newParts()...))
+			if stats := src.Stats(); stats.Parts != 0 || stats.TotalBytes != 0 || stats.Finished {
+				t.Errorf("unexpected stats before generation: %+v", stats)
+			}
+
+			var buf bytes.Buffer
+			if err := consume(src, &buf); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+
+			stats := src.Stats()
+			if stats.Parts != 3 || !slices.Equal(stats.PartSizes, wantSizes) {
+				t.Errorf("got %d parts of sizes %v, want 3 of %v", stats.Parts, stats.PartSizes, wantSizes)
+			}
+			if stats.TotalBytes != int64(buf.Len()) || stats.HeaderBytes+stats.ContentBytes != stats.TotalBytes {
+				t.Errorf("got total %d = %d + %d, message size %d", stats.TotalBytes, stats.HeaderBytes, stats.ContentBytes, buf.Len())
+			}
+			if stats.ContentBytes != 5+int64(len(large)) {
+				t.Errorf("got %d content bytes", stats.ContentBytes)
+			}
+			if !stats.Finished || stats.Duration <= 0 {
+				t.Errorf("got finished %t, duration %s", stats.Finished, stats.Duration)
+			}
+			if later := src.Stats(); later.Duration != stats.Duration {
+				t.Errorf("duration changed after finish: %s, was %s", later.Duration, stats.Duration)
+			}
+
+			if _, err := json.Marshal(stats); err != nil {
+				t.Errorf("Marshal: unexpected error %s", err)
+			}
+
+			src.Reset(itermultipart.PartSeq(newParts()...))
+			if stats := src.Stats(); stats.Parts != 0 || stats.TotalBytes != 0 || stats.Finished {
+				t.Errorf("unexpected stats after Reset: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestSourceStatsClose(t *testing.T) {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("a").SetContentString("content"),
+	))
+	if _, err := src.Read(make([]byte, 10)); err != nil {
+		t.Fatalf("Read: unexpected error %s", err)
+	}
+	if stats := src.Stats(); stats.Parts != 1 || stats.TotalBytes != 10 || stats.Finished {
+		t.Errorf("unexpected stats during generation: %+v", stats)
+	}
+	src.Close()
+	if stats := src.Stats(); stats.Parts != 1 || stats.TotalBytes != 10 || !stats.Finished || !errors.Is(stats.Err, itermultipart.ErrClosed) {
+		t.Errorf("unexpected stats after Close: %+v", stats)
+	}
+}
+
+func TestSourceStatsError(t *testing.T) {
+	errContent := errors.New("content failed")
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("a").SetContent(iotest.ErrReader(errContent)),
+	))
+	if _, err := src.WriteTo(io.Discard); !errors.Is(err, errContent) {
+		t.Fatalf("got error %v, want %s", err, errContent)
+	}
+	stats := src.Stats()
+	if !stats.Finished || !errors.Is(stats.Err, errContent) {
+		t.Errorf("unexpected stats after failure: %+v", stats)
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("Marshal: unexpected error %s", err)
+	}
+	if !strings.Contains(string(data), `"error":"`+stats.Error+`"`) || stats.Error == "" {
+		t.Errorf("error is not exported: %s", data)
+	}
+
+	src.Reset(itermultipart.PartSeq(itermultipart.NewFieldPart("a", "b")))
+	if _, err := src.WriteTo(io.Discard); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if stats := src.Stats(); stats.Err != nil || stats.Error != "" {
+		t.Errorf("unexpected error of the finished message: %v", stats.Err)
+	}
+}
@@ -0,0 +1,148 @@
+package itermultipart
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+)
+
+// ReplaceStreamWriter writes a "multipart/x-mixed-replace" stream to the [http.ResponseWriter],
+// i.e. MJPEG camera frames or live dashboard updates, where every part replaces the previous one on the client.
+// Unlike [MultipartResponseWriter] it writes the delimiter line right after every part and flushes the response,
+// so the client shows the part without waiting for the next one. The stream has no end until
+// [ReplaceStreamWriter.Close] is called or the client goes away.
+type ReplaceStreamWriter struct {
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	src       *Source // boundary and headings
+	buf       bytes.Buffer
+	delimited bool // the delimiter after the last part is written
+	onFlush   func(part *Part)
+	closed    bool
+}
+
+// NewReplaceStreamWriter creates a [ReplaceStreamWriter] and sets the Content-Type header of the response.
+// Options configure the boundary and headings like for [NewSource].
+func NewReplaceStreamWriter(w http.ResponseWriter, opts ...SourceOption) *ReplaceStreamWriter {
+	sw := &ReplaceStreamWriter{
+		w:   w,
+		rc:  http.NewResponseController(w),
+		src: NewSource(nil, opts...),
+	}
+	if sw.src.boundaryErr == nil {
+		w.Header().Set(contentTypeHeader, sw.ContentType())
+	}
+	return sw
+}
+
+// ContentType returns the Content-Type header value of the response.
+func (sw *ReplaceStreamWriter) ContentType() string {
+	return sw.src.ContentType("x-mixed-replace", nil)
+}
+
+// Boundary returns the boundary of the stream.
+func (sw *ReplaceStreamWriter) Boundary() string {
+	return sw.src.Boundary()
+}
+
+// OnFlush sets the function called after every part is written and flushed, i.e. to collect frame statistics.
+func (sw *ReplaceStreamWriter) OnFlush(f func(part *Part)) {
+	sw.onFlush = f
+}
+
+// WritePart writes the part followed by the delimiter and flushes the response.
+// It returns an error if the [http.ResponseWriter] doesn't support flushing.
+func (sw *ReplaceStreamWriter) WritePart(part *Part) error {
+	if sw.closed {
+		return errors.New("replace stream writer is closed")
+	}
+	if sw.src.boundaryErr != nil {
+		return sw.src.boundaryErr
+	}
+
+	sw.buf.Reset()
+	sw.src.writePartHeading(&sw.buf, part, true)
+	heading := sw.buf.Bytes()
+	if sw.delimited {
+		heading = heading[len("--")+len(sw.src.boundary)+len("\r\n"):]
+	}
+	if _, err := sw.w.Write(heading); err != nil {
+		return err
+	}
+	if err := writePartContent(sw.w, part); err != nil {
+		return err
+	}
+	if _, err := sw.w.Write([]byte("\r\n--" + sw.src.boundary + "\r\n")); err != nil {
+		return err
+	}
+	sw.delimited = true
+
+	if err := sw.rc.Flush(); err != nil {
+		return err
+	}
+	if sw.onFlush != nil {
+		sw.onFlush(part)
+	}
+	return nil
+}
+
+// Stream writes parts from the sequence until it ends, an error occurs or ctx is done,
+// i.e. when the request context is canceled because the client went away.
+// The stream isn't closed, so more parts may be written later.
+func (sw *ReplaceStreamWriter) Stream(ctx context.Context, parts iter.Seq2[*Part, error]) error {
+	for part, err := range parts {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := sw.WritePart(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamChannel writes parts received from the channel until it's closed, an error occurs or ctx is done.
+// It's convenient when frames are produced by another goroutine, i.e. a camera capture loop.
+func (sw *ReplaceStreamWriter) StreamChannel(ctx context.Context, ch <-chan *Part) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case part, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := sw.WritePart(part); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close finishes the stream with the closing delimiter and flushes the response.
+// Since the delimiter after the last part is already written, the stream ends with an empty part.
+// It doesn't close the underlying connection.
+func (sw *ReplaceStreamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	if sw.src.boundaryErr != nil {
+		return sw.src.boundaryErr
+	}
+	sw.closed = true
+
+	closing := "--" + sw.src.boundary + "--\r\n"
+	if sw.delimited {
+		// the delimiter after the last part already started the next one, finish it empty
+		closing = "\r\n\r\n" + closing
+	}
+	if _, err := sw.w.Write([]byte(closing)); err != nil {
+		return err
+	}
+	return sw.rc.Flush()
+}
@@ -0,0 +1,105 @@
+package itermultipart_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestReplaceStreamWriter(t *testing.T) {
+	newFrame := func(content string) *itermultipart.Part {
+		return itermultipart.NewPart().SetContentType("text/plain").SetContentString(content)
+	}
+	frames := make(chan *itermultipart.Part, 1)
+	frames <- newFrame("frame 0") // response headers are sent with the first frame
+	handlerDone := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := itermultipart.NewReplaceStreamWriter(w)
+		err := sw.StreamChannel(r.Context(), frames)
+		if err == nil {
+			err = sw.Close()
+		}
+		handlerDone <- err
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: unexpected error %s", err)
+	}
+	defer resp.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/x-mixed-replace" {
+		t.Fatalf("unexpected Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+
+	// every frame must be readable before the next one is produced
+	for i, frame := range []string{"frame 0", "frame 1", "frame 2"} {
+		if i > 0 {
+			frames <- newFrame(frame)
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart: unexpected error %s", err)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("ReadAll: unexpected error %s", err)
+		}
+		if string(content) != frame || part.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("got %s %q, want text/plain %q", part.Header.Get("Content-Type"), content, frame)
+		}
+	}
+
+	close(frames)
+	if err := <-handlerDone; err != nil {
+		t.Fatalf("handler: unexpected error %s", err)
+	}
+	part, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("NextPart: unexpected error %s", err)
+	}
+	if content, _ := io.ReadAll(part); len(content) != 0 {
+		t.Errorf("got closing part content %q, want empty", content)
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestReplaceStreamWriterStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := itermultipart.NewReplaceStreamWriter(rec)
+	var flushed int
+	sw.OnFlush(func(*itermultipart.Part) { flushed++ })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	parts := func(yield func(*itermultipart.Part, error) bool) {
+		for i := 0; ; i++ {
+			if i == 3 {
+				cancel() // client went away
+			}
+			if !yield(itermultipart.NewPart().SetContentString("frame"), nil) {
+				return
+			}
+		}
+	}
+	if err := sw.Stream(ctx, parts); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %s", err, context.Canceled)
+	}
+	if flushed != 3 {
+		t.Errorf("got %d flushes, want 3", flushed)
+	}
+	if !rec.Flushed {
+		t.Error("response is not flushed")
+	}
+}
@@ -0,0 +1,87 @@
+package itermultipart
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+var (
+	// ErrNotFormData is returned for parts without the "form-data" disposition and a form name.
+	ErrNotFormData = errors.New("part is not form-data")
+	// ErrDuplicateName is returned for parts repeating a form name if duplicates are rejected.
+	ErrDuplicateName = errors.New("duplicate form name")
+	// ErrUnsafeFileName is returned for parts whose file name contains path separators.
+	ErrUnsafeFileName = errors.New("file name contains path separators")
+)
+
+// DuplicateNamePolicy defines what happens with parts repeating a form name, see [StrictFormParts].
+type DuplicateNamePolicy int
+
+const (
+	// DuplicatesAllowed passes all parts, it's how multipart/form-data works by default.
+	DuplicatesAllowed DuplicateNamePolicy = iota
+	// DuplicatesFirstWins keeps the first part with the name and drops the rest without reading them.
+	DuplicatesFirstWins
+	// DuplicatesLastWins keeps the last part with the name. Parts already yielded can't be taken back,
+	// so [StrictFormParts] passes duplicates, collectors configured with [WithStrictForm] replace earlier values.
+	DuplicatesLastWins
+	// DuplicatesRejected fails with [ErrDuplicateName].
+	DuplicatesRejected
+)
+
+// StrictFormParts returns the sequence validating parts according to RFC 7578:
+// every part must have the "form-data" disposition with a form name, otherwise [ErrNotFormData] is yielded,
+// a file name must not contain path separators, otherwise [ErrUnsafeFileName] is yielded.
+// Parts repeating a form name are handled according to the policy.
+// A validation error stops the sequence. Errors of the underlying sequence are passed through.
+func StrictFormParts(parts iter.Seq2[*Part, error], duplicates DuplicateNamePolicy) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		seen := make(map[string]struct{})
+		index := -1
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			index++
+			name, err := checkFormPart(part)
+			if err != nil {
+				yield(nil, fmt.Errorf("part %d: %w", index, err))
+				return
+			}
+
+			if _, ok := seen[name]; ok {
+				switch duplicates {
+				case DuplicatesFirstWins:
+					continue
+				case DuplicatesRejected:
+					yield(nil, fmt.Errorf("part %d: %w %q", index, ErrDuplicateName, name))
+					return
+				}
+			}
+			seen[name] = struct{}{}
+
+			if !yield(part, nil) {
+				return
+			}
+		}
+	}
+}
+
+// checkFormPart returns the form name of the part conforming to RFC 7578.
+func checkFormPart(part *Part) (string, error) {
+	name := part.FormName()
+	if name == "" {
+		return "", fmt.Errorf("%w: disposition %q", ErrNotFormData, part.Header.Get(contentDispositionHeader))
+	}
+	// FileName strips directories, check the whole value
+	if fileName := part.dispositionParams["filename"]; strings.ContainsAny(fileName, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafeFileName, fileName)
+	}
+	return name, nil
+}
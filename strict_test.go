@@ -0,0 +1,114 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestStrictFormParts(t *testing.T) {
+	tests := []struct {
+		name       string
+		parts      []*itermultipart.Part
+		duplicates itermultipart.DuplicateNamePolicy
+		wantNames  []string
+		wantErr    error
+	}{
+		{
+			name: "valid",
+			parts: []*itermultipart.Part{
+				itermultipart.NewFieldPart("a", "1"),
+				itermultipart.NewPart().SetFormName("f").SetFileName("f.txt"),
+			},
+			wantNames: []string{"a", "f"},
+		},
+		{
+			name:    "inline",
+			parts:   []*itermultipart.Part{itermultipart.NewPart().SetInline().SetContentString("1")},
+			wantErr: itermultipart.ErrNotFormData,
+		},
+		{
+			name:    "no name",
+			parts:   []*itermultipart.Part{itermultipart.NewPart().SetHeaderValue("Content-Disposition", "form-data")},
+			wantErr: itermultipart.ErrNotFormData,
+		},
+		{
+			name: "path in file name",
+			parts: []*itermultipart.Part{
+				itermultipart.NewPart().SetHeaderValue("Content-Disposition", `form-data; name="f"; filename="..\\..\\evil.exe"`),
+			},
+			wantErr: itermultipart.ErrUnsafeFileName,
+		},
+		{
+			name:      "duplicates allowed",
+			parts:     []*itermultipart.Part{itermultipart.NewFieldPart("a", "1"), itermultipart.NewFieldPart("a", "2")},
+			wantNames: []string{"a", "a"},
+		},
+		{
+			name:       "first wins",
+			parts:      []*itermultipart.Part{itermultipart.NewFieldPart("a", "1"), itermultipart.NewFieldPart("a", "2"), itermultipart.NewFieldPart("b", "3")},
+			duplicates: itermultipart.DuplicatesFirstWins,
+			wantNames:  []string{"a", "b"},
+		},
+		{
+			name:       "rejected",
+			parts:      []*itermultipart.Part{itermultipart.NewFieldPart("a", "1"), itermultipart.NewFieldPart("a", "2")},
+			duplicates: itermultipart.DuplicatesRejected,
+			wantNames:  []string{"a"},
+			wantErr:    itermultipart.ErrDuplicateName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				names []string
+				err   error
+			)
+			for part, partErr := range itermultipart.StrictFormParts(itermultipart.PartSeq(tt.parts...), tt.duplicates) {
+				if partErr != nil {
+					err = partErr
+					break
+				}
+				names = append(names, part.FormName())
+			}
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if len(names) != len(tt.wantNames) {
+				t.Fatalf("got names %q, want %q", names, tt.wantNames)
+			}
+			for i := range names {
+				if names[i] != tt.wantNames[i] {
+					t.Errorf("got names %q, want %q", names, tt.wantNames)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectValuesStrictForm(t *testing.T) {
+	parts := func() []*itermultipart.Part {
+		return []*itermultipart.Part{itermultipart.NewFieldPart("a", "1"), itermultipart.NewFieldPart("a", "2")}
+	}
+
+	for policy, want := range map[itermultipart.DuplicateNamePolicy]string{
+		itermultipart.DuplicatesAllowed:   "a=1&a=2",
+		itermultipart.DuplicatesFirstWins: "a=1",
+		itermultipart.DuplicatesLastWins:  "a=2",
+	} {
+		values, err := itermultipart.CollectValues(itermultipart.PartSeq(parts()...), itermultipart.WithStrictForm(policy))
+		if err != nil {
+			t.Fatalf("policy %d: unexpected error %s", policy, err)
+		}
+		if got := values.Encode(); got != want {
+			t.Errorf("policy %d: got values %s, want %s", policy, got, want)
+		}
+	}
+
+	_, err := itermultipart.CollectValues(itermultipart.PartSeq(formParts()...), itermultipart.WithStrictForm(itermultipart.DuplicatesAllowed))
+	if !errors.Is(err, itermultipart.ErrNotFormData) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrNotFormData)
+	}
+}
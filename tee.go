@@ -0,0 +1,78 @@
+package itermultipart
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TeePolicy defines how [Source] handles errors of the audit writer set by [Source.TeeTo].
+type TeePolicy int
+
+const (
+	// TeeFailStream stops generation with the audit writer error. Bytes are written to the audit writer
+	// before they are passed to the destination, so the destination never gets unrecorded bytes.
+	TeeFailStream TeePolicy = iota
+	// TeeBestEffort keeps generating the message if the audit writer fails.
+	// The audit writer is not used after the first error, the error is reported by [Source.TeeErr].
+	TeeBestEffort
+)
+
+// teeWriter writes to the audit writer according to the policy.
+type teeWriter struct {
+	w      io.Writer
+	policy TeePolicy
+	err    error // first error of w
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	if t.err == nil && len(p) > 0 {
+		n, err := t.w.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			t.err = fmt.Errorf("audit writer: %w", err)
+		}
+	}
+	if t.err != nil && t.policy == TeeFailStream {
+		return 0, t.err
+	}
+	return len(p), nil
+}
+
+// TeeTo makes the [Source] mirror every generated byte of the message, headings and contents, to w
+// while it's streamed with [Source.Read] or [Source.WriteTo], i.e. to record uploads for compliance.
+// Policy defines what happens if w fails, see [TeePolicy].
+// The message generated again after [Source.Rewind] and [Source.Reset] is mirrored again,
+// clones made with [Source.Clone] don't mirror.
+// Passing nil disables mirroring. TeeTo must be called before reading.
+func (s *Source) TeeTo(w io.Writer, policy TeePolicy) error {
+	if s.pull != nil || s.finalizing {
+		return errors.New("TeeTo called after read")
+	}
+	if w == nil {
+		s.tee = nil
+		return nil
+	}
+	s.tee = &teeWriter{w: w, policy: policy}
+	return nil
+}
+
+// TeeErr returns the error of the audit writer set by [Source.TeeTo] for the current message.
+// With [TeeBestEffort] policy it's the only way to know that the recording is incomplete.
+func (s *Source) TeeErr() error {
+	if s.tee == nil {
+		return nil
+	}
+	return s.tee.err
+}
+
+// Tee makes src mirror the generated message to w like [Source.TeeTo] with [TeeFailStream] policy
+// and returns src, so it can be used in place, i.e. as a request body, keeping [Source.WriteTo] fast path.
+// Like for [io.TeeReader], errors of w are returned by reads of src.
+// Tee has no effect if reading from src has already started.
+func Tee(src *Source, w io.Writer) *Source {
+	_ = src.TeeTo(w, TeeFailStream)
+	return src
+}
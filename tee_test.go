@@ -0,0 +1,127 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// failingWriter accepts limit bytes and fails after that.
+type failingWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+var errAuditFailed = errors.New("audit failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.limit {
+		return 0, errAuditFailed
+	}
+	return w.buf.Write(p)
+}
+
+func TestSourceTeeTo(t *testing.T) {
+	consumers := []struct {
+		name string
+		read func(*itermultipart.Source, io.Writer) error
+	}{
+		{"WriteTo", func(src *itermultipart.Source, w io.Writer) error { _, err := src.WriteTo(w); return err }},
+		{"Read", func(src *itermultipart.Source, w io.Writer) error {
+			_, err := io.Copy(w, struct{ io.Reader }{src})
+			return err
+		}},
+	}
+
+	for _, c := range consumers {
+		t.Run(c.name, func(t *testing.T) {
+			src := itermultipart.NewSource(smallFields(100))
+			var audit bytes.Buffer
+			if err := src.TeeTo(&audit, itermultipart.TeeFailStream); err != nil {
+				t.Fatalf("TeeTo: unexpected error %s", err)
+			}
+			var out bytes.Buffer
+			if err := c.read(src, &out); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if !bytes.Equal(audit.Bytes(), out.Bytes()) {
+				t.Errorf("audit differs from the output:\n got: %q\nwant: %q", audit.Bytes(), out.Bytes())
+			}
+
+			if err := src.TeeTo(nil, itermultipart.TeeFailStream); err == nil {
+				t.Error("expected error after read")
+			}
+		})
+
+		t.Run(c.name+" fail stream", func(t *testing.T) {
+			src := itermultipart.NewSource(smallFields(100))
+			audit := &failingWriter{limit: 100}
+			if err := src.TeeTo(audit, itermultipart.TeeFailStream); err != nil {
+				t.Fatalf("TeeTo: unexpected error %s", err)
+			}
+			var out bytes.Buffer
+			if err := c.read(src, &out); !errors.Is(err, errAuditFailed) {
+				t.Fatalf("got error %v, want %v", err, errAuditFailed)
+			}
+			if !bytes.Equal(audit.buf.Bytes(), out.Bytes()) {
+				t.Errorf("destination got unrecorded bytes:\n got: %q\nwant: %q", out.Bytes(), audit.buf.Bytes())
+			}
+			if !errors.Is(src.TeeErr(), errAuditFailed) {
+				t.Errorf("TeeErr returned %v", src.TeeErr())
+			}
+		})
+
+		t.Run(c.name+" best effort", func(t *testing.T) {
+			src := itermultipart.NewSource(smallFields(100))
+			audit := &failingWriter{limit: 100}
+			if err := src.TeeTo(audit, itermultipart.TeeBestEffort); err != nil {
+				t.Fatalf("TeeTo: unexpected error %s", err)
+			}
+			var out bytes.Buffer
+			if err := c.read(src, &out); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if !errors.Is(src.TeeErr(), errAuditFailed) {
+				t.Errorf("TeeErr returned %v", src.TeeErr())
+			}
+			if !bytes.HasPrefix(out.Bytes(), audit.buf.Bytes()) {
+				t.Errorf("audit is not a prefix of the output")
+			}
+
+			if err := src.Rewind(); err != nil {
+				t.Fatalf("Rewind: unexpected error %s", err)
+			}
+			if err := src.TeeErr(); err != nil {
+				t.Errorf("TeeErr returned %v after Rewind", err)
+			}
+		})
+	}
+}
+
+func TestTee(t *testing.T) {
+	var sent, mirrored bytes.Buffer
+	src := itermultipart.Tee(itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "b"),
+	)), &mirrored)
+	if _, err := src.WriteTo(&sent); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !bytes.Equal(sent.Bytes(), mirrored.Bytes()) {
+		t.Errorf("mirrored %q, sent %q", mirrored.String(), sent.String())
+	}
+
+	audit := &failingWriter{limit: 10}
+	src = itermultipart.Tee(itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewFieldPart("a", "b"),
+	)), audit)
+	sent.Reset()
+	if _, err := sent.ReadFrom(src); !errors.Is(err, errAuditFailed) {
+		t.Errorf("got error %v, want %s", err, errAuditFailed)
+	}
+	if !errors.Is(src.TeeErr(), errAuditFailed) {
+		t.Errorf("unexpected tee error %v", src.TeeErr())
+	}
+}
@@ -0,0 +1,201 @@
+package itermultipart
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"iter"
+	"mime/quotedprintable"
+	"strings"
+)
+
+const contentTransferEncodingHeader = "Content-Transfer-Encoding"
+
+// Transfer encodings supported by [Part.SetTransferEncoding].
+const (
+	TransferEncodingBase64          = "base64"
+	TransferEncodingQuotedPrintable = "quoted-printable"
+	TransferEncoding7Bit            = "7bit"
+	TransferEncoding8Bit            = "8bit"
+	TransferEncodingBinary          = "binary"
+)
+
+// base64LineLength is the maximum length of encoded lines required by RFC 2045.
+const base64LineLength = 76
+
+// SetTransferEncoding sets the "Content-Transfer-Encoding" header of the part and makes [Source]
+// and [Part.AddToWriter] encode the content accordingly. Content must be set unencoded.
+// Base64 content is written as a single line unless [Part.SetSMTPCompat] is enabled,
+// quoted-printable lines get soft line breaks and line breaks of the content are normalized to CRLF.
+// Other encodings ("7bit", "8bit", "binary") only set the header.
+// Parts read from a message are never encoded again, even if they have the header.
+func (p *Part) SetTransferEncoding(encoding string) *Part {
+	p.transferEncoding = strings.ToLower(encoding)
+	return p.SetHeaderValue(contentTransferEncodingHeader, p.transferEncoding)
+}
+
+// SetSMTPCompat makes the transfer encoding follow line length limits of RFC 2045 that SMTP servers enforce:
+// base64 lines are wrapped at 76 columns, as some MTAs reject unwrapped base64.
+// Quoted-printable lines get soft line breaks at 76 columns either way. It's not needed for HTTP messages.
+func (p *Part) SetSMTPCompat(enabled bool) *Part {
+	p.smtpCompat = enabled
+	return p
+}
+
+// encodedContent returns the content of the part as it must be written to the message.
+func (p *Part) encodedContent(content io.Reader) io.Reader {
+	if content == nil {
+		return nil
+	}
+	content = p.compressedContent(content)
+
+	er := &encodingReader{src: content}
+	switch p.transferEncoding {
+	case TransferEncodingBase64:
+		var w io.Writer = &er.buf
+		if p.smtpCompat {
+			w = &lineWrapper{w: w}
+		}
+		er.enc = base64.NewEncoder(base64.StdEncoding, w)
+	case TransferEncodingQuotedPrintable:
+		er.enc = quotedprintable.NewWriter(&er.buf)
+	default:
+		return content
+	}
+	return er
+}
+
+// encodedSize returns the size of the content after encoding if it can be determined without reading.
+func (p *Part) encodedSize() (int64, bool) {
+	size, ok := p.Size()
+	if !ok || p.compresses() {
+		return 0, false
+	}
+
+	switch p.transferEncoding {
+	case TransferEncodingBase64:
+		encoded := int64(base64.StdEncoding.EncodedLen(int(size)))
+		if encoded == 0 || !p.smtpCompat {
+			return encoded, true
+		}
+		lines := (encoded + base64LineLength - 1) / base64LineLength
+		return encoded + 2*(lines-1), true
+	case TransferEncodingQuotedPrintable:
+		return 0, false // depends on the content
+	default:
+		return size, true
+	}
+}
+
+// encodingReader encodes the source content on the fly.
+type encodingReader struct {
+	src   io.Reader
+	enc   io.WriteCloser // writes to buf
+	buf   bytes.Buffer
+	chunk []byte
+	err   error
+}
+
+func (r *encodingReader) Read(p []byte) (int, error) {
+	if r.chunk == nil {
+		r.chunk = make([]byte, 3*1024) // multiple of 3 to avoid keeping base64 remainders
+	}
+
+	for r.buf.Len() == 0 && r.err == nil {
+		n, err := r.src.Read(r.chunk)
+		r.enc.Write(r.chunk[:n]) // writes to the buffer never fail
+		switch {
+		case errors.Is(err, io.EOF):
+			r.enc.Close()
+			r.err = io.EOF
+		case err != nil:
+			r.err = err
+		}
+	}
+
+	if r.buf.Len() > 0 {
+		return r.buf.Read(p)
+	}
+	return 0, r.err
+}
+
+// lineWrapper inserts CRLF after every base64LineLength bytes except the last line.
+type lineWrapper struct {
+	w      io.Writer
+	column int
+}
+
+func (lw *lineWrapper) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if lw.column == base64LineLength {
+			if _, err := lw.w.Write([]byte("\r\n")); err != nil {
+				return n, err
+			}
+			lw.column = 0
+		}
+
+		chunk := p[:min(len(p), base64LineLength-lw.column)]
+		written, err := lw.w.Write(chunk)
+		n += written
+		lw.column += written
+		if err != nil {
+			return n, err
+		}
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// DecodeTransferEncoding decodes contents of the parts according to their "Content-Transfer-Encoding" header.
+// Base64 and quoted-printable contents are decoded, "7bit", "8bit" and "binary" contents are left as is.
+// The header of decoded parts is removed like [multipart.Reader.NextPart] does for quoted-printable,
+// parts with unknown encodings are yielded untouched so the caller can handle them.
+// It's useful for email attachments read with raw [PartsFromReader] or [Parser].
+// Parts are not modified permanently: the content and the header are restored after the part is yielded.
+func DecodeTransferEncoding(parts iter.Seq2[*Part, error]) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		for part, err := range parts {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+
+			encoding := part.Header.Get(contentTransferEncodingHeader)
+			if part.transferEncoding != "" {
+				encoding = "" // content is not encoded yet, see SetTransferEncoding
+			}
+
+			var decoded io.Reader
+			switch strings.ToLower(strings.TrimSpace(encoding)) {
+			case TransferEncodingBase64:
+				decoded = base64.NewDecoder(base64.StdEncoding, part.Content)
+			case TransferEncodingQuotedPrintable:
+				decoded = quotedprintable.NewReader(part.Content)
+			case TransferEncoding7Bit, TransferEncoding8Bit, TransferEncodingBinary:
+				decoded = part.Content
+			default:
+				if !yield(part, nil) {
+					return
+				}
+				continue
+			}
+			if part.Content == nil {
+				decoded = nil
+			}
+
+			content := part.Content
+			part.Content = decoded
+			part.Header.Del(contentTransferEncodingHeader)
+			next := yield(part, nil)
+			part.Content = content
+			part.Header.Set(contentTransferEncodingHeader, encoding)
+			if !next {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestPartSetTransferEncoding(t *testing.T) {
+	content := strings.Repeat("Ünïcode text with a long line to wrap. ", 10) + "\nsecond line"
+	decoders := map[string]func(io.Reader) io.Reader{
+		itermultipart.TransferEncodingBase64: func(r io.Reader) io.Reader {
+			return base64.NewDecoder(base64.StdEncoding, r)
+		},
+		itermultipart.TransferEncodingQuotedPrintable: func(r io.Reader) io.Reader {
+			return quotedprintable.NewReader(r)
+		},
+		itermultipart.TransferEncoding8Bit: func(r io.Reader) io.Reader { return r },
+	}
+
+	for encoding, decode := range decoders {
+		t.Run(encoding, func(t *testing.T) {
+			src := itermultipart.NewSource(itermultipart.PartSeq(
+				itermultipart.NewPart().SetContentString(content).SetTransferEncoding(encoding).SetSMTPCompat(true),
+				itermultipart.NewPart().SetContent(iotest.OneByteReader(strings.NewReader(content))).SetTransferEncoding(encoding).SetSMTPCompat(true),
+				itermultipart.NewPart().SetTransferEncoding(encoding).SetSMTPCompat(true),
+			))
+			var buf bytes.Buffer
+			if _, err := io.Copy(&buf, iotest.HalfReader(src)); err != nil {
+				t.Fatalf("Copy: unexpected error %s", err)
+			}
+
+			mr := multipart.NewReader(&buf, src.Boundary())
+			for i, want := range []string{content, content, ""} {
+				part, err := mr.NextRawPart()
+				if err != nil {
+					t.Fatalf("part %d: unexpected error %s", i, err)
+				}
+				if got := part.Header.Get("Content-Transfer-Encoding"); got != encoding {
+					t.Errorf("part %d: Content-Transfer-Encoding %q, want %q", i, got, encoding)
+				}
+
+				encoded, _ := io.ReadAll(part)
+				for _, line := range strings.Split(string(encoded), "\r\n") {
+					if len(line) > 76 && encoding != itermultipart.TransferEncoding8Bit {
+						t.Errorf("part %d: line is longer than 76 characters: %q", i, line)
+					}
+				}
+
+				decoded, err := io.ReadAll(decode(bytes.NewReader(encoded)))
+				if err != nil {
+					t.Fatalf("part %d: decode: unexpected error %s", i, err)
+				}
+				if encoding == itermultipart.TransferEncodingQuotedPrintable {
+					want = strings.ReplaceAll(want, "\n", "\r\n")
+				}
+				if string(decoded) != want {
+					t.Errorf("part %d:\n got: %q\nwant: %q", i, decoded, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPartSetTransferEncodingContentLength(t *testing.T) {
+	for _, smtpCompat := range []bool{false, true} {
+		for _, size := range []int{0, 1, 2, 3, 56, 57, 58, 114, 1000} {
+			src := itermultipart.NewSource(itermultipart.PartSeq(
+				itermultipart.NewPart().SetContentBytes(bytes.Repeat([]byte{'a'}, size)).SetTransferEncoding("BASE64").SetSMTPCompat(smtpCompat),
+			), itermultipart.WithReplayableParts())
+			length, ok := src.ContentLength()
+			if !ok {
+				t.Fatalf("size %d: content length is unknown", size)
+			}
+			n, err := src.WriteTo(io.Discard)
+			if err != nil {
+				t.Fatalf("size %d: WriteTo: unexpected error %s", size, err)
+			}
+			if length != n {
+				t.Errorf("SMTP compat %t, size %d: content length %d, written %d", smtpCompat, size, length, n)
+			}
+		}
+	}
+
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentString("text").SetTransferEncoding(itermultipart.TransferEncodingQuotedPrintable),
+	), itermultipart.WithReplayableParts())
+	if _, ok := src.ContentLength(); ok {
+		t.Error("content length of quoted-printable part must be unknown")
+	}
+}
+
+func TestPartSetSMTPCompat(t *testing.T) {
+	content := bytes.Repeat([]byte{0xff}, 200)
+	for _, smtpCompat := range []bool{false, true} {
+		var b bytes.Buffer
+		part := itermultipart.NewPart().SetContentBytes(content).SetTransferEncoding(itermultipart.TransferEncodingBase64).SetSMTPCompat(smtpCompat)
+		if _, err := part.WriteTo(&b); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		_, encoded, _ := strings.Cut(b.String(), "\r\n\r\n")
+
+		want := base64.StdEncoding.EncodeToString(content)
+		if smtpCompat {
+			var lines []string
+			for line := range slices.Chunk([]byte(want), 76) {
+				lines = append(lines, string(line))
+			}
+			want = strings.Join(lines, "\r\n")
+		}
+		if encoded != want {
+			t.Errorf("SMTP compat %t:\n got: %q\nwant: %q", smtpCompat, encoded, want)
+		}
+	}
+}
+
+func TestPartSetTransferEncodingAddToWriter(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := itermultipart.NewPart().SetContentString("hello").SetTransferEncoding("base64").AddToWriter(mw); err != nil {
+		t.Fatalf("AddToWriter: unexpected error %s", err)
+	}
+	mw.Close()
+
+	part, err := multipart.NewReader(&buf, mw.Boundary()).NextRawPart()
+	if err != nil {
+		t.Fatalf("NextRawPart: unexpected error %s", err)
+	}
+	if content, _ := io.ReadAll(part); string(content) != "aGVsbG8=" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func ExamplePart_SetTransferEncoding() {
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().
+			SetContentType("text/plain; charset=utf-8").
+			SetTransferEncoding(itermultipart.TransferEncodingQuotedPrintable).
+			SetContentString("Grüße!"),
+		itermultipart.NewPart().
+			SetContentType("application/octet-stream").
+			SetTransferEncoding(itermultipart.TransferEncodingBase64).
+			SetContentBytes([]byte{0, 1, 2, 3}),
+	))
+	src.SetBoundary("boundary")
+
+	var buf strings.Builder
+	src.WriteTo(&buf)
+	fmt.Print(strings.ReplaceAll(buf.String(), "\r\n", "\n"))
+	// Output:
+	// --boundary
+	// Content-Transfer-Encoding: quoted-printable
+	// Content-Type: text/plain; charset=utf-8
+	//
+	// Gr=C3=BC=C3=9Fe!
+	// --boundary
+	// Content-Transfer-Encoding: base64
+	// Content-Type: application/octet-stream
+	//
+	// AAECAw==
+	// --boundary--
+}
+
+func TestDecodeTransferEncoding(t *testing.T) {
+	binary := string([]byte{0, 1, 2, 0xff})
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentString(binary).SetTransferEncoding(itermultipart.TransferEncodingBase64),
+		itermultipart.NewPart().SetContentString("Grüße").SetTransferEncoding(itermultipart.TransferEncodingQuotedPrintable),
+		itermultipart.NewPart().SetContentString("8bit").SetTransferEncoding("8BIT"),
+		itermultipart.NewPart().SetContentString("unknown").SetHeaderValue("Content-Transfer-Encoding", "x-unknown"),
+		itermultipart.NewPart().SetContentString("none"),
+	))
+
+	parser := itermultipart.NewParser(src, src.Boundary())
+	got, err := collectParts(t, itermultipart.DecodeTransferEncoding(parser.Parts()))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := []parsedPart{
+		{header: "map[]", content: binary},
+		{header: "map[]", content: "Grüße"},
+		{header: "map[]", content: "8bit"},
+		{header: "map[Content-Transfer-Encoding:[x-unknown]]", content: "unknown"},
+		{header: "map[]", content: "none"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("\n got: %q\nwant: %q", got, want)
+	}
+
+	// parts to be encoded are not decoded
+	part := itermultipart.NewPart().SetContentString("plain").SetTransferEncoding(itermultipart.TransferEncodingBase64)
+	got, err = collectParts(t, itermultipart.DecodeTransferEncoding(itermultipart.PartSeq(part)))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(got) != 1 || got[0].content != "plain" {
+		t.Errorf("unexpected parts %q", got)
+	}
+}
@@ -0,0 +1,325 @@
+// Package tus uploads messages generated by [itermultipart.Source] and contents of single parts
+// with the tus resumable upload protocol 1.0.0 (https://tus.io/protocols/resumable-upload):
+// the upload is created, sent in chunks with PATCH requests, failed requests are retried from the offset
+// reported by the server and interrupted uploads are resumed. Creation with deferred length
+// and checksum extensions are supported.
+package tus
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xakep666/itermultipart"
+)
+
+// Version is the version of the protocol sent in the Tus-Resumable header.
+const Version = "1.0.0"
+
+// DefaultChunkSize is the size of PATCH requests used if [Client.ChunkSize] is not set.
+const DefaultChunkSize = 4 << 20
+
+// ErrOffsetMismatch is returned when the offset reported by the server doesn't belong to the chunk being sent,
+// i.e. the upload was modified by another client.
+var ErrOffsetMismatch = errors.New("tus: upload offset mismatch")
+
+// Client uploads data to a tus server. The zero value is usable. Fields must not be changed during uploads.
+type Client struct {
+	HTTPClient *http.Client // [http.DefaultClient] if nil
+	Header     http.Header  // added to every request, i.e. for authorization
+	ChunkSize  int64        // size of PATCH requests, DefaultChunkSize if not positive; a chunk is kept in memory for retries
+	Retries    int          // retries of a failed PATCH request, the offset is requested from the server before retrying
+
+	// Checksum is the algorithm of the checksum extension sent in the Upload-Checksum header of every PATCH request:
+	// "md5", "sha1", "sha256" or "sha512". Checksums are not sent if it's empty.
+	Checksum string
+}
+
+// Create creates the upload of length bytes at the creation endpoint and returns the upload URL.
+// Negative length defers it until the last chunk is sent, the server must support the creation-defer-length extension.
+// Metadata is sent in the Upload-Metadata header.
+func (c *Client) Create(ctx context.Context, endpoint string, length int64, metadata map[string]string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	if length >= 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(length, 10))
+	} else {
+		req.Header.Set("Upload-Defer-Length", "1")
+	}
+	if len(metadata) > 0 {
+		req.Header.Set("Upload-Metadata", encodeMetadata(metadata))
+	}
+
+	resp, err := c.do(req, http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("tus: no Location in the creation response")
+	}
+	u, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("tus: invalid Location: %w", err)
+	}
+	return u.String(), nil
+}
+
+// Offset returns the number of bytes of the upload stored by the server.
+func (c *Client) Offset(ctx context.Context, uploadURL string) (int64, error) {
+	offset, _, err := c.head(ctx, uploadURL)
+	return offset, err
+}
+
+// UploadSource creates the upload of the message generated by src and sends it. The length of the upload
+// is deferred if [itermultipart.Source.ContentLength] doesn't know it, i.e. the src isn't created
+// with [itermultipart.WithReplayableParts]. The upload URL is returned even on error
+// if the upload was created, so it can be resumed with [Client.ResumeSource] later.
+func (c *Client) UploadSource(ctx context.Context, endpoint string, src *itermultipart.Source, metadata map[string]string) (string, error) {
+	length, ok := src.ContentLength()
+	if !ok {
+		length = -1
+	}
+	uploadURL, err := c.Create(ctx, endpoint, length, metadata)
+	if err != nil {
+		return "", err
+	}
+	return uploadURL, c.send(ctx, uploadURL, src, 0, length >= 0)
+}
+
+// ResumeSource continues the upload of the message generated by src from the offset stored by the server.
+// The src must generate the same message as the interrupted one, i.e. it's rewound or created with the same boundary,
+// bytes before the offset are skipped with [itermultipart.Source.SkipBytes].
+func (c *Client) ResumeSource(ctx context.Context, uploadURL string, src *itermultipart.Source) error {
+	offset, lengthKnown, err := c.head(ctx, uploadURL)
+	if err != nil {
+		return err
+	}
+	if _, err := src.SkipBytes(offset); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return c.send(ctx, uploadURL, src, offset, lengthKnown)
+}
+
+// UploadPart creates the upload of the part content alone, without multipart framing, and sends it.
+// File name, content type and form name of the part are sent as "filename", "filetype" and "name" metadata.
+// The upload URL is returned even on error if the upload was created, see [Client.ResumePart].
+func (c *Client) UploadPart(ctx context.Context, endpoint string, part *itermultipart.Part) (string, error) {
+	metadata := make(map[string]string, 3)
+	for key, value := range map[string]string{
+		"filename": part.FileName(),
+		"filetype": part.ContentType(),
+		"name":     part.FormName(),
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	length, ok := part.Size()
+	if !ok {
+		length = -1
+	}
+
+	content, err := part.OpenContent()
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	uploadURL, err := c.Create(ctx, endpoint, length, metadata)
+	if err != nil {
+		return "", err
+	}
+	return uploadURL, c.send(ctx, uploadURL, content, 0, length >= 0)
+}
+
+// ResumePart continues the upload of the part content from the offset stored by the server,
+// content before the offset is read and discarded.
+func (c *Client) ResumePart(ctx context.Context, uploadURL string, part *itermultipart.Part) error {
+	offset, lengthKnown, err := c.head(ctx, uploadURL)
+	if err != nil {
+		return err
+	}
+	content, err := part.OpenContent()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	if _, err := io.CopyN(io.Discard, content, offset); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return c.send(ctx, uploadURL, content, offset, lengthKnown)
+}
+
+// send uploads the rest of r starting at offset chunk by chunk.
+// If the length is not known by the server, it's sent with the last chunk.
+func (c *Client) send(ctx context.Context, uploadURL string, r io.Reader, offset int64, lengthKnown bool) error {
+	chunkSize := c.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		last := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !last {
+			return err
+		}
+		if n == 0 && last && lengthKnown {
+			return nil
+		}
+
+		finalLength := int64(-1)
+		if last && !lengthKnown {
+			finalLength = offset + int64(n)
+		}
+		if err := c.sendChunk(ctx, uploadURL, buf[:n], offset, finalLength); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		offset += int64(n)
+	}
+}
+
+// sendChunk sends the chunk retrying failed requests from the offset stored by the server.
+func (c *Client) sendChunk(ctx context.Context, uploadURL string, chunk []byte, offset, finalLength int64) error {
+	end := offset + int64(len(chunk))
+	for attempt := 0; ; {
+		newOffset, err := c.patch(ctx, uploadURL, chunk, offset, finalLength)
+		if err != nil {
+			if attempt++; attempt > c.Retries || ctx.Err() != nil {
+				return err
+			}
+			// the server may have stored a part of the chunk
+			if newOffset, _, err = c.head(ctx, uploadURL); err != nil {
+				return err
+			}
+		}
+		if newOffset < offset || newOffset > end {
+			return fmt.Errorf("%w: server offset %d, chunk %d-%d", ErrOffsetMismatch, newOffset, offset, end)
+		}
+		if newOffset == end {
+			return nil
+		}
+		chunk, offset = chunk[newOffset-offset:], newOffset
+	}
+}
+
+func (c *Client) patch(ctx context.Context, uploadURL string, chunk []byte, offset, finalLength int64) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodPatch, uploadURL, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if finalLength >= 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(finalLength, 10))
+	}
+	if c.Checksum != "" {
+		h, err := newChecksum(c.Checksum)
+		if err != nil {
+			return 0, err
+		}
+		h.Write(chunk)
+		req.Header.Set("Upload-Checksum", c.Checksum+" "+base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	}
+
+	resp, err := c.do(req, http.StatusNoContent)
+	if err != nil {
+		return 0, err
+	}
+	return parseOffset(resp)
+}
+
+// head returns the offset of the upload and whether its length is known by the server.
+func (c *Client) head(ctx context.Context, uploadURL string) (int64, bool, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, uploadURL, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := c.do(req, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		return 0, false, err
+	}
+	offset, err := parseOffset(resp)
+	return offset, resp.Header.Get("Upload-Defer-Length") != "1", err
+}
+
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Tus-Resumable", Version)
+	return req, nil
+}
+
+// do sends the request and checks the response status, the response body is drained and closed.
+func (c *Client) do(req *http.Request, statuses ...int) (*http.Response, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, resp.Body) // to reuse the connection
+	resp.Body.Close()
+	if !slices.Contains(statuses, resp.StatusCode) {
+		return nil, fmt.Errorf("tus: %s %s: unexpected status %s", req.Method, req.URL, resp.Status)
+	}
+	return resp, nil
+}
+
+func parseOffset(resp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("tus: invalid Upload-Offset %q", resp.Header.Get("Upload-Offset"))
+	}
+	return offset, nil
+}
+
+func encodeMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func newChecksum(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("tus: unsupported checksum algorithm %q", algorithm)
+	}
+}
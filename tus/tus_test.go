@@ -0,0 +1,231 @@
+package tus_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+	"github.com/xakep666/itermultipart/tus"
+)
+
+type upload struct {
+	data     []byte
+	length   int64 // -1 if deferred
+	metadata string
+}
+
+// server is a minimal tus server storing uploads in memory.
+type server struct {
+	mu      sync.Mutex
+	uploads map[string]*upload
+	patches int
+	failAt  int // number of the PATCH request storing half of the chunk and failing, 0 to disable
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Tus-Resumable") != tus.Version {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("Tus-Resumable", tus.Version)
+
+	if r.Method == http.MethodPost {
+		u := &upload{length: -1, metadata: r.Header.Get("Upload-Metadata")}
+		if r.Header.Get("Upload-Defer-Length") != "1" {
+			u.length, _ = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		}
+		id := strconv.Itoa(len(s.uploads))
+		s.uploads[id] = u
+		w.Header().Set("Location", "/files/"+id)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	u, ok := s.uploads[strings.TrimPrefix(r.URL.Path, "/files/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.Itoa(len(u.data)))
+
+	switch r.Method {
+	case http.MethodHead:
+		if u.length < 0 {
+			w.Header().Set("Upload-Defer-Length", "1")
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		if offset, _ := strconv.Atoi(r.Header.Get("Upload-Offset")); offset != len(u.data) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if checksum := r.Header.Get("Upload-Checksum"); checksum != "" {
+			sum := sha256.Sum256(body)
+			if checksum != "sha256 "+base64.StdEncoding.EncodeToString(sum[:]) {
+				w.WriteHeader(460)
+				return
+			}
+		}
+		if s.patches++; s.patches == s.failAt {
+			u.data = append(u.data, body[:len(body)/2]...)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if length := r.Header.Get("Upload-Length"); length != "" {
+			u.length, _ = strconv.ParseInt(length, 10, 64)
+		}
+		u.data = append(u.data, body...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(u.data)))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) upload(t *testing.T, uploadURL string) *upload {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[uploadURL[strings.LastIndex(uploadURL, "/")+1:]]
+	if !ok {
+		t.Fatalf("upload %s not found", uploadURL)
+	}
+	return u
+}
+
+func newServer(t *testing.T) (*server, string) {
+	s := &server{uploads: make(map[string]*upload)}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL + "/files/"
+}
+
+func TestUploadSource(t *testing.T) {
+	large := strings.Repeat("0123456789", 1000)
+	newSource := func(size int64) *itermultipart.Source {
+		part := itermultipart.NewPart().SetFormName("file").SetContent(strings.NewReader(large))
+		if size < 0 {
+			part.SetContent(io.MultiReader(strings.NewReader(large))) // unknown size
+		}
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewFieldPart("a", "first"),
+			part,
+		), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")), itermultipart.WithReplayableParts())
+	}
+	var full bytes.Buffer
+	if _, err := newSource(0).WriteTo(&full); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		size   int64
+		failAt int
+	}{
+		{"known length", 0, 0},
+		{"deferred length", -1, 0},
+		{"retry", 0, 2},
+		{"retry last chunk", -1, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, endpoint := newServer(t)
+			s.failAt = tc.failAt
+			c := &tus.Client{ChunkSize: 4096, Retries: 1, Checksum: "sha256"}
+
+			uploadURL, err := c.UploadSource(context.Background(), endpoint, newSource(tc.size), map[string]string{"filename": "form"})
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			u := s.upload(t, uploadURL)
+			if !bytes.Equal(u.data, full.Bytes()) || u.length != int64(full.Len()) {
+				t.Errorf("uploaded %d of %d bytes, want %d", len(u.data), u.length, full.Len())
+			}
+			if want := "filename " + base64.StdEncoding.EncodeToString([]byte("form")); u.metadata != want {
+				t.Errorf("metadata %q, want %q", u.metadata, want)
+			}
+		})
+	}
+}
+
+func TestResumeSource(t *testing.T) {
+	newSource := func() *itermultipart.Source {
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("file").SetContentString(strings.Repeat("0123456789", 1000)),
+		), itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")))
+	}
+	var full bytes.Buffer
+	if _, err := newSource().WriteTo(&full); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	s, endpoint := newServer(t)
+	s.failAt = 2
+	c := &tus.Client{ChunkSize: 4096}
+	uploadURL, err := c.UploadSource(context.Background(), endpoint, newSource(), nil)
+	if err == nil {
+		t.Fatal("error expected without retries")
+	}
+	if uploadURL == "" {
+		t.Fatal("upload URL expected for resuming")
+	}
+	if offset, err := c.Offset(context.Background(), uploadURL); err != nil || offset != 4096+2048 {
+		t.Fatalf("offset %d, error %v", offset, err)
+	}
+
+	if err := c.ResumeSource(context.Background(), uploadURL, newSource()); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if u := s.upload(t, uploadURL); !bytes.Equal(u.data, full.Bytes()) {
+		t.Errorf("uploaded %d bytes, want %d", len(u.data), full.Len())
+	}
+}
+
+func TestUploadPart(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	part := itermultipart.NewPart().SetFormName("file").SetFileName("a.txt").SetContentString(content)
+
+	s, endpoint := newServer(t)
+	s.failAt = 1
+	c := &tus.Client{ChunkSize: 4096}
+	uploadURL, err := c.UploadPart(context.Background(), endpoint, part)
+	if err == nil {
+		t.Fatal("error expected without retries")
+	}
+	u := s.upload(t, uploadURL)
+	if want := "filename YS50eHQ=,filetype YXBwbGljYXRpb24vb2N0ZXQtc3RyZWFt,name ZmlsZQ=="; u.metadata != want {
+		t.Errorf("metadata %q, want %q", u.metadata, want)
+	}
+
+	part.SetContentString(content)
+	if err := c.ResumePart(context.Background(), uploadURL, part); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if string(u.data) != content || u.length != int64(len(content)) {
+		t.Errorf("uploaded %d of %d bytes, want %d", len(u.data), u.length, len(content))
+	}
+}
+
+func TestCreateError(t *testing.T) {
+	_, endpoint := newServer(t)
+	c := &tus.Client{Header: http.Header{"Tus-Resumable": {"0.2.2"}}}
+	// the header is overridden by the client
+	if _, err := c.Create(context.Background(), endpoint, 1, nil); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, err := c.Offset(context.Background(), endpoint+"missing"); err == nil {
+		t.Error("error expected for missing upload")
+	}
+}
@@ -0,0 +1,271 @@
+package itermultipart
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+var (
+	fileHeaderType      = reflect.TypeFor[FileHeader]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// FileHeader describes a collected file part. Its content is kept in memory or in a temporary file.
+type FileHeader struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+
+	content []byte
+	tmpfile string // path of the temporary file with the content if it's not in memory
+}
+
+// Open returns a reader of the file content.
+func (fh *FileHeader) Open() (multipart.File, error) {
+	if fh.tmpfile != "" {
+		return os.Open(fh.tmpfile)
+	}
+	return sectionReadCloser{io.NewSectionReader(bytes.NewReader(fh.content), 0, int64(len(fh.content)))}, nil
+}
+
+// Remove removes the temporary file of the content if any, the content can't be opened afterwards.
+func (fh *FileHeader) Remove() error {
+	if fh.tmpfile == "" {
+		return nil
+	}
+	err := os.Remove(fh.tmpfile)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return err
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+}
+
+func (sectionReadCloser) Close() error {
+	return nil
+}
+
+// tempFile is a content stored in a temporary file, closing it removes the file.
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	return errors.Join(f.File.Close(), os.Remove(f.Name()))
+}
+
+// Unmarshal populates fields of the struct pointed by v from form parts matching them by name.
+// Field names are defined by `multipart` tags like for [Marshal], tag options are ignored.
+// Supported field types are strings, booleans, numbers, [encoding.TextUnmarshaler]s,
+// byte slices, [io.Reader]s and [FileHeader]s receiving the part content, pointers to them
+// and slices of them receiving repeated fields. For other fields the last part wins.
+// Parts not matching any field are skipped without reading their content.
+// Contents are read with limits of [CollectValues] and [CollectFiles] set by options: contents of [io.Reader]
+// and [FileHeader] fields are kept in memory up to the limit set by [WithMaxMemory] and in temporary files beyond it,
+// other contents are read to memory up to the limit set by [WithMaxValuesSize].
+// Readers of temporary files are [io.Closer]s removing the files, for file headers call [FileHeader.Remove].
+// On error temporary files are removed by Unmarshal.
+// Wrap the sequence with [DecodeCharset] to get text fields of legacy charsets as UTF-8.
+func Unmarshal(parts iter.Seq2[*Part, error], v any, opts ...CollectOption) (err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("multipart: Unmarshal(non-pointer or nil %T)", v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("multipart: Unmarshal(pointer to non-struct %s)", rv.Type())
+	}
+
+	fields, err := structFields(rv.Type())
+	if err != nil {
+		return fmt.Errorf("multipart: %w", err)
+	}
+	byName := make(map[string]structField, len(fields))
+	for _, f := range fields {
+		if ft := rv.Type().FieldByIndex(f.index).Type; !unmarshalable(ft) {
+			return fmt.Errorf("multipart: field %s has unsupported type %s", f.name, ft)
+		}
+		if _, ok := byName[f.name]; !ok {
+			byName[f.name] = f
+		}
+	}
+
+	c := newCollector(opts)
+	var spilled []*FileHeader
+	defer func() {
+		if err != nil {
+			for _, fh := range spilled {
+				fh.Remove()
+			}
+		}
+	}()
+
+	var buf bytes.Buffer
+	for part, err := range parts {
+		if err != nil {
+			return err
+		}
+
+		f, ok := byName[part.FormName()]
+		if !ok {
+			continue
+		}
+
+		fv, err := fieldByIndexAlloc(rv, f.index)
+		if err != nil {
+			return fmt.Errorf("multipart: field %s: %w", f.name, err)
+		}
+
+		var fh *FileHeader
+		buf.Reset()
+		if spillable(fv.Type()) {
+			fh, err = c.collectFile(part)
+			if fh != nil && fh.tmpfile != "" {
+				spilled = append(spilled, fh)
+			}
+		} else {
+			err = c.collectValue(&buf, part.Content)
+		}
+		if err != nil {
+			return fmt.Errorf("multipart: field %s: %w", f.name, err)
+		}
+		if err := unmarshalValue(fv, part, buf.Bytes(), fh); err != nil {
+			return fmt.Errorf("multipart: field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// spillable reports whether contents of the field are collected like files.
+func spillable(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+	}
+	return t == fileHeaderType || t == readerType
+}
+
+// fieldByIndexAlloc is like [reflect.Value.FieldByIndex] but allocates nil embedded pointers.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func unmarshalable(t reflect.Type) bool {
+	switch {
+	case t == fileHeaderType, t == readerType, reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return true
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Pointer, reflect.Slice:
+		return unmarshalable(t.Elem())
+	default:
+		return false
+	}
+}
+
+// unmarshalValue sets the value from the part, content is valid only during the call.
+// Fields reported by [spillable] are set from the collected file instead of the content.
+func unmarshalValue(v reflect.Value, part *Part, content []byte, fh *FileHeader) error {
+	t := v.Type()
+	switch {
+	case t == fileHeaderType:
+		v.Set(reflect.ValueOf(*fh))
+		return nil
+	case t == readerType:
+		if fh.tmpfile == "" {
+			v.Set(reflect.ValueOf(bytes.NewReader(fh.content)))
+			return nil
+		}
+		f, err := os.Open(fh.tmpfile)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tempFile{f}))
+		return nil
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(content)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		v.SetBytes(bytes.Clone(content))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return unmarshalValue(v.Elem(), part, content, fh)
+	case reflect.Slice:
+		elem := reflect.New(t.Elem()).Elem()
+		if err := unmarshalValue(elem, part, content, fh); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+		return nil
+	case reflect.String:
+		v.SetString(string(content))
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(string(content))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(string(content), 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(string(content), 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(string(content), t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+}
@@ -0,0 +1,206 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xakep666/itermultipart"
+)
+
+type unmarshalTarget struct {
+	*marshalBase
+
+	Name     string                      `multipart:"name"`
+	Tags     []string                    `multipart:"tag"`
+	Enabled  bool                        `multipart:"enabled"`
+	Ratio    float64                     `multipart:"ratio"`
+	Count    *uint8                      `multipart:"count"`
+	When     time.Time                   `multipart:"when"`
+	Raw      []byte                      `multipart:"raw"`
+	Reader   io.Reader                   `multipart:"reader"`
+	File     itermultipart.FileHeader    `multipart:"file"`
+	Files    []*itermultipart.FileHeader `multipart:"files"`
+	Untagged string
+}
+
+func TestUnmarshal(t *testing.T) {
+	parts := itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("id").SetContentString("42"),
+		itermultipart.NewPart().SetFormName("name").SetContentString("first"),
+		itermultipart.NewPart().SetFormName("name").SetContentString("name"),
+		itermultipart.NewPart().SetFormName("tag").SetContentString("a"),
+		itermultipart.NewPart().SetFormName("tag").SetContentString("b"),
+		itermultipart.NewPart().SetFormName("enabled").SetContentString("true"),
+		itermultipart.NewPart().SetFormName("ratio").SetContentString("0.5"),
+		itermultipart.NewPart().SetFormName("count").SetContentString("7"),
+		itermultipart.NewPart().SetFormName("when").SetContentString("2024-01-02T03:04:05Z"),
+		itermultipart.NewPart().SetFormName("raw").SetContentString("raw"),
+		itermultipart.NewPart().SetFormName("reader").SetContentString("reader"),
+		itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContentString("file"),
+		itermultipart.NewPart().SetFormName("files").SetFileName("1.txt").SetContentString("one"),
+		itermultipart.NewPart().SetFormName("files").SetFileName("2.txt").SetContentString("two"),
+		itermultipart.NewPart().SetFormName("unknown").SetContentString("unknown"),
+		itermultipart.NewPart().SetFormName("Untagged").SetContentString("untagged"),
+	)
+
+	// pass through the encoder and parser to make sure parts become invalid after iteration
+	src := itermultipart.NewSource(parts)
+	v := unmarshalTarget{marshalBase: new(marshalBase)} // unexported embedded pointers can't be allocated
+	if err := itermultipart.Unmarshal(itermultipart.NewParser(src, src.Boundary()).Parts(), &v); err != nil {
+		t.Fatalf("Unmarshal: unexpected error %s", err)
+	}
+
+	readAll := func(fh *itermultipart.FileHeader) string {
+		f, err := fh.Open()
+		if err != nil {
+			t.Fatalf("Open: %s", err)
+		}
+		defer f.Close()
+		content, _ := io.ReadAll(f)
+		return fmt.Sprintf("%s:%d:%s", fh.Filename, fh.Size, content)
+	}
+	reader, _ := io.ReadAll(v.Reader)
+
+	got := fmt.Sprintf("%d %s %v %v %v %d %s %s %s %s %s,%s %s",
+		v.ID, v.Name, v.Tags, v.Enabled, v.Ratio, *v.Count, v.When.Format(time.RFC3339), v.Raw, reader,
+		readAll(&v.File), readAll(v.Files[0]), readAll(v.Files[1]), v.Untagged)
+	want := "42 name [a b] true 0.5 7 2024-01-02T03:04:05Z raw reader file.txt:4:file 1.txt:3:one,2.txt:3:two untagged"
+	if got != want {
+		t.Errorf("\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	parts := itermultipart.PartSeq(itermultipart.NewPart().SetFormName("a").SetContentString("not a number"))
+
+	var number struct {
+		A int `multipart:"a"`
+	}
+	var unsupported struct {
+		A map[string]string `multipart:"a"`
+	}
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"not a pointer", number},
+		{"not a struct", new(int)},
+		{"unsupported type", &unsupported},
+		{"parse error", &number},
+	}
+	for _, tt := range tests {
+		if err := itermultipart.Unmarshal(parts, tt.v); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestUnmarshalLimits(t *testing.T) {
+	var target struct {
+		Name   string                   `multipart:"name"`
+		Reader io.Reader                `multipart:"reader"`
+		File   itermultipart.FileHeader `multipart:"file"`
+	}
+	parts := func() iter.Seq2[*itermultipart.Part, error] {
+		return itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("name").SetContentString("name"),
+			itermultipart.NewPart().SetFormName("reader").SetContentString("reader content"),
+			itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContentString("file content"),
+		)
+	}
+
+	if err := itermultipart.Unmarshal(parts(), &target, itermultipart.WithMaxPartSize(5)); !errors.Is(err, itermultipart.ErrPartTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrPartTooLarge)
+	}
+	if err := itermultipart.Unmarshal(parts(), &target, itermultipart.WithMaxTotalSize(10)); !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+	if err := itermultipart.Unmarshal(parts(), &target, itermultipart.WithMaxValuesSize(3)); !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+
+	// contents of readers and files beyond the memory limit are stored in temporary files
+	dir := t.TempDir()
+	if err := itermultipart.Unmarshal(parts(), &target, itermultipart.WithMaxMemory(5), itermultipart.WithTempDir(dir)); err != nil {
+		t.Fatalf("Unmarshal: unexpected error %s", err)
+	}
+	if tempFiles, _ := os.ReadDir(dir); len(tempFiles) != 2 {
+		t.Errorf("got %d temporary files, want 2", len(tempFiles))
+	}
+	if content, _ := io.ReadAll(target.Reader); string(content) != "reader content" {
+		t.Errorf("got reader content %q", content)
+	}
+	if err := target.Reader.(io.Closer).Close(); err != nil {
+		t.Errorf("Close: unexpected error %s", err)
+	}
+	f, err := target.File.Open()
+	if err != nil {
+		t.Fatalf("Open: unexpected error %s", err)
+	}
+	content, _ := io.ReadAll(f)
+	f.Close()
+	if string(content) != "file content" {
+		t.Errorf("got file content %q", content)
+	}
+	if err := target.File.Remove(); err != nil {
+		t.Errorf("Remove: unexpected error %s", err)
+	}
+	if tempFiles, _ := os.ReadDir(dir); len(tempFiles) != 0 {
+		t.Errorf("got %d temporary files after closing", len(tempFiles))
+	}
+
+	// temporary files are removed on error
+	failing := itermultipart.PartSeq(
+		itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContentString("file content"),
+		itermultipart.NewPart().SetFormName("name").SetContentString("too long name"),
+	)
+	err = itermultipart.Unmarshal(failing, &target, itermultipart.WithMaxMemory(5), itermultipart.WithTempDir(dir),
+		itermultipart.WithMaxValuesSize(5))
+	if !errors.Is(err, itermultipart.ErrMessageTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrMessageTooLarge)
+	}
+	if tempFiles, _ := os.ReadDir(dir); len(tempFiles) != 0 {
+		t.Errorf("got %d temporary files after error", len(tempFiles))
+	}
+}
+
+func ExampleUnmarshal() {
+	message := `--boundary
+Content-Disposition: form-data; name="title"
+
+greeting
+--boundary
+Content-Disposition: form-data; name="tag"
+
+hello
+--boundary
+Content-Disposition: form-data; name="tag"
+
+world
+--boundary
+Content-Disposition: form-data; name="file"; filename="hello.txt"
+
+Hello, World!
+--boundary--`
+	message = strings.ReplaceAll(message, "\n", "\r\n")
+
+	var form struct {
+		Title string                   `multipart:"title"`
+		Tags  []string                 `multipart:"tag"`
+		File  itermultipart.FileHeader `multipart:"file"`
+	}
+	parser := itermultipart.NewParser(strings.NewReader(message), "boundary")
+	if err := itermultipart.Unmarshal(parser.Parts(), &form); err != nil {
+		panic(err)
+	}
+
+	fmt.Println(form.Title, form.Tags, form.File.Filename, form.File.Size)
+	// Output:
+	// greeting [hello world] hello.txt 13
+}
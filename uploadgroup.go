@@ -0,0 +1,320 @@
+package itermultipart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UploadGroup sends many [Source]s as HTTP requests concurrently, like errgroup does for goroutines.
+// The zero value is not usable, create it with [NewUploadGroup].
+type UploadGroup struct {
+	ctx    context.Context
+	client *http.Client
+	sem    chan struct{} // nil if unlimited
+
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+
+	bytesSent atomic.Int64
+
+	cfg uploadConfig
+}
+
+type uploadConfig struct {
+	expectContinueTimeout time.Duration // 0 if disabled
+	retries               int
+	sourceOptions         []SourceOption
+}
+
+// UploadOption configures [UploadGroup] and [DoMultipart].
+type UploadOption func(*uploadConfig)
+
+// WithExpectContinue makes uploads send "Expect: 100-continue" header and hold the body back
+// until the server's interim "100 Continue" response arrives, so requests rejected by their headers
+// don't pull parts or open files. If the server doesn't respond within the timeout, the body is sent anyway,
+// but never after the final response arrived instead of "100 Continue".
+func WithExpectContinue(timeout time.Duration) UploadOption {
+	return func(c *uploadConfig) {
+		c.expectContinueTimeout = timeout
+	}
+}
+
+// WithUploadRetries makes uploads retry requests failed with transport errors up to n times.
+// Only requests of [Source]s created with [WithReplayableParts] and contents that can be rewound are retried,
+// see [NewRequest]; other failed uploads return [ErrNotReplayable] with the transport error. Responses are never retried.
+func WithUploadRetries(n int) UploadOption {
+	return func(c *uploadConfig) {
+		c.retries = n
+	}
+}
+
+// WithUploadSourceOptions sets options of the [Source] created by [DoMultipart].
+func WithUploadSourceOptions(opts ...SourceOption) UploadOption {
+	return func(c *uploadConfig) {
+		c.sourceOptions = append(c.sourceOptions, opts...)
+	}
+}
+
+// ErrNotReplayable is returned with the transport error when the upload can't be retried
+// because its part sequence is not declared with [WithReplayableParts] or part contents can't be rewound.
+var ErrNotReplayable = errors.New("upload is not replayable")
+
+// UploadError describes a failed upload of the [UploadGroup].
+type UploadError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload %s %s: %s", e.Method, e.URL, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// NewUploadGroup creates an [UploadGroup] sending requests with the client ([http.DefaultClient] if nil)
+// with at most limit uploads in flight (unlimited if limit <= 0).
+// Every upload gets its own context derived from ctx, so cancelling ctx stops all uploads.
+func NewUploadGroup(ctx context.Context, client *http.Client, limit int, opts ...UploadOption) *UploadGroup {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	g := &UploadGroup{ctx: ctx, client: client}
+	if limit > 0 {
+		g.sem = make(chan struct{}, limit)
+	}
+	for _, opt := range opts {
+		opt(&g.cfg)
+	}
+	return g
+}
+
+// Go starts uploading the [Source] created with [NewRequest]. It blocks while the limit of uploads in flight is reached.
+// Failed uploads are retried with [WithUploadRetries] only if the src is created with [WithReplayableParts].
+// The handler is called with the response, its body is closed afterwards.
+// If the handler is nil, responses with non-2xx status are reported as errors.
+// Progress of the upload is accounted in [UploadGroup.BytesSent], so the [Source]'s progress callback
+// set by [WithProgress] is wrapped.
+func (g *UploadGroup) Go(method, url string, src *Source, handle func(*http.Response) error) {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			g.addError(method, url, g.ctx.Err())
+			return
+		}
+	}
+
+	prevProgress := src.progress
+	var lastTotal int64
+	src.progress = func(part *Part, partBytes, totalBytes int64) {
+		if totalBytes < lastTotal {
+			lastTotal = 0 // request is retried with the rewound source
+		}
+		g.bytesSent.Add(totalBytes - lastTotal)
+		lastTotal = totalBytes
+		if prevProgress != nil {
+			prevProgress(part, partBytes, totalBytes)
+		}
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+
+		if err := g.upload(method, url, src, handle); err != nil {
+			g.addError(method, url, err)
+		}
+	}()
+}
+
+func (g *UploadGroup) upload(method, url string, src *Source, handle func(*http.Response) error) error {
+	ctx, cancel := context.WithCancel(g.ctx)
+	defer cancel()
+
+	req, err := NewRequest(ctx, method, url, src)
+	if err != nil {
+		return err
+	}
+	resp, err := g.cfg.do(g.client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if handle != nil {
+		return handle(resp)
+	}
+	io.Copy(io.Discard, resp.Body) // to reuse the connection
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (g *UploadGroup) addError(method, url string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errs = append(g.errs, &UploadError{Method: method, URL: url, Err: err})
+}
+
+// do sends the request applying the configuration.
+func (c *uploadConfig) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		sent := req
+		if c.expectContinueTimeout > 0 {
+			sent = expectContinue(req, c.expectContinueTimeout)
+		}
+		resp, err := client.Do(sent)
+		if body, ok := sent.Body.(*continueBody); ok {
+			body.stop() // the final response arrived or the request failed, the body is not needed if it's still held back
+		}
+		if err == nil || attempt >= c.retries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("%w: %w", ErrNotReplayable, err) // the body is consumed already
+		}
+
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, errors.Join(err, bodyErr)
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+}
+
+// DoMultipart sends parts as a "multipart/form-data" request with the client ([http.DefaultClient] if nil).
+// The [Source] is created with options set by [WithUploadSourceOptions] and the request with [NewRequest],
+// so the request may be redirected and retried with [WithUploadRetries] only if [WithReplayableParts] is among
+// these options and contents of all parts can be rewound. Other sequences, i.e. parsed or proxied parts,
+// are iterated once while the body is sent. The caller must close the response body.
+func DoMultipart(ctx context.Context, client *http.Client, method, url string, parts iter.Seq2[*Part, error], opts ...UploadOption) (*http.Response, error) {
+	var cfg uploadConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := NewRequest(ctx, method, url, NewSource(parts, cfg.sourceOptions...))
+	if err != nil {
+		return nil, err
+	}
+	return cfg.do(client, req)
+}
+
+// expectContinue returns the request with "Expect: 100-continue" header and the body waiting for the interim response.
+func expectContinue(req *http.Request, timeout time.Duration) *http.Request {
+	got := make(chan struct{})
+	var once sync.Once
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		Got100Continue: func() { once.Do(func() { close(got) }) },
+	}))
+	req.Header.Set("Expect", "100-continue")
+	req.Body = &continueBody{ctx: req.Context(), body: req.Body, got: got, stopped: make(chan struct{}), timeout: timeout}
+	return req
+}
+
+// continueBody holds the body back until "100 Continue" response. [http.Transport] does that too
+// if its ExpectContinueTimeout is set, continueBody makes it independent of the client configuration.
+// Like the transport, it doesn't send the body once the final response arrived without "100 Continue".
+type continueBody struct {
+	ctx      context.Context
+	got      <-chan struct{}
+	stopped  chan struct{} // closed when the final response arrived or the body is closed
+	stopOnce sync.Once
+	timeout  time.Duration
+
+	mu     sync.Mutex // guards fields below, the transport may close the body while it's read
+	body   io.ReadCloser
+	waited bool
+	closed bool
+}
+
+func (b *continueBody) stop() {
+	b.stopOnce.Do(func() { close(b.stopped) })
+}
+
+// wait blocks until the body may be sent, [io.EOF] is returned if it isn't needed anymore.
+func (b *continueBody) wait() error {
+	b.mu.Lock()
+	waited := b.waited
+	b.waited = true
+	b.mu.Unlock()
+	if waited {
+		return nil
+	}
+
+	timer := time.NewTimer(b.timeout)
+	defer timer.Stop()
+	select {
+	case <-b.got:
+		return nil
+	case <-timer.C:
+		select {
+		case <-b.stopped:
+			return io.EOF
+		default:
+			return nil
+		}
+	case <-b.stopped:
+		return io.EOF
+	case <-b.ctx.Done():
+		return b.ctx.Err()
+	}
+}
+
+func (b *continueBody) Read(p []byte) (int, error) {
+	if err := b.wait(); err != nil {
+		return 0, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	return b.body.Read(p)
+}
+
+func (b *continueBody) Close() error {
+	b.stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	return b.body.Close()
+}
+
+// BytesSent returns the number of message bytes sent by all uploads so far.
+func (g *UploadGroup) BytesSent() int64 {
+	return g.bytesSent.Load()
+}
+
+// Wait waits for all uploads to finish and returns their errors joined with [errors.Join].
+func (g *UploadGroup) Wait() error {
+	g.wg.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return errors.Join(g.errs...)
+}
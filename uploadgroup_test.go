@@ -0,0 +1,267 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestUploadGroup(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		received.Add(int64(len(body)))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	const limit = 2
+	g := itermultipart.NewUploadGroup(context.Background(), srv.Client(), limit)
+	var handled atomic.Int32
+	for i := range 10 {
+		var handle func(*http.Response) error
+		if i%2 == 0 {
+			handle = func(resp *http.Response) error {
+				handled.Add(1)
+				return nil
+			}
+		}
+		g.Go(http.MethodPost, srv.URL+"/ok", itermultipart.NewSource(smallFields(100)), handle)
+	}
+	g.Go(http.MethodPut, srv.URL+"/fail", itermultipart.NewSource(smallFields(1)), nil)
+
+	err := g.Wait()
+	var uploadErr *itermultipart.UploadError
+	if !errors.As(err, &uploadErr) || uploadErr.Method != http.MethodPut || uploadErr.URL != srv.URL+"/fail" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if got := maxInFlight.Load(); got > limit {
+		t.Errorf("%d uploads were in flight, limit is %d", got, limit)
+	}
+	if got := handled.Load(); got != 5 {
+		t.Errorf("handler called %d times, want 5", got)
+	}
+	if sent, got := g.BytesSent(), received.Load(); sent != got {
+		t.Errorf("sent %d bytes, server received %d", sent, got)
+	}
+}
+
+func TestUploadGroupCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	g := itermultipart.NewUploadGroup(ctx, nil, 1)
+	g.Go(http.MethodPost, "http://example.com", itermultipart.NewSource(smallFields(1)), nil)
+	g.Go(http.MethodPost, "http://example.com", itermultipart.NewSource(smallFields(1)), nil)
+	if err := g.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestUploadGroupExpectContinue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Expect") != "100-continue" {
+			t.Errorf("unexpected Expect header %q", r.Header.Get("Expect"))
+		}
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge) // without reading the body
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+
+	var opened atomic.Int32
+	newSource := func() *itermultipart.Source {
+		return itermultipart.NewSource(itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContentFactory(func() (io.ReadCloser, error) {
+				opened.Add(1)
+				return io.NopCloser(strings.NewReader("content")), nil
+			}),
+		))
+	}
+
+	g := itermultipart.NewUploadGroup(context.Background(), srv.Client(), 0, itermultipart.WithExpectContinue(time.Minute))
+	g.Go(http.MethodPost, srv.URL+"/reject", newSource(), func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait: unexpected error %s", err)
+	}
+	if got := opened.Load(); got != 0 {
+		t.Errorf("content of the rejected upload was opened %d times", got)
+	}
+
+	g.Go(http.MethodPost, srv.URL+"/accept", newSource(), nil)
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait: unexpected error %s", err)
+	}
+	if got := opened.Load(); got != 1 {
+		t.Errorf("content of the accepted upload was opened %d times", got)
+	}
+}
+
+func TestDoMultipartExpectContinueRejected(t *testing.T) {
+	for _, status := range []int{http.StatusExpectationFailed, http.StatusRequestEntityTooLarge} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status) // without reading the body
+		}))
+
+		var opened atomic.Int32
+		parts := itermultipart.PartSeq(
+			itermultipart.NewPart().SetFormName("file").SetFileName("file.txt").SetContentFactory(func() (io.ReadCloser, error) {
+				opened.Add(1)
+				return io.NopCloser(strings.NewReader("content")), nil
+			}),
+		)
+		resp, err := itermultipart.DoMultipart(context.Background(), srv.Client(), http.MethodPost, srv.URL, parts,
+			itermultipart.WithExpectContinue(50*time.Millisecond))
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("got status %s, want %d", resp.Status, status)
+		}
+
+		time.Sleep(200 * time.Millisecond) // the body would be sent after the timeout
+		if got := opened.Load(); got != 0 {
+			t.Errorf("status %d: content of the rejected upload was opened %d times", status, got)
+		}
+		srv.Close()
+	}
+}
+
+func TestDoMultipart(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %s", err)
+				return
+			}
+			conn.Close() // transport error for the client
+			return
+		}
+		for part, err := range itermultipart.PartsFromRequest(r, false) {
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, "%s=", part.FormName())
+			io.Copy(w, part.Content)
+		}
+	}))
+	defer srv.Close()
+
+	parts := func() iter.Seq2[*itermultipart.Part, error] {
+		return itermultipart.PartSeq(itermultipart.NewFieldPart("a", "1"))
+	}
+	if _, err := itermultipart.DoMultipart(context.Background(), srv.Client(), http.MethodPost, srv.URL, parts()); err == nil {
+		t.Fatal("error expected without retries")
+	}
+
+	requests.Store(0)
+	resp, err := itermultipart.DoMultipart(context.Background(), srv.Client(), http.MethodPost, srv.URL, parts(),
+		itermultipart.WithUploadRetries(1),
+		itermultipart.WithUploadSourceOptions(itermultipart.WithBoundaryFunc(itermultipart.SequentialBoundaries("b")), itermultipart.WithReplayableParts()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "a=1" {
+		t.Errorf("unexpected response %s %q", resp.Status, body)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("got %d requests, want 2", requests.Load())
+	}
+
+	// one-shot sequence is sent intact
+	var message bytes.Buffer
+	mw := multipart.NewWriter(&message)
+	mw.WriteField("b", "2")
+	mw.Close()
+	requests.Store(1) // don't fail
+	resp, err = itermultipart.DoMultipart(context.Background(), srv.Client(), http.MethodPost, srv.URL,
+		itermultipart.PartsFromReader(multipart.NewReader(&message, mw.Boundary()), false))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(body) != "b=2" {
+		t.Errorf("unexpected response %s %q", resp.Status, body)
+	}
+}
+
+func TestUploadGroupRetries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %s", err)
+				return
+			}
+			conn.Close() // transport error for the client
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil || r.FormValue("a") != "1" {
+			t.Errorf("retried body is broken: %v, a=%q", err, r.FormValue("a"))
+		}
+	}))
+	defer srv.Close()
+
+	g := itermultipart.NewUploadGroup(context.Background(), srv.Client(), 0, itermultipart.WithUploadRetries(1))
+	g.Go(http.MethodPost, srv.URL, itermultipart.NewSource(itermultipart.PartSeq(itermultipart.NewFieldPart("a", "1")), itermultipart.WithReplayableParts()), nil)
+	if err := g.Wait(); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+
+	// one-shot sequence can't be retried
+	var message bytes.Buffer
+	mw := multipart.NewWriter(&message)
+	mw.WriteField("a", "1")
+	mw.Close()
+	requests.Store(0)
+	g.Go(http.MethodPost, srv.URL, itermultipart.NewSource(itermultipart.PartsFromReader(multipart.NewReader(&message, mw.Boundary()), false)), nil)
+	if err := g.Wait(); !errors.Is(err, itermultipart.ErrNotReplayable) {
+		t.Errorf("got error %v, want %v", err, itermultipart.ErrNotReplayable)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,132 @@
+package itermultipart
+
+import (
+	"fmt"
+	"maps"
+	"net/textproto"
+	"slices"
+	"strings"
+)
+
+// HeaderError describes an invalid header of the part found by [Part.Validate] or [WithStrictValidation].
+type HeaderError struct {
+	Part     int    // index of the part in the sequence, -1 if unknown
+	FormName string // form name of the part if any
+	Header   string
+	Value    string // empty if the header key is invalid
+	Reason   string
+}
+
+func (e *HeaderError) Error() string {
+	var sb strings.Builder
+	if e.Part >= 0 {
+		fmt.Fprintf(&sb, "part %d: ", e.Part)
+	}
+	if e.FormName != "" {
+		fmt.Fprintf(&sb, "form field %q: ", e.FormName)
+	}
+	fmt.Fprintf(&sb, "header %q: %s", e.Header, e.Reason)
+	if e.Value != "" {
+		fmt.Fprintf(&sb, " %q", e.Value)
+	}
+	return sb.String()
+}
+
+// Validate checks that the part can be written without corrupting the message or injecting headers:
+// header keys must be RFC 7230 tokens, header values must not contain CR, LF or other control characters
+// except horizontal tab, form and file names must not contain control characters.
+// See [WithStrictValidation] to validate parts while generating the message.
+// Header errors are reported as [*HeaderError].
+func (p *Part) Validate() error {
+	return p.validate(false)
+}
+
+func (p *Part) validate(asciiOnly bool) error {
+	if err := validateHeader(p.Header, asciiOnly); err != nil {
+		err.FormName = p.FormName()
+		return err
+	}
+	if name := p.FormName(); !validName(name) {
+		return fmt.Errorf("invalid form name %q", name)
+	}
+	// FileName strips directories, validate the whole value
+	if name := p.dispositionParams["filename"]; !validName(name) {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	return nil
+}
+
+// WithStrictValidation makes [Source] validate every part with [Part.Validate] and default part headers
+// before writing them, so generation fails fast instead of emitting a corrupt message.
+func WithStrictValidation() SourceOption {
+	return func(s *Source) {
+		s.strict = true
+	}
+}
+
+// WithASCIIHeaderValues makes [Source] validation stricter: header values must also contain only ASCII characters,
+// as some parsers mishandle other octets. It implies [WithStrictValidation].
+func WithASCIIHeaderValues() SourceOption {
+	return func(s *Source) {
+		s.strict, s.asciiHeaders = true, true
+	}
+}
+
+func validateHeader(h textproto.MIMEHeader, asciiOnly bool) *HeaderError {
+	for _, k := range slices.Sorted(maps.Keys(h)) { // report errors deterministically
+		if !validHeaderKey(k) {
+			return &HeaderError{Part: -1, Header: k, Reason: "invalid key"}
+		}
+		for _, v := range h[k] {
+			if !validHeaderValue(v) {
+				return &HeaderError{Part: -1, Header: k, Value: v, Reason: "control character in value"}
+			}
+			if asciiOnly && !isPrintableASCII(v) {
+				return &HeaderError{Part: -1, Header: k, Value: v, Reason: "non-ASCII value"}
+			}
+		}
+	}
+	return nil
+}
+
+func validHeaderKey(k string) bool {
+	if k == "" {
+		return false
+	}
+	for i := range len(k) {
+		if !isTokenChar(k[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", b) >= 0
+}
+
+func validHeaderValue(v string) bool {
+	for i := range len(v) {
+		if b := v[i]; b < ' ' && b != '\t' || b == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrintableASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func validName(name string) bool {
+	return !strings.ContainsFunc(name, func(r rune) bool { return r < ' ' || r == 0x7f })
+}
@@ -0,0 +1,88 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestPartValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		part    *itermultipart.Part
+		wantErr string
+	}{
+		{"valid", itermultipart.NewFieldPart("name", "value").SetHeaderValue("X-Tab", "a\tb"), ""},
+		{"CRLF in header value", itermultipart.NewFieldPart("name", "value").SetHeaderValue("X-Injected", "a\r\nX-Evil: 1"), "control character in value"},
+		{"NUL in header value", itermultipart.NewFieldPart("name", "value").SetHeaderValue("X-Nul", "a\x00b"), "control character in value"},
+		{"space in header key", itermultipart.NewFieldPart("name", "value").AddHeaderValue("X Evil", "1"), "invalid key"},
+		{"newline in header key", &itermultipart.Part{Header: textproto.MIMEHeader{"X-A\r\nX-B": {"1"}}}, "invalid key"},
+		{"control in form name", itermultipart.NewFieldPart("na\nme", "value"), "invalid form name"},
+		{"control in file name", itermultipart.NewPart().SetFormName("file").SetFileName("a\rb.txt"), "invalid file name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.part.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error %s", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSourceStrictValidation(t *testing.T) {
+	parts := itermultipart.PartSeq(
+		itermultipart.NewFieldPart("valid", "value"),
+		itermultipart.NewFieldPart("invalid", "value").SetHeaderValue("X-Injected", "a\r\nX-Evil: 1"),
+	)
+	if _, err := itermultipart.NewSource(parts).WriteTo(io.Discard); err != nil {
+		t.Errorf("unexpected error without strict validation %s", err)
+	}
+
+	consumers := []struct {
+		name string
+		read func(*itermultipart.Source) error
+	}{
+		{"WriteTo", func(src *itermultipart.Source) error { _, err := src.WriteTo(io.Discard); return err }},
+		{"Read", func(src *itermultipart.Source) error {
+			_, err := io.Copy(io.Discard, struct{ io.Reader }{src})
+			return err
+		}},
+	}
+	for _, c := range consumers {
+		src := itermultipart.NewSource(parts, itermultipart.WithStrictValidation())
+		var headerErr *itermultipart.HeaderError
+		if err := c.read(src); !errors.As(err, &headerErr) || headerErr.Part != 1 || headerErr.FormName != "invalid" || headerErr.Header != "X-Injected" {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+
+		src = itermultipart.NewSource(itermultipart.PartSeq(itermultipart.NewFieldPart("valid", "value")), itermultipart.WithStrictValidation())
+		src.SetDefaultPartHeaders(textproto.MIMEHeader{"X-Default": {"a\nb"}})
+		if err := c.read(src); err == nil || !strings.Contains(err.Error(), "default part headers") {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestSourceASCIIHeaderValues(t *testing.T) {
+	parts := itermultipart.PartSeq(
+		itermultipart.NewFieldPart("valid", "value").SetFileName("café.txt"), // encoded by SetFileName
+		itermultipart.NewFieldPart("invalid", "value").SetHeaderValue("X-Title", "café"),
+	)
+	if _, err := itermultipart.NewSource(parts, itermultipart.WithStrictValidation()).WriteTo(io.Discard); err != nil {
+		t.Errorf("unexpected error with UTF-8 header value %s", err)
+	}
+
+	_, err := itermultipart.NewSource(parts, itermultipart.WithASCIIHeaderValues()).WriteTo(io.Discard)
+	want := `part 1: form field "invalid": header "X-Title": non-ASCII value "café"`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
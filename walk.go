@@ -0,0 +1,93 @@
+package itermultipart
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"mime"
+	"net/textproto"
+	"slices"
+	"strings"
+)
+
+// ErrNestingTooDeep is returned by [WalkParts] when multipart messages are nested deeper than allowed.
+var ErrNestingTooDeep = errors.New("multipart nesting is too deep")
+
+// WalkParts returns the sequence of leaf parts descending into parts whose content is a nested multipart message,
+// i.e. to extract attachments from emails. Nested messages are parsed with [Parser] configured by opts while they stream.
+// Headers of enclosing multipart parts are available with [Part.Parents] while the part is yielded.
+// Multipart parts with a transfer encoding other than 7bit, 8bit or binary are yielded as leaves.
+// Nesting deeper than maxDepth levels yields [ErrNestingTooDeep], a nested message reusing the boundary
+// of an enclosing one yields an error too. Both stop the sequence. Errors of the underlying sequence are passed through.
+func WalkParts(parts iter.Seq2[*Part, error], maxDepth int, opts ...ParserOption) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		w := walker{maxDepth: maxDepth, opts: opts, yield: yield}
+		w.walk(parts, nil, nil)
+	}
+}
+
+type walker struct {
+	maxDepth int
+	opts     []ParserOption
+	yield    func(*Part, error) bool
+}
+
+// walk yields leaf parts of the message nested in parents, reports false if the sequence must stop.
+func (w *walker) walk(parts iter.Seq2[*Part, error], parents []textproto.MIMEHeader, boundaries []string) bool {
+	for part, err := range parts {
+		if err != nil {
+			if !w.yield(nil, err) {
+				return false
+			}
+			continue
+		}
+
+		boundary, ok := nestedBoundary(part)
+		if !ok {
+			part.parents = parents
+			next := w.yield(part, nil)
+			part.parents = nil
+			if !next {
+				return false
+			}
+			continue
+		}
+
+		if len(parents) >= w.maxDepth {
+			w.yield(nil, fmt.Errorf("%w: more than %d levels", ErrNestingTooDeep, w.maxDepth))
+			return false
+		}
+		if slices.Contains(boundaries, boundary) {
+			w.yield(nil, fmt.Errorf("multipart: nested message reuses the boundary %q", boundary))
+			return false
+		}
+		nested := NewParser(part.Content, boundary, w.opts...).Parts()
+		if !w.walk(nested, append(slices.Clip(parents), maps.Clone(part.Header)), append(slices.Clip(boundaries), boundary)) {
+			return false
+		}
+	}
+	return true
+}
+
+// nestedBoundary returns the boundary of the nested multipart message in the part content.
+func nestedBoundary(part *Part) (string, bool) {
+	if part.Content == nil {
+		return "", false
+	}
+	switch strings.ToLower(part.Header.Get(contentTransferEncodingHeader)) {
+	case "", TransferEncoding7Bit, TransferEncoding8Bit, TransferEncodingBinary:
+	default:
+		return "", false
+	}
+	mediaType, params, err := mime.ParseMediaType(part.ContentType())
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return "", false
+	}
+	return params["boundary"], true
+}
+
+// Parents returns headers of multipart parts enclosing the part yielded by [WalkParts], the outermost first.
+func (p *Part) Parents() []textproto.MIMEHeader {
+	return p.parents
+}
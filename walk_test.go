@@ -0,0 +1,84 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func nestedMessage(t *testing.T) (*bytes.Buffer, string) {
+	t.Helper()
+
+	inner := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentType("text/plain").SetContentString("text"),
+		itermultipart.NewPart().SetContentType("text/html").SetContentString("<p>html</p>"),
+	))
+	middle := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentType("multipart/alternative").SetMultipartContent(inner),
+		itermultipart.NewPart().SetContentType("image/png").SetContentString("PNG"),
+	))
+	outer := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewPart().SetContentType("multipart/related").SetMultipartContent(middle),
+		itermultipart.NewPart().SetAttachment().SetFileName("a.txt").SetContentString("attachment"),
+	))
+
+	var b bytes.Buffer
+	if _, err := outer.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	return &b, outer.Boundary()
+}
+
+func TestWalkParts(t *testing.T) {
+	message, boundary := nestedMessage(t)
+
+	type leaf struct {
+		content string
+		parents string
+	}
+	var got []leaf
+	for part, err := range itermultipart.WalkParts(itermultipart.NewParser(message, boundary).Parts(), 2) {
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		content, err := io.ReadAll(part.Content)
+		if err != nil {
+			t.Fatalf("reading content: unexpected error %s", err)
+		}
+		var parents string
+		part.SetHeaderOrder("Content-Type") // reordering headers keeps the ancestry
+		for _, h := range part.Parents() {
+			parents += "/" + h.Get("Content-Type")[:len("multipart/")+3]
+		}
+		got = append(got, leaf{content: string(content), parents: parents})
+	}
+
+	want := []leaf{
+		{"text", "/multipart/rel/multipart/alt"},
+		{"<p>html</p>", "/multipart/rel/multipart/alt"},
+		{"PNG", "/multipart/rel"},
+		{"attachment", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got leaves %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("leaf %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	message, boundary = nestedMessage(t)
+	var err error
+	for _, partErr := range itermultipart.WalkParts(itermultipart.NewParser(message, boundary).Parts(), 1) {
+		if partErr != nil {
+			err = partErr
+		}
+	}
+	if !errors.Is(err, itermultipart.ErrNestingTooDeep) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrNestingTooDeep)
+	}
+}
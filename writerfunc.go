@@ -0,0 +1,49 @@
+package itermultipart
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"mime/multipart"
+)
+
+// errWriterFuncStopped is returned to writes of the [multipart.Writer] when the consumer stops early.
+var errWriterFuncStopped = errors.New("multipart: consumer stopped reading parts")
+
+// PartsFromWriterFunc returns the sequence of parts written by f to the [multipart.Writer],
+// so code built around multipart.Writer can feed a [Source] and be migrated incrementally.
+// f runs in its own goroutine writing to a pipe which is parsed part by part, so parts are streamed
+// without buffering. f must not close the writer, it's closed after f returns; an error returned by f is yielded.
+// When the consumer stops early, writes of f fail and the sequence waits for f to return.
+// Every iteration runs f again.
+func PartsFromWriterFunc(f func(w *multipart.Writer) error) iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			err := f(mw)
+			if err == nil {
+				err = mw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		defer func() {
+			pr.CloseWithError(errWriterFuncStopped)
+			<-done
+		}()
+
+		for part, err := range NewParser(pr, mw.Boundary()).Parts() {
+			if !yield(part, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// NewSourceFromWriterFunc returns a [Source] generating the message of parts written by f, see [PartsFromWriterFunc].
+// The message has the boundary of the [Source], not one of the [multipart.Writer].
+func NewSourceFromWriterFunc(f func(w *multipart.Writer) error, opts ...SourceOption) *Source {
+	return NewSource(PartsFromWriterFunc(f), opts...)
+}
@@ -0,0 +1,79 @@
+package itermultipart_test
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestNewSourceFromWriterFunc(t *testing.T) {
+	large := strings.Repeat("x", 1<<20)
+	src := itermultipart.NewSourceFromWriterFunc(func(w *multipart.Writer) error {
+		if err := w.WriteField("title", "report"); err != nil {
+			return err
+		}
+		fw, err := w.CreateFormFile("file", "large.txt")
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(fw, large)
+		return err
+	})
+
+	var sb strings.Builder
+	if _, err := src.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+	form, err := multipart.NewReader(strings.NewReader(sb.String()), src.Boundary()).ReadForm(2 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm: unexpected error %s", err)
+	}
+	if got := form.Value["title"]; len(got) != 1 || got[0] != "report" {
+		t.Errorf("got title %v", got)
+	}
+	if files := form.File["file"]; len(files) != 1 || files[0].Filename != "large.txt" || files[0].Size != int64(len(large)) {
+		t.Errorf("unexpected files %v", files)
+	}
+}
+
+func TestPartsFromWriterFunc(t *testing.T) {
+	errWrite := errors.New("write failed")
+	parts := itermultipart.PartsFromWriterFunc(func(w *multipart.Writer) error {
+		if err := w.WriteField("a", "1"); err != nil {
+			return err
+		}
+		return errWrite
+	})
+	var count int
+	var gotErr error
+	for part, err := range parts {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		io.Copy(io.Discard, part.Content)
+		count++
+	}
+	if count != 1 || !errors.Is(gotErr, errWrite) {
+		t.Errorf("got %d parts and error %v, want 1 and %s", count, gotErr, errWrite)
+	}
+
+	// stopping early makes writes fail
+	var writeErr error
+	endless := itermultipart.PartsFromWriterFunc(func(w *multipart.Writer) error {
+		for {
+			if writeErr = w.WriteField("n", "v"); writeErr != nil {
+				return writeErr
+			}
+		}
+	})
+	for range itermultipart.LimitParts(endless, 2) {
+	}
+	if writeErr == nil {
+		t.Error("writes don't fail after stop")
+	}
+}
@@ -0,0 +1,177 @@
+package itermultipart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"mime"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	xopMediaType = "application/xop+xml"
+	xopNamespace = "http://www.w3.org/2004/08/xop/include"
+)
+
+// NewXOPRootPart returns the root part of the XOP package (MTOM, SOAP with attachments) with the given Content-ID.
+// documentType is the media type of the XML document, i.e. "application/soap+xml" for SOAP 1.2 or "text/xml" for SOAP 1.1.
+// The content is the XML document referencing attachments with [XOPInclude].
+func NewXOPRootPart(cid, documentType string) *Part {
+	return NewPart().
+		SetContentType(mime.FormatMediaType(xopMediaType, map[string]string{"charset": "UTF-8", "type": documentType})).
+		SetContentID(cid)
+}
+
+// NewXOPAttachmentPart returns the binary attachment part of the XOP package with the given Content-ID.
+func NewXOPAttachmentPart(cid, contentType string) *Part {
+	return NewPart().
+		SetContentType(contentType).
+		SetHeaderValue(contentTransferEncodingHeader, "binary").
+		SetContentID(cid)
+}
+
+// XOPInclude returns the xop:Include element referencing the attachment with the given Content-ID
+// to be placed into the XML document instead of base64 encoded data.
+func XOPInclude(cid string) string {
+	var sb strings.Builder
+	sb.WriteString(`<xop:Include xmlns:xop="` + xopNamespace + `" href="cid:`)
+	xml.EscapeText(&sb, []byte(url.PathEscape(strings.Trim(cid, "<>"))))
+	sb.WriteString(`"/>`)
+	return sb.String()
+}
+
+// XOPContentType returns the Content-Type for the XOP package with this [Source]'s Boundary,
+// startCID and documentType are ones of the root part, see [NewXOPRootPart].
+func (s *Source) XOPContentType(startCID, documentType string) string {
+	params := map[string]string{"type": xopMediaType}
+	if startCID != "" {
+		params["start"] = "<" + strings.Trim(startCID, "<>") + ">"
+	}
+	if documentType != "" {
+		params["start-info"] = documentType
+	}
+	return s.ContentType("related", params)
+}
+
+// XOPPackage is the XOP package read by [ReadXOP].
+type XOPPackage struct {
+	RootHeader textproto.MIMEHeader
+	Root       []byte   // XML document with xop:Include elements
+	Includes   []string // Content-IDs referenced by xop:Include elements in document order
+}
+
+// ReadXOP reads the XOP package (MTOM, SOAP with attachments) from parts of the multipart/related message.
+// The root part must be the first one, its Content-ID must match start, the "start" parameter of the message Content-Type,
+// if it's not empty. The root XML document is read into memory up to maxRootSize bytes, [ErrPartTooLarge] is returned
+// if it's larger. Attachment parts referenced by xop:Include elements are passed to the attachment function
+// with their Content-IDs as they are read, so contents are streamed. Unreferenced parts are skipped,
+// an error is returned if a referenced part is missing.
+func ReadXOP(parts iter.Seq2[*Part, error], start string, maxRootSize int64, attachment func(cid string, part *Part) error) (*XOPPackage, error) {
+	var (
+		pkg      *XOPPackage
+		resolved = make(map[string]bool)
+	)
+	for part, err := range parts {
+		if err != nil {
+			return nil, err
+		}
+		if pkg == nil {
+			if pkg, err = readXOPRoot(part, start, maxRootSize); err != nil {
+				return nil, err
+			}
+			for _, cid := range pkg.Includes {
+				resolved[cid] = false
+			}
+			continue
+		}
+
+		cid := part.ContentID()
+		if done, ok := resolved[cid]; !ok || done {
+			continue
+		}
+		resolved[cid] = true
+		if err := attachment(cid, part); err != nil {
+			return nil, fmt.Errorf("xop: attachment %q: %w", cid, err)
+		}
+	}
+
+	if pkg == nil {
+		return nil, errors.New("xop: no root part")
+	}
+	for _, cid := range pkg.Includes {
+		if !resolved[cid] {
+			return nil, fmt.Errorf("xop: referenced attachment %q not found", cid)
+		}
+	}
+	return pkg, nil
+}
+
+func readXOPRoot(part *Part, start string, maxRootSize int64) (*XOPPackage, error) {
+	if start = strings.Trim(start, "<>"); start != "" && part.ContentID() != start {
+		return nil, fmt.Errorf("xop: the first part %q is not the root part %q", part.ContentID(), start)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(part.ContentType()); mediaType != xopMediaType {
+		return nil, fmt.Errorf("xop: unexpected root part media type %q", mediaType)
+	}
+
+	var root []byte
+	if part.Content != nil {
+		var err error
+		root, err = io.ReadAll(io.LimitReader(part.Content, maxRootSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(root)) > maxRootSize {
+			return nil, fmt.Errorf("xop: root part: %w: more than %d bytes", ErrPartTooLarge, maxRootSize)
+		}
+	}
+
+	includes, err := XOPIncludes(root)
+	if err != nil {
+		return nil, err
+	}
+	return &XOPPackage{
+		RootHeader: maps.Clone(part.Header),
+		Root:       root,
+		Includes:   includes,
+	}, nil
+}
+
+// XOPIncludes returns Content-IDs referenced by xop:Include elements of the XML document in document order.
+func XOPIncludes(document []byte) ([]string, error) {
+	var includes []string
+	dec := xml.NewDecoder(bytes.NewReader(document))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return includes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xop: %w", err)
+		}
+
+		el, ok := tok.(xml.StartElement)
+		if !ok || el.Name.Space != xopNamespace || el.Name.Local != "Include" {
+			continue
+		}
+		for _, attr := range el.Attr {
+			if attr.Name.Local != "href" {
+				continue
+			}
+			ref, ok := strings.CutPrefix(attr.Value, "cid:")
+			if !ok {
+				return nil, fmt.Errorf("xop: unsupported reference %q", attr.Value)
+			}
+			if cid, err := url.PathUnescape(ref); err == nil {
+				ref = cid
+			}
+			includes = append(includes, ref)
+		}
+	}
+}
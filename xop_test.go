@@ -0,0 +1,63 @@
+package itermultipart_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"testing"
+
+	"github.com/xakep666/itermultipart"
+)
+
+func TestXOP(t *testing.T) {
+	document := `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body><data>` +
+		itermultipart.XOPInclude("image@example.com") + `</data></soap:Body></soap:Envelope>`
+	src := itermultipart.NewSource(itermultipart.PartSeq(
+		itermultipart.NewXOPRootPart("root@example.com", "application/soap+xml").SetContentString(document),
+		itermultipart.NewXOPAttachmentPart("unused@example.com", "text/plain").SetContentString("unused"),
+		itermultipart.NewXOPAttachmentPart("image@example.com", "image/png").SetContentString("PNG data"),
+	))
+	contentType := src.XOPContentType("root@example.com", "application/soap+xml")
+
+	var message bytes.Buffer
+	if _, err := src.WriteTo(&message); err != nil {
+		t.Fatalf("WriteTo: unexpected error %s", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/related" || params["type"] != "application/xop+xml" || params["start"] != "<root@example.com>" {
+		t.Fatalf("unexpected Content-Type %q", contentType)
+	}
+
+	attachments := make(map[string]string)
+	parser := itermultipart.NewParser(bytes.NewReader(message.Bytes()), params["boundary"])
+	pkg, err := itermultipart.ReadXOP(parser.Parts(), params["start"], 1<<10, func(cid string, part *itermultipart.Part) error {
+		content, err := io.ReadAll(part.Content)
+		attachments[cid] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ReadXOP: unexpected error %s", err)
+	}
+	if string(pkg.Root) != document {
+		t.Errorf("got root %q, want %q", pkg.Root, document)
+	}
+	if len(pkg.Includes) != 1 || pkg.Includes[0] != "image@example.com" {
+		t.Errorf("got includes %q", pkg.Includes)
+	}
+	if len(attachments) != 1 || attachments["image@example.com"] != "PNG data" {
+		t.Errorf("got attachments %q", attachments)
+	}
+
+	_, err = itermultipart.ReadXOP(itermultipart.NewParser(bytes.NewReader(message.Bytes()), params["boundary"]).Parts(),
+		"", 10, func(string, *itermultipart.Part) error { return nil })
+	if !errors.Is(err, itermultipart.ErrPartTooLarge) {
+		t.Errorf("got error %v, want %s", err, itermultipart.ErrPartTooLarge)
+	}
+
+	missing := itermultipart.PartSeq(itermultipart.NewXOPRootPart("root", "text/xml").SetContentString(document))
+	if _, err := itermultipart.ReadXOP(missing, "", 1<<10, nil); err == nil {
+		t.Error("expected error for missing attachment")
+	}
+}